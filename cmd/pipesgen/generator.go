@@ -0,0 +1,612 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	pipeImportPath = "github.com/mariomac/pipes/pipe"
+	connectTag     = "pipes"
+)
+
+// sourceImporter type-checks the packages imported by the NodesMap file from their source code.
+// It caches the imported packages, so it's not safe for concurrent use
+var sourceImporter = importer.ForCompiler(token.NewFileSet(), "source", nil)
+
+// nodeTypes are the types from the pipe package that are considered as nodes
+var nodeTypes = map[string]struct{}{
+	"Start":          {},
+	"Middle":         {},
+	"Final":          {},
+	"FailableMiddle": {},
+	"FailableFinal":  {},
+}
+
+// receiverWrappers are the functions from the pipe package that wrap the Receiver passed as their
+// last argument
+var receiverWrappers = map[string]struct{}{
+	"Route":        {},
+	"DefaultRoute": {},
+	"Cloned":       {},
+	"Record":       {},
+}
+
+type nodeField struct {
+	Name     string
+	FuncName string
+	Type     string
+	// Kind is the name of the node type in the pipe package (Start, Middle...)
+	Kind string
+	// In and Out are the source code of the input and output types of the node, if any
+	In, Out string
+	// in and out are the input and output types of the node, as resolved by the type checker
+	in, out types.Type
+}
+
+// connection from a node (or from its DeadLetters sender) to a receiver, as declared in
+// the struct tags or in the Connect method of the NodesMap
+type connection struct {
+	pos         token.Position
+	src, dst    string
+	deadLetters bool
+}
+
+type taggedConnection struct {
+	Src  string
+	Dsts []string
+}
+
+type generatedFile struct {
+	Package    string
+	Type       string
+	Pipe       string
+	StdImports []string
+	Imports    []string
+	Fields     []nodeField
+	Tagged     []taggedConnection
+}
+
+var fileTemplate = template.Must(template.New("pipes").Parse(`// Code generated by pipesgen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+{{- range .StdImports }}
+	{{ . }}
+{{- end }}
+{{ range .Imports }}
+	{{ . }}
+{{- end }}
+)
+{{ range .Fields }}
+// {{ .FuncName }} returns a pointer to the {{ .Name }} node field of {{ $.Type }}.
+func {{ .FuncName }}(n *{{ $.Type }}) *{{ .Type }} { return &n.{{ .Name }} }
+{{ end }}
+// NodeFields returns the names and addresses of the node fields of {{ .Type }}.
+func (n *{{ .Type }}) NodeFields() []{{ .Pipe }}.NodeField {
+	return []{{ .Pipe }}.NodeField{
+{{- range .Fields }}
+		{{ $.Pipe }}.Field("{{ .Name }}", &n.{{ .Name }}),
+{{- end }}
+	}
+}
+
+// NewNodes returns a new, empty instance of {{ .Type }}.
+func (n *{{ .Type }}) NewNodes() {{ .Pipe }}.NodesMap { return &{{ .Type }}{} }
+
+// ConnectTagged connects the nodes of {{ .Type }} according to their "pipes" struct tags.
+func (n *{{ .Type }}) ConnectTagged() {
+{{- range .Tagged }}
+	n.{{ .Src }}.SendTo({{ range $i, $dst := .Dsts }}{{ if $i }}, {{ end }}n.{{ $dst }}{{ end }})
+{{- end }}
+}
+
+// CheckNodes verifies that all the nodes of {{ .Type }} have been added to the pipeline Builder.
+// Optional nodes must be added with a provider that returns a nil function.
+func (n *{{ .Type }}) CheckNodes() error {
+	var missing []string
+{{- range .Fields }}
+	if n.{{ .Name }} == nil {
+		missing = append(missing, "{{ .Name }}")
+	}
+{{- end }}
+	if len(missing) > 0 {
+		return fmt.Errorf("{{ .Type }} nodes not added to the pipeline: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+`))
+
+// Generate parses the Go package in the provided directory and returns the source code of the
+// accessor functions and the NodeFields, NewNodes, ConnectTagged and CheckNodes methods for the
+// provided NodesMap struct type.
+// It returns an error if the connections declared in the struct tags or in the Connect method
+// send data to a node whose input type does not match the output type of the sender.
+func Generate(dir, typeName, prefix, outputFile string) ([]byte, error) {
+	fset := token.NewFileSet()
+	files, err := parsePackage(fset, dir, outputFile)
+	if err != nil {
+		return nil, err
+	}
+	file, structType, err := findStruct(files, typeName)
+	if err != nil {
+		return nil, err
+	}
+	pipeAlias := importAlias(file, pipeImportPath)
+	if pipeAlias == "" {
+		return nil, fmt.Errorf("%s: the file declaring %s does not import %s",
+			fset.Position(file.Pos()).Filename, typeName, pipeImportPath)
+	}
+
+	gen := generatedFile{Package: file.Name.Name, Type: typeName, Pipe: pipeAlias}
+	// packages that are referred from the fields types
+	usedPackages := map[string]struct{}{"fmt": {}, "strings": {}, pipeAlias: {}}
+	var connections []connection
+	for _, field := range structType.Fields.List {
+		fields, err := parseField(fset, field, prefix, pipeAlias, usedPackages)
+		if err != nil {
+			return nil, err
+		}
+		gen.Fields = append(gen.Fields, fields...)
+		tagged, err := parseTag(fset, field)
+		if err != nil {
+			return nil, err
+		}
+		for _, tc := range tagged {
+			gen.Tagged = append(gen.Tagged, tc)
+			for _, dst := range tc.Dsts {
+				connections = append(connections, connection{pos: fset.Position(field.Pos()), src: tc.Src, dst: dst})
+			}
+		}
+	}
+	if len(gen.Fields) == 0 {
+		return nil, fmt.Errorf("%s does not contain any %s node field", typeName, pipeImportPath)
+	}
+	connections = append(connections, connectCalls(fset, files, typeName, pipeAlias)...)
+	if err := resolveTypes(fset, files, typeName, gen.Fields); err != nil {
+		return nil, err
+	}
+	if err := validateConnections(gen.Fields, connections, pipeAlias); err != nil {
+		return nil, err
+	}
+	gen.StdImports, gen.Imports = imports(file, usedPackages)
+
+	var src bytes.Buffer
+	if err := fileTemplate.Execute(&src, gen); err != nil {
+		return nil, fmt.Errorf("generating code: %w", err)
+	}
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return formatted, nil
+}
+
+// parsePackage parses the non-test Go files of the directory, excluding the generator output file
+func parsePackage(fset *token.FileSet, dir, outputFile string) ([]*ast.File, error) {
+	goFiles, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, fmt.Errorf("listing Go files: %w", err)
+	}
+	var files []*ast.File
+	for _, goFile := range goFiles {
+		if strings.HasSuffix(goFile, "_test.go") || filepath.Base(goFile) == outputFile {
+			continue
+		}
+		src, err := os.ReadFile(goFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading source: %w", err)
+		}
+		file, err := parser.ParseFile(fset, goFile, src, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("parsing source: %w", err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// findStruct looks for the declaration of the provided struct type in the parsed files
+func findStruct(files []*ast.File, typeName string) (*ast.File, *ast.StructType, error) {
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.Name.Name != typeName {
+					continue
+				}
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok || ts.TypeParams != nil {
+					return nil, nil, fmt.Errorf("%s must be a non-generic struct type", typeName)
+				}
+				return file, st, nil
+			}
+		}
+	}
+	return nil, nil, errors.New("type " + typeName + " not found")
+}
+
+// parseField returns the node fields declared in the provided struct field, if it is a node,
+// adding the packages that its type refers to the usedPackages set
+func parseField(
+	fset *token.FileSet, field *ast.Field, prefix, pipeAlias string, usedPackages map[string]struct{},
+) ([]nodeField, error) {
+	kind, typeArgs := nodeType(field.Type, pipeAlias)
+	if kind == "" {
+		return nil, nil
+	}
+	typeSrc, err := source(fset, field.Type)
+	if err != nil {
+		return nil, fmt.Errorf("printing type of field %v: %w", field.Names, err)
+	}
+	ast.Inspect(field.Type, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok {
+				usedPackages[pkg.Name] = struct{}{}
+			}
+		}
+		return true
+	})
+	nf := nodeField{Kind: kind, Type: typeSrc}
+	// the type arguments are the output type for Start nodes, the input type for Final nodes,
+	// and the input and output types for Middle nodes
+	for i, arg := range typeArgs {
+		argSrc, err := source(fset, arg)
+		if err != nil {
+			return nil, fmt.Errorf("printing type of field %v: %w", field.Names, err)
+		}
+		switch {
+		case kind == "Start":
+			nf.Out = argSrc
+		case i == 0:
+			nf.In = argSrc
+		default:
+			nf.Out = argSrc
+		}
+	}
+	var fields []nodeField
+	for _, name := range field.Names {
+		nf.Name = name.Name
+		nf.FuncName = accessorName(prefix, name.Name)
+		fields = append(fields, nf)
+	}
+	return fields, nil
+}
+
+// parseTag returns the connections declared in the "pipes" struct tag of the field, if any
+func parseTag(fset *token.FileSet, field *ast.Field) ([]taggedConnection, error) {
+	if field.Tag == nil {
+		return nil, nil
+	}
+	tags, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid tag: %w", fset.Position(field.Pos()), err)
+	}
+	tag, ok := reflect.StructTag(tags).Lookup(connectTag)
+	if !ok {
+		return nil, nil
+	}
+	key, value, ok := strings.Cut(tag, "=")
+	if !ok || strings.TrimSpace(key) != "sendTo" {
+		return nil, fmt.Errorf(`%s: invalid tag %s:%q. Expecting %s:"sendTo=<fields>"`,
+			fset.Position(field.Pos()), connectTag, tag, connectTag)
+	}
+	var destinations []string
+	for _, dst := range strings.Split(value, ",") {
+		if dst = strings.TrimSpace(dst); dst != "" {
+			destinations = append(destinations, dst)
+		}
+	}
+	if len(destinations) == 0 {
+		return nil, fmt.Errorf("%s: sendTo tag does not specify any destination", fset.Position(field.Pos()))
+	}
+	var tagged []taggedConnection
+	for _, name := range field.Names {
+		tagged = append(tagged, taggedConnection{Src: name.Name, Dsts: destinations})
+	}
+	return tagged, nil
+}
+
+// connectCalls returns the connections between node fields that are declared by the
+// SendTo invocations in the Connect method of the NodesMap type
+func connectCalls(fset *token.FileSet, files []*ast.File, typeName, pipeAlias string) []connection {
+	var connections []connection
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Name.Name != "Connect" || fd.Body == nil || receiverType(fd) != typeName {
+				continue
+			}
+			recv := fd.Recv.List[0].Names[0].Name
+			ast.Inspect(fd.Body, func(node ast.Node) bool {
+				call, ok := node.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				src, deadLetters, ok := sendToSource(call, recv)
+				if !ok {
+					return true
+				}
+				for _, arg := range call.Args {
+					if dst := nodeArgument(arg, recv, pipeAlias); dst != "" {
+						connections = append(connections, connection{
+							pos: fset.Position(arg.Pos()), src: src, dst: dst, deadLetters: deadLetters,
+						})
+					}
+				}
+				return true
+			})
+		}
+	}
+	return connections
+}
+
+// receiverType returns the name of the receiver type of a method, or an empty string if the
+// function is not a method or its receiver is not named
+func receiverType(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) != 1 || len(fd.Recv.List[0].Names) != 1 {
+		return ""
+	}
+	recvType := fd.Recv.List[0].Type
+	if star, ok := recvType.(*ast.StarExpr); ok {
+		recvType = star.X
+	}
+	if ident, ok := recvType.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// sendToSource returns the node field of an invocation with the form recv.field.SendTo(...)
+// or recv.field.DeadLetters().SendTo(...)
+func sendToSource(call *ast.CallExpr, recv string) (field string, deadLetters bool, ok bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "SendTo" {
+		return "", false, false
+	}
+	if dlCall, ok := sel.X.(*ast.CallExpr); ok {
+		dlSel, ok := dlCall.Fun.(*ast.SelectorExpr)
+		if !ok || dlSel.Sel.Name != "DeadLetters" {
+			return "", false, false
+		}
+		field = fieldOf(dlSel.X, recv)
+		return field, true, field != ""
+	}
+	field = fieldOf(sel.X, recv)
+	return field, false, field != ""
+}
+
+// nodeArgument returns the node field passed as SendTo argument, unwrapping the receivers wrapped
+// by the Route, DefaultRoute, Cloned and Record functions of the pipe package
+func nodeArgument(arg ast.Expr, recv, pipeAlias string) string {
+	call, ok := arg.(*ast.CallExpr)
+	if !ok {
+		return fieldOf(arg, recv)
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if idx, ok := call.Fun.(*ast.IndexExpr); ok {
+		// explicit type argument, e.g. pipe.Route[int](...)
+		sel, _ = idx.X.(*ast.SelectorExpr)
+	}
+	if !ok || sel == nil || len(call.Args) == 0 {
+		return ""
+	}
+	if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != pipeAlias {
+		return ""
+	}
+	if _, ok := receiverWrappers[sel.Sel.Name]; !ok {
+		return ""
+	}
+	return nodeArgument(call.Args[len(call.Args)-1], recv, pipeAlias)
+}
+
+// fieldOf returns the field name of an expression with the form recv.field
+func fieldOf(expr ast.Expr, recv string) string {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	if ident, ok := sel.X.(*ast.Ident); !ok || ident.Name != recv {
+		return ""
+	}
+	return sel.Sel.Name
+}
+
+// resolveTypes type-checks the package to resolve the input and output types of the node fields,
+// so they are compared regardless of how they are written (e.g. any and interface{}, or the names
+// of the imported packages). The errors of the rest of the package are ignored, as it might refer to
+// the code that is going to be generated.
+func resolveTypes(fset *token.FileSet, files []*ast.File, typeName string, fields []nodeField) error {
+	var typeErr error
+	conf := types.Config{
+		Importer: sourceImporter,
+		Error: func(err error) {
+			if typeErr == nil {
+				typeErr = err
+			}
+		},
+	}
+	pkg, _ := conf.Check(files[0].Name.Name, fset, files, nil)
+	obj := pkg.Scope().Lookup(typeName)
+	if obj == nil {
+		return fmt.Errorf("resolving type %s: not found in package %s: %w", typeName, pkg.Name(), typeErr)
+	}
+	strct, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return fmt.Errorf("%s must be a non-generic struct type", typeName)
+	}
+	fieldTypes := make(map[string]types.Type, strct.NumFields())
+	for i := 0; i < strct.NumFields(); i++ {
+		fieldTypes[strct.Field(i).Name()] = strct.Field(i).Type()
+	}
+	for i := range fields {
+		f := &fields[i]
+		named, ok := fieldTypes[f.Name].(*types.Named)
+		if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != pipeImportPath {
+			return fmt.Errorf("resolving the type of field %s: %w", f.Name, typeErr)
+		}
+		args := named.TypeArgs()
+		switch {
+		case f.Kind == "Start":
+			f.out = args.At(0)
+		case args.Len() > 1:
+			f.in, f.out = args.At(0), args.At(1)
+		default:
+			f.in = args.At(0)
+		}
+	}
+	return nil
+}
+
+// validateConnections verifies that the connections are made from senders to receivers of
+// the same type
+func validateConnections(fields []nodeField, connections []connection, pipeAlias string) error {
+	nodes := make(map[string]nodeField, len(fields))
+	for _, f := range fields {
+		nodes[f.Name] = f
+	}
+	for _, c := range connections {
+		src, ok := nodes[c.src]
+		if !ok {
+			return fmt.Errorf("%s: %s is not a node field", c.pos, c.src)
+		}
+		dst, ok := nodes[c.dst]
+		if !ok {
+			return fmt.Errorf("%s: destination %s is not a node field", c.pos, c.dst)
+		}
+		out, sends := src.Out, src.out != nil
+		same := func() bool { return types.Identical(src.out, dst.in) }
+		if c.deadLetters {
+			if !strings.HasPrefix(src.Kind, "Failable") {
+				return fmt.Errorf("%s: %s is a %s node, which does not have dead letters", c.pos, c.src, src.Kind)
+			}
+			out, sends = pipeAlias+".DeadLetter["+src.In+"]", true
+			same = func() bool { return isDeadLetter(dst.in, src.in) }
+		}
+		switch {
+		case !sends:
+			return fmt.Errorf("%s: %s is a %s node, which can't send data", c.pos, c.src, src.Kind)
+		case dst.in == nil:
+			return fmt.Errorf("%s: %s is a %s node, which can't receive data", c.pos, c.dst, dst.Kind)
+		case !same():
+			return fmt.Errorf("%s: %s sends %s, but %s receives %s", c.pos, c.src, out, c.dst, dst.In)
+		}
+	}
+	return nil
+}
+
+// isDeadLetter returns true if the type is a pipe.DeadLetter of the provided item type
+func isDeadLetter(typ, item types.Type) bool {
+	named, ok := typ.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != pipeImportPath ||
+		named.Obj().Name() != "DeadLetter" || named.TypeArgs().Len() != 1 {
+		return false
+	}
+	return types.Identical(named.TypeArgs().At(0), item)
+}
+
+// importAlias returns the name that the file uses to refer the provided import path, or an
+// empty string if the path is not imported
+func importAlias(file *ast.File, path string) string {
+	for _, imp := range file.Imports {
+		if impPath, _ := strconv.Unquote(imp.Path.Value); impPath == path {
+			if imp.Name != nil {
+				return imp.Name.Name
+			}
+			return filepath.Base(path)
+		}
+	}
+	return ""
+}
+
+// nodeType returns the name of the pipe package node type of the field, as well as its type
+// arguments. It returns an empty name if the field is not a node
+func nodeType(fieldType ast.Expr, pipeAlias string) (string, []ast.Expr) {
+	var genericType ast.Expr
+	var typeArgs []ast.Expr
+	switch ft := fieldType.(type) {
+	case *ast.IndexExpr:
+		genericType, typeArgs = ft.X, []ast.Expr{ft.Index}
+	case *ast.IndexListExpr:
+		genericType, typeArgs = ft.X, ft.Indices
+	default:
+		return "", nil
+	}
+	sel, ok := genericType.(*ast.SelectorExpr)
+	if !ok {
+		return "", nil
+	}
+	if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != pipeAlias {
+		return "", nil
+	}
+	if _, ok = nodeTypes[sel.Sel.Name]; !ok {
+		return "", nil
+	}
+	return sel.Sel.Name, typeArgs
+}
+
+// source returns the formatted source code of an expression
+func source(fset *token.FileSet, expr ast.Expr) (string, error) {
+	var src bytes.Buffer
+	if err := format.Node(&src, fset, expr); err != nil {
+		return "", err
+	}
+	return src.String(), nil
+}
+
+// imports returns the import declarations for the used packages, grouped as
+// standard library and non-standard library imports
+func imports(file *ast.File, usedPackages map[string]struct{}) (std, nonStd []string) {
+	declared := map[string]struct{}{}
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := filepath.Base(path)
+		decl := imp.Path.Value
+		if imp.Name != nil {
+			name = imp.Name.Name
+			decl = imp.Name.Name + " " + decl
+		}
+		if _, ok := usedPackages[name]; !ok {
+			continue
+		}
+		declared[name] = struct{}{}
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			nonStd = append(nonStd, decl)
+		} else {
+			std = append(std, decl)
+		}
+	}
+	// packages used by the generated code, not imported by the source file
+	for _, pkg := range []string{"fmt", "strings"} {
+		if _, ok := declared[pkg]; !ok {
+			std = append(std, strconv.Quote(pkg))
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(nonStd)
+	return std, nonStd
+}
+
+func accessorName(prefix, fieldName string) string {
+	name := prefix + fieldName + "Ptr"
+	return strings.ToLower(name[:1]) + name[1:]
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	src, err := os.ReadFile(filepath.Join("testdata", "nodes.go.txt"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nodes.go"), src, 0o600))
+	golden, err := os.ReadFile(filepath.Join("testdata", "nodes_pipes.go.golden"))
+	require.NoError(t, err)
+
+	generated, err := Generate(dir, "SampleNodes", "s", "nodes_pipes.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(golden), string(generated))
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nodes.go"), []byte(`package sample
+
+import "github.com/mariomac/pipes/pipe"
+
+type Nodes struct {
+	start pipe.Start[int]
+}
+
+type NoNodes struct {
+	foo int
+}
+
+type NotAStruct int
+`), 0o600))
+
+	_, err := Generate(dir, "Unexisting", "", "out.go")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "type Unexisting not found")
+	_, err = Generate(dir, "NoNodes", "", "out.go")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not contain any")
+	_, err = Generate(dir, "NotAStruct", "", "out.go")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must be a non-generic struct type")
+	_, err = Generate(dir, "Nodes", "", "out.go")
+	assert.NoError(t, err)
+}
+
+func TestGenerate_TypeNotResolved(t *testing.T) {
+	dir := t.TempDir()
+	// the file declaring the type belongs to a different package than the first parsed file
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a_tool.go"), []byte(`//go:build ignore
+
+package main
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nodes.go"), []byte(`package sample
+
+import "github.com/mariomac/pipes/pipe"
+
+type Nodes struct {
+	start pipe.Start[int]
+}
+`), 0o600))
+
+	_, err := Generate(dir, "Nodes", "", "out.go")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "resolving type Nodes: not found in package main")
+}
+
+func TestGenerate_IdenticalTypes(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nodes.go"), []byte(`package sample
+
+import (
+	stdtime "time"
+
+	"github.com/mariomac/pipes/pipe"
+)
+
+type Nodes struct {
+	anything pipe.Start[interface{}] `+"`pipes:\"sendTo=any\"`"+`
+	any      pipe.Final[any]
+	clock    pipe.Start[stdtime.Time]
+	stamps   pipe.Final[Timestamp]
+}
+
+func (n *Nodes) Connect() {
+	n.clock.SendTo(n.stamps)
+}
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "types.go"), []byte(`package sample
+
+import "time"
+
+type Timestamp = time.Time
+`), 0o600))
+
+	// the types are compared by their identity, not by their source code
+	_, err := Generate(dir, "Nodes", "", "out.go")
+	assert.NoError(t, err)
+}
+
+func TestGenerate_InvalidConnections(t *testing.T) {
+	type testCase struct {
+		name       string
+		fields     string
+		connect    string
+		errMessage string
+	}
+	for _, tc := range []testCase{{
+		name:       "tag type mismatch",
+		fields:     "src pipe.Start[int] `pipes:\"sendTo=dst\"`\n\tdst pipe.Final[string]",
+		errMessage: "src sends int, but dst receives string",
+	}, {
+		name:       "connect type mismatch",
+		fields:     "src pipe.Start[int]\n\tdst pipe.Final[string]",
+		connect:    "n.src.SendTo(n.dst)",
+		errMessage: "src sends int, but dst receives string",
+	}, {
+		name:       "wrapped receiver type mismatch",
+		fields:     "src pipe.Start[int]\n\tdst pipe.Final[string]",
+		connect:    "n.src.SendTo(pipe.DefaultRoute(n.dst))",
+		errMessage: "src sends int, but dst receives string",
+	}, {
+		name:       "dead letters type mismatch",
+		fields:     "src pipe.FailableFinal[int]\n\tdst pipe.Final[pipe.DeadLetter[string]]",
+		connect:    "n.src.DeadLetters().SendTo(n.dst)",
+		errMessage: "src sends pipe.DeadLetter[int], but dst receives pipe.DeadLetter[string]",
+	}, {
+		name:       "no dead letters",
+		fields:     "src pipe.Middle[int, int]\n\tdst pipe.Final[pipe.DeadLetter[int]]",
+		connect:    "n.src.DeadLetters().SendTo(n.dst)",
+		errMessage: "src is a Middle node, which does not have dead letters",
+	}, {
+		name:       "sending from a Final node",
+		fields:     "src pipe.Final[int]\n\tdst pipe.Final[int]",
+		connect:    "n.src.SendTo(n.dst)",
+		errMessage: "src is a Final node, which can't send data",
+	}, {
+		name:       "sending to a Start node",
+		fields:     "src pipe.Start[int] `pipes:\"sendTo=dst\"`\n\tdst pipe.Start[int]",
+		errMessage: "dst is a Start node, which can't receive data",
+	}, {
+		name:       "unknown destination",
+		fields:     "src pipe.Start[int] `pipes:\"sendTo=dst\"`",
+		errMessage: "destination dst is not a node field",
+	}, {
+		name:       "invalid tag",
+		fields:     "src pipe.Start[int] `pipes:\"dst\"`",
+		errMessage: "invalid tag",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "nodes.go"), []byte(`package sample
+
+import "github.com/mariomac/pipes/pipe"
+
+type Nodes struct {
+	`+tc.fields+`
+}
+
+func (n *Nodes) Connect() {
+	`+tc.connect+`
+}
+`), 0o600))
+			_, err := Generate(dir, "Nodes", "", "out.go")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.errMessage)
+			assert.Contains(t, err.Error(), "nodes.go:")
+		})
+	}
+}
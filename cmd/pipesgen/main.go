@@ -0,0 +1,58 @@
+// Command pipesgen generates, for a given NodesMap struct, the accessor functions that return
+// pointers to each node field (to be passed to pipe.AddStart, pipe.AddMiddleProvider, etc...),
+// as well as a CheckNodes method that verifies that all the nodes have been added to the
+// pipe.Builder before invoking the NodesMap Connect method. Optional nodes must be also added,
+// with a provider that returns a nil function (e.g. pipe.AddFinalProvider with a provider that
+// returns a nil pipe.FinalFunc when the node is disabled by the configuration).
+//
+// It also generates the NodeFields, NewNodes and ConnectTagged methods, so the pipe.Builder does
+// not need reflection to name the nodes, to create new NodesMap instances for sub-pipelines, and
+// to connect the nodes declared with "pipes" struct tags. pipesgen fails if any connection from
+// the struct tags or the SendTo invocations of the Connect method sends data to a node whose input
+// type differs from the output type of the sender. The types are compared by the Go type checker,
+// which loads the imported packages from the module of the current directory.
+//
+// Usage, from a go:generate directive in the same package as the NodesMap struct:
+//
+//	//go:generate go run github.com/mariomac/pipes/cmd/pipesgen -type MyNodes
+//
+// For each node field, pipesgen generates a function named as the field plus the "Ptr" suffix,
+// prefixed by the optional -prefix argument, e.g.:
+//
+//	func ingestPtr(n *MyNodes) *pipe.Start[string] { return &n.ingest }
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("pipesgen: ")
+	typeName := flag.String("type", "", "name of the NodesMap struct type (required)")
+	prefix := flag.String("prefix", "", "prefix of the generated accessor function names")
+	output := flag.String("output", "", "output file name. Default: <type>_pipes.go, in lowercase")
+	dir := flag.String("dir", ".", "directory of the package containing the NodesMap struct")
+	flag.Parse()
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output == "" {
+		*output = strings.ToLower(*typeName) + "_pipes.go"
+	}
+	outPath := filepath.Join(*dir, *output)
+
+	src, err := Generate(*dir, *typeName, *prefix, filepath.Base(outPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(outPath, src, 0o644); err != nil { //nolint:gosec
+		log.Fatal(fmt.Errorf("writing output: %w", err))
+	}
+}
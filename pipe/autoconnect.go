@@ -0,0 +1,147 @@
+package pipe
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+const connectTag = "pipes"
+
+// AutoConnect can be embedded into a NodesMap struct whose connections are completely
+// defined by means of "pipes" struct tags, so it doesn't need to implement its own
+// Connect method:
+//
+//	type MiniGrepNodes struct {
+//		pipe.AutoConnect
+//		fileFinder  pipe.Start[*os.File]              `pipes:"sendTo=fileScanner"`
+//		fileScanner pipe.Middle[*os.File, FileLine]   `pipes:"sendTo=matchFilter"`
+//		matchFilter pipe.Middle[FileLine, FileLine]   `pipes:"sendTo=printer,store"`
+//		printer     pipe.Final[FileLine]
+//		store       pipe.Final[FileLine]
+//	}
+//
+// The "sendTo" property of the tag lists the names of the NodesMap fields that the
+// tagged node sends data to. The Builder connects them, validating that the output
+// type of the node matches the input type of its destinations, before invoking the
+// Connect method of the NodesMap. If the NodesMap implements its own Connect method,
+// it will be invoked after connecting the tagged nodes, so both connection modes can
+// be combined.
+//
+// The tags are inspected by reflection, once for each NodesMap type. The pipesgen tool
+// (github.com/mariomac/pipes/cmd/pipesgen) generates a ConnectTagged method that connects the
+// tagged nodes without reflection, and validates the connections at generation time.
+type AutoConnect struct{}
+
+// Connect does nothing, as the nodes are connected by the Builder according to the
+// NodesMap struct tags.
+func (AutoConnect) Connect() {}
+
+// taggedField is a NodesMap field with a "pipes" struct tag
+type taggedField struct {
+	name         string
+	destinations []string
+}
+
+// taggedFields caches the tagged fields of each NodesMap type, so they are looked up only once
+var taggedFields sync.Map // map[reflect.Type][]taggedField
+
+// connectTagged connects the nodes of the NodesMap according to the "pipes" tags of its fields.
+// It's only invoked for the NodesMap types that don't implement TagConnector.
+func connectTagged(nodesMap any) error {
+	nm := reflect.ValueOf(nodesMap)
+	if nm.Kind() != reflect.Pointer || nm.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	nm = nm.Elem()
+	fields, err := lookupTaggedFields(nm.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if err := connectField(nm, field.name, field.destinations); err != nil {
+			return fmt.Errorf("field %s: %w", field.name, err)
+		}
+	}
+	return nil
+}
+
+func lookupTaggedFields(nmType reflect.Type) ([]taggedField, error) {
+	if fields, ok := taggedFields.Load(nmType); ok {
+		return fields.([]taggedField), nil
+	}
+	var fields []taggedField
+	for i := 0; i < nmType.NumField(); i++ {
+		tag, ok := nmType.Field(i).Tag.Lookup(connectTag)
+		if !ok {
+			continue
+		}
+		name := nmType.Field(i).Name
+		destinations, err := parseSendTo(tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		fields = append(fields, taggedField{name: name, destinations: destinations})
+	}
+	taggedFields.Store(nmType, fields)
+	return fields, nil
+}
+
+// parseSendTo returns the destination fields from a tag with the form sendTo=field1,field2,...
+func parseSendTo(tag string) ([]string, error) {
+	key, value, ok := strings.Cut(tag, "=")
+	if !ok || strings.TrimSpace(key) != "sendTo" {
+		return nil, fmt.Errorf(`invalid tag %s:%q. Expecting %s:"sendTo=<fields>"`, connectTag, tag, connectTag)
+	}
+	var destinations []string
+	for _, dst := range strings.Split(value, ",") {
+		if dst = strings.TrimSpace(dst); dst != "" {
+			destinations = append(destinations, dst)
+		}
+	}
+	if len(destinations) == 0 {
+		return nil, errors.New("sendTo tag does not specify any destination")
+	}
+	return destinations, nil
+}
+
+func connectField(nm reflect.Value, srcName string, destinations []string) error {
+	src := accessibleField(nm, srcName)
+	if src.Kind() != reflect.Interface {
+		return fmt.Errorf("expecting a node. Got %s", src.Type())
+	}
+	if src.IsNil() {
+		return errors.New("node not added to the pipeline")
+	}
+	sendTo := src.MethodByName("SendTo")
+	if !sendTo.IsValid() {
+		return fmt.Errorf("%s can't send data to other nodes", src.Type())
+	}
+	// SendTo(...Receiver[OUT])
+	receiverType := sendTo.Type().In(0).Elem()
+	receivers := make([]reflect.Value, 0, len(destinations))
+	for _, dstName := range destinations {
+		if _, ok := nm.Type().FieldByName(dstName); !ok {
+			return fmt.Errorf("destination field %s does not exist", dstName)
+		}
+		dst := accessibleField(nm, dstName)
+		if !dst.Type().AssignableTo(receiverType) {
+			return fmt.Errorf("can't send data to %s: expecting %s. Got %s", dstName, receiverType, dst.Type())
+		}
+		if dst.IsNil() {
+			return fmt.Errorf("destination %s not added to the pipeline", dstName)
+		}
+		receivers = append(receivers, dst)
+	}
+	sendTo.Call(receivers)
+	return nil
+}
+
+// accessibleField returns the value of a struct field, even if it's unexported
+func accessibleField(strct reflect.Value, name string) reflect.Value {
+	field := strct.FieldByName(name)
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+}
@@ -0,0 +1,174 @@
+package pipe_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+type taggedPipe struct {
+	pipe.AutoConnect
+	counter pipe.Start[int]       `pipes:"sendTo=doubler, formatter"`
+	doubler pipe.Middle[int, int] `pipes:"sendTo=formatter"`
+	// formatter connection is defined in both the tag and the Connect method
+	formatter pipe.Middle[int, string] `pipes:"sendTo=collector"`
+	collector pipe.Final[string]
+}
+
+func TestAutoConnect(t *testing.T) {
+	b := pipe.NewBuilder(&taggedPipe{})
+	pipe.AddStart(b, func(p *taggedPipe) *pipe.Start[int] { return &p.counter }, Counter(1, 3))
+	pipe.AddMiddle(b, func(p *taggedPipe) *pipe.Middle[int, int] { return &p.doubler },
+		func(in <-chan int, out chan<- int) {
+			for i := range in {
+				out <- i * 2
+			}
+		})
+	pipe.AddMiddle(b, func(p *taggedPipe) *pipe.Middle[int, string] { return &p.formatter },
+		func(in <-chan int, out chan<- string) {
+			for i := range in {
+				out <- string(rune('a' + i))
+			}
+		})
+	var collected []string
+	pipe.AddFinal(b, func(p *taggedPipe) *pipe.Final[string] { return &p.collector }, collectInto(&collected))
+
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	sort.Strings(collected)
+	assert.Equal(t, []string{"b", "c", "c", "d", "e", "g"}, collected)
+}
+
+// mixedPipe connects a node by tags and another by the Connect method
+type mixedPipe struct {
+	start pipe.Start[int] `pipes:"sendTo=final"`
+	final pipe.Final[int]
+	other pipe.Final[int]
+}
+
+func (m *mixedPipe) Connect() {
+	m.start.SendTo(m.other)
+}
+
+func TestAutoConnect_MixedWithConnect(t *testing.T) {
+	b := pipe.NewBuilder(&mixedPipe{})
+	pipe.AddStart(b, func(p *mixedPipe) *pipe.Start[int] { return &p.start }, Counter(1, 3))
+	var final, other []int
+	pipe.AddFinal(b, func(p *mixedPipe) *pipe.Final[int] { return &p.final }, collectInto(&final))
+	pipe.AddFinal(b, func(p *mixedPipe) *pipe.Final[int] { return &p.other }, collectInto(&other))
+
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.Equal(t, []int{1, 2, 3}, final)
+	assert.Equal(t, []int{1, 2, 3}, other)
+}
+
+type wrongTypePipe struct {
+	pipe.AutoConnect
+	start pipe.Start[int] `pipes:"sendTo=final"`
+	final pipe.Final[string]
+}
+
+type unknownDstPipe struct {
+	pipe.AutoConnect
+	start pipe.Start[int] `pipes:"sendTo=fnal"`
+	final pipe.Final[int]
+}
+
+type missingNodePipe struct {
+	pipe.AutoConnect
+	start pipe.Start[int] `pipes:"sendTo=final"`
+	final pipe.Final[int]
+}
+
+type wrongTagPipe struct {
+	pipe.AutoConnect
+	start pipe.Start[int] `pipes:"to=final"`
+	final pipe.Final[int]
+}
+
+func TestAutoConnect_Errors(t *testing.T) {
+	t.Run("type mismatch", func(t *testing.T) {
+		b := pipe.NewBuilder(&wrongTypePipe{})
+		pipe.AddStart(b, func(p *wrongTypePipe) *pipe.Start[int] { return &p.start }, Counter(1, 3))
+		pipe.AddFinal(b, func(p *wrongTypePipe) *pipe.Final[string] { return &p.final }, func(in <-chan string) {})
+		_, err := b.Build()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "field start: can't send data to final")
+	})
+	t.Run("unknown destination", func(t *testing.T) {
+		b := pipe.NewBuilder(&unknownDstPipe{})
+		pipe.AddStart(b, func(p *unknownDstPipe) *pipe.Start[int] { return &p.start }, Counter(1, 3))
+		pipe.AddFinal(b, func(p *unknownDstPipe) *pipe.Final[int] { return &p.final }, func(in <-chan int) {})
+		_, err := b.Build()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "destination field fnal does not exist")
+	})
+	t.Run("destination not added", func(t *testing.T) {
+		b := pipe.NewBuilder(&missingNodePipe{})
+		pipe.AddStart(b, func(p *missingNodePipe) *pipe.Start[int] { return &p.start }, Counter(1, 3))
+		_, err := b.Build()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "destination final not added to the pipeline")
+	})
+	t.Run("invalid tag", func(t *testing.T) {
+		b := pipe.NewBuilder(&wrongTagPipe{})
+		pipe.AddStart(b, func(p *wrongTagPipe) *pipe.Start[int] { return &p.start }, Counter(1, 3))
+		pipe.AddFinal(b, func(p *wrongTagPipe) *pipe.Final[int] { return &p.final }, func(in <-chan int) {})
+		_, err := b.Build()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid tag")
+	})
+}
+
+// generatedPipe implements the methods generated by pipesgen, so the Builder neither inspects
+// its tags nor its fields by reflection
+type generatedPipe struct {
+	start pipe.Start[int] `pipes:"sendTo=this tag is ignored"`
+	final pipe.Final[int]
+}
+
+func (g *generatedPipe) Connect() {}
+
+func (g *generatedPipe) NodeFields() []pipe.NodeField {
+	return []pipe.NodeField{pipe.Field("theStart", &g.start), pipe.Field("theFinal", &g.final)}
+}
+
+func (g *generatedPipe) ConnectTagged() {
+	g.start.SendTo(g.final)
+}
+
+func (g *generatedPipe) NewNodes() pipe.NodesMap { return &generatedPipe{} }
+
+func TestAutoConnect_Generated(t *testing.T) {
+	b := pipe.NewBuilder(&generatedPipe{})
+	startPtr := func(p *generatedPipe) *pipe.Start[int] { return &p.start }
+	pipe.AddStart(b, startPtr, Counter(1, 3))
+	var collected []int
+	pipe.AddFinal(b, func(p *generatedPipe) *pipe.Final[int] { return &p.final }, collectInto(&collected))
+
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.Equal(t, []int{1, 2, 3}, collected)
+
+	// the names are provided by the NodeFields method
+	pipe.AddStartProvider(b, startPtr, func() (pipe.StartFunc[int], error) {
+		return nil, StartError{}
+	})
+	_, err = b.BuildWith(&generatedPipe{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "theStart")
+}
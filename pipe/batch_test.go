@@ -0,0 +1,105 @@
+package pipe_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+type batchPipe struct {
+	start  pipe.Start[int]
+	double pipe.Middle[int, int]
+	mid    pipe.Middle[int, int]
+	f1, f2 pipe.Final[int]
+}
+
+func (b *batchPipe) Connect() {
+	b.start.SendTo(b.double)
+	b.double.SendTo(b.mid)
+	b.mid.SendTo(b.f1, b.f2)
+}
+
+func (b *batchPipe) startPtr() *pipe.Start[int]        { return &b.start }
+func (b *batchPipe) doublePtr() *pipe.Middle[int, int] { return &b.double }
+func (b *batchPipe) midPtr() *pipe.Middle[int, int]    { return &b.mid }
+func (b *batchPipe) f1Ptr() *pipe.Final[int]           { return &b.f1 }
+func (b *batchPipe) f2Ptr() *pipe.Final[int]           { return &b.f2 }
+
+func TestBatching(t *testing.T) {
+	// all the connections are batched: from a Start function, from a Map node
+	// and from a fan-out
+	p := pipe.NewBuilder(&batchPipe{}, pipe.Batching(3, 0))
+	pipe.AddStart(p, (*batchPipe).startPtr, Counter(1, 7))
+	pipe.AddMap(p, (*batchPipe).doublePtr, func(i int) int { return 2 * i })
+	pipe.AddMiddle(p, (*batchPipe).midPtr, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i + 1
+		}
+	})
+	var items1, items2 []int
+	pipe.AddFinal(p, (*batchPipe).f1Ptr, collectInto(&items1))
+	pipe.AddFinal(p, (*batchPipe).f2Ptr, collectInto(&items2))
+	r, err := p.Build()
+	require.NoError(t, err)
+	require.NoError(t, r.Run())
+
+	// the last incomplete batches are forwarded when the senders finish
+	assert.Equal(t, []int{3, 5, 7, 9, 11, 13, 15}, items1)
+	assert.Equal(t, items1, items2)
+	testers.VerifyNoLeaks(t, r)
+}
+
+type batchLatencyPipe struct {
+	start pipe.Start[int]
+	final pipe.Final[int]
+}
+
+func (b *batchLatencyPipe) Connect() {
+	b.start.SendTo(b.final)
+}
+
+func TestBatching_FlushLatency(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+	input := make(chan int)
+	received := make(chan int, 10)
+	p := pipe.NewBuilder(&batchLatencyPipe{}, pipe.WithClock(clock), pipe.Batching(3, time.Second))
+	pipe.AddStart(p, func(b *batchLatencyPipe) *pipe.Start[int] { return &b.start }, func(out chan<- int) {
+		for i := range input {
+			out <- i
+		}
+	})
+	pipe.AddFinal(p, func(b *batchLatencyPipe) *pipe.Final[int] { return &b.final }, func(in <-chan int) {
+		for i := range in {
+			received <- i
+		}
+	})
+	r, err := p.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	// the incomplete batch is forwarded after the flush latency
+	input <- 1
+	clock.BlockUntil(1)
+	clock.Advance(999 * time.Millisecond)
+	assert.Empty(t, received)
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, 1, testers.ReadChannel(t, received, timeout))
+
+	// full batches are forwarded without waiting for the flush latency
+	input <- 2
+	input <- 3
+	input <- 4
+	for i := 2; i <= 4; i++ {
+		assert.Equal(t, i, testers.ReadChannel(t, received, timeout))
+	}
+
+	input <- 5
+	close(input)
+	assert.Equal(t, 5, testers.ReadChannel(t, received, timeout))
+	testers.ReadChannel(t, r.Done(), timeout)
+}
@@ -0,0 +1,205 @@
+package pipe_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mariomac/pipes/pipe"
+)
+
+var benchBufferLens = []int{0, 10, 100}
+
+type benchTransport struct {
+	name string
+	opts []pipe.Option
+}
+
+// benchBatchSize is the number of items of each batch in the batched connections
+const benchBatchSize = 64
+
+// benchTransports returns the connection transports that are compared for a given buffer length.
+// Ring buffers can't be unbuffered, so they are only compared for buffered connections.
+// Map and Filter nodes are not fused in the batched connections, so they consume the batches directly.
+func benchTransports(bufLen int) []benchTransport {
+	transports := []benchTransport{{name: "chan", opts: []pipe.Option{pipe.ChannelBufferLen(bufLen)}}}
+	if bufLen > 0 {
+		transports = append(transports, benchTransport{
+			name: "ring", opts: []pipe.Option{pipe.ChannelBufferLen(bufLen), pipe.RingBuffer(bufLen)},
+		})
+	}
+	return append(transports, benchTransport{
+		name: "batch", opts: []pipe.Option{pipe.ChannelBufferLen(bufLen), pipe.Batching(benchBatchSize, 0), pipe.Unfused()},
+	})
+}
+
+type benchChain struct {
+	start  pipe.Start[int]
+	m1, m2 pipe.Middle[int, int]
+	m3, m4 pipe.Middle[int, int]
+	final  pipe.Final[int]
+}
+
+func (c *benchChain) Connect() {
+	c.start.SendTo(c.m1)
+	c.m1.SendTo(c.m2)
+	c.m2.SendTo(c.m3)
+	c.m3.SendTo(c.m4)
+	c.m4.SendTo(c.final)
+}
+
+func (c *benchChain) middles() []pipe.MiddlePtr[*benchChain, int, int] {
+	return []pipe.MiddlePtr[*benchChain, int, int]{
+		func(c *benchChain) *pipe.Middle[int, int] { return &c.m1 },
+		func(c *benchChain) *pipe.Middle[int, int] { return &c.m2 },
+		func(c *benchChain) *pipe.Middle[int, int] { return &c.m3 },
+		func(c *benchChain) *pipe.Middle[int, int] { return &c.m4 },
+	}
+}
+
+func BenchmarkChain(b *testing.B) {
+	for _, bufLen := range benchBufferLens {
+		for _, tr := range benchTransports(bufLen) {
+			b.Run(fmt.Sprintf("middle/%s/buf=%d", tr.name, bufLen), func(b *testing.B) {
+				nodes := &benchChain{}
+				p := pipe.NewBuilder(nodes, tr.opts...)
+				for _, mid := range nodes.middles() {
+					pipe.AddMiddle(p, mid, func(in <-chan int, out chan<- int) {
+						for i := range in {
+							out <- i + 1
+						}
+					})
+				}
+				runBenchChain(b, p)
+			})
+			// Map nodes are fused when connected through channels, and run in their own
+			// goroutine when connected through ring buffers
+			b.Run(fmt.Sprintf("map/%s/buf=%d", tr.name, bufLen), func(b *testing.B) {
+				runBenchMapChain(b, tr.opts...)
+			})
+		}
+		// unfused Map nodes connected through channels, as the baseline of the ring buffers,
+		// which run the same number of goroutines
+		b.Run(fmt.Sprintf("map/chan-unfused/buf=%d", bufLen), func(b *testing.B) {
+			runBenchMapChain(b, pipe.ChannelBufferLen(bufLen), pipe.Unfused())
+		})
+	}
+}
+
+func runBenchMapChain(b *testing.B, opts ...pipe.Option) {
+	nodes := &benchChain{}
+	p := pipe.NewBuilder(nodes, opts...)
+	for _, mid := range nodes.middles() {
+		pipe.AddMap(p, mid, func(i int) int { return i + 1 })
+	}
+	runBenchChain(b, p)
+}
+
+func runBenchChain(b *testing.B, p *pipe.Builder[*benchChain]) {
+	pipe.AddStart(p, func(c *benchChain) *pipe.Start[int] { return &c.start }, benchCounter(b.N))
+	pipe.AddFinal(p, func(c *benchChain) *pipe.Final[int] { return &c.final }, benchDiscard)
+	runBench(b, p.Build)
+}
+
+type benchFanOut struct {
+	start          pipe.Start[int]
+	f1, f2, f3, f4 pipe.Final[int]
+}
+
+func (f *benchFanOut) Connect() {
+	f.start.SendTo(f.f1, f.f2, f.f3, f.f4)
+}
+
+func BenchmarkFanOut(b *testing.B) {
+	for _, bufLen := range benchBufferLens {
+		for _, tr := range benchTransports(bufLen) {
+			b.Run(fmt.Sprintf("%s/buf=%d", tr.name, bufLen), func(b *testing.B) {
+				p := pipe.NewBuilder(&benchFanOut{}, tr.opts...)
+				pipe.AddStart(p, func(f *benchFanOut) *pipe.Start[int] { return &f.start }, benchCounter(b.N))
+				for _, final := range []pipe.FinalPtr[*benchFanOut, int]{
+					func(f *benchFanOut) *pipe.Final[int] { return &f.f1 },
+					func(f *benchFanOut) *pipe.Final[int] { return &f.f2 },
+					func(f *benchFanOut) *pipe.Final[int] { return &f.f3 },
+					func(f *benchFanOut) *pipe.Final[int] { return &f.f4 },
+				} {
+					pipe.AddFinal(p, final, benchDiscard)
+				}
+				runBench(b, p.Build)
+			})
+		}
+	}
+}
+
+type benchFanIn struct {
+	s1, s2, s3, s4 pipe.Start[int]
+	maps           [4]pipe.Middle[int, int]
+	final          pipe.Final[int]
+}
+
+func (f *benchFanIn) Connect() {
+	for i, s := range []pipe.Start[int]{f.s1, f.s2, f.s3, f.s4} {
+		s.SendTo(f.maps[i])
+		f.maps[i].SendTo(f.final)
+	}
+}
+
+// BenchmarkFanIn compares the transports of the connections from multiple Start nodes to the Map
+// nodes that forward their data to the same Final node. The input of the Final node is always a
+// channel, as ring buffers only support a single sender.
+func BenchmarkFanIn(b *testing.B) {
+	for _, bufLen := range benchBufferLens {
+		for _, tr := range benchTransports(bufLen) {
+			b.Run(fmt.Sprintf("%s/buf=%d", tr.name, bufLen), func(b *testing.B) {
+				p := pipe.NewBuilder(&benchFanIn{}, tr.opts...)
+				// all the start nodes wait until the pipeline is completely started
+				started := make(chan struct{})
+				for i, start := range []pipe.StartPtr[*benchFanIn, int]{
+					func(f *benchFanIn) *pipe.Start[int] { return &f.s1 },
+					func(f *benchFanIn) *pipe.Start[int] { return &f.s2 },
+					func(f *benchFanIn) *pipe.Start[int] { return &f.s3 },
+					func(f *benchFanIn) *pipe.Start[int] { return &f.s4 },
+				} {
+					count := benchCounter(b.N / 4)
+					pipe.AddStart(p, start, func(out chan<- int) {
+						<-started
+						count(out)
+					})
+					pipe.AddMap(p, benchFanInMap(i), func(i int) int { return i + 1 })
+				}
+				pipe.AddFinal(p, func(f *benchFanIn) *pipe.Final[int] { return &f.final }, benchDiscard)
+				runBench(b, p.Build, func() { close(started) })
+			})
+		}
+	}
+}
+
+func benchFanInMap(i int) pipe.MiddlePtr[*benchFanIn, int, int] {
+	return func(f *benchFanIn) *pipe.Middle[int, int] { return &f.maps[i] }
+}
+
+func runBench(b *testing.B, build func() (*pipe.Runner, error), afterStart ...func()) {
+	b.Helper()
+	r, err := build()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	r.Start()
+	for _, fn := range afterStart {
+		fn()
+	}
+	<-r.Done()
+}
+
+func benchCounter(n int) pipe.StartFunc[int] {
+	return func(out chan<- int) {
+		for i := 0; i < n; i++ {
+			out <- i
+		}
+	}
+}
+
+func benchDiscard(in <-chan int) {
+	for range in {
+	}
+}
@@ -1,49 +1,97 @@
 package pipe
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"sync"
+	"unsafe"
+
+	"github.com/mariomac/pipes/pipe/internal/connect"
 )
 
 type startable interface {
-	Start()
+	// startGated starts the node. If the node is pausable, its output is forwarded only while
+	// the passed gate is open. The context is cancelled when the Runner is shut down.
+	startGated(ctx context.Context, gate *connect.Gate)
+	isPausable() bool
+	isStoppable() bool
 }
 
 type doneable interface {
 	Done() <-chan struct{}
 }
 
+// inspectable nodes report their processing status, to diagnose which nodes are busy
+type inspectable interface {
+	// isRunning returns true if the node function has been started and hasn't returned yet
+	isRunning() bool
+	// bufferedItems returns the number of items that are waiting in the input channel of the node
+	bufferedItems() int
+	// track makes the node start its goroutines through the passed Tracker, under the given name
+	track(tracker *connect.Tracker, name string)
+	// input returns the Joiner of the node input channel, or nil if the node has no inputs
+	input() any
+	// destinations returns the Joiners of the input channels of the nodes that receive data from this node
+	destinations() []any
+	// watch makes the node receive its input through a Probe, which is returned. It returns nil
+	// if the node has no inputs. It must be invoked after track and before starting the node
+	watch() *connect.Probe
+}
+
 // Builder provides tools and functions to create a pipeline and add nodes and node providers to it.
 type Builder[IMPL NodesMap] struct {
 	nodesMap IMPL
 	opts     []Option
+	// namePrefix is prepended to the names of the nodes, to nest them under the name of the
+	// parent node when the pipeline is added as a sub-pipeline of another Builder
+	namePrefix string
+	// names of the NodesMap node fields, indexed by their address. They are
+	// calculated only once, as this might require reflection
+	names     map[uintptr]string
+	namesOnce sync.Once
 
-	// startNodes and finalNodes are stored by the uintptr of the destination field
-	// in the NodesMap implementation.
+	// startNodes, middleNodes and finalNodes are stored by the uintptr of the destination field
+	// in the NodesMap implementation passed to NewBuilder.
 	// this way we make sure that we can assign a node to a field twice and only
 	// tha last change will prevail, without leaving lost startnodes around there
-	startNodes map[uintptr]nodeOrProvider[startable]
-	// in middle nodes, we only care about providers and we don't really care about the node implementation
-	middleNodes map[uintptr]nodeOrProvider[struct{}]
-	finalNodes  map[uintptr]nodeOrProvider[doneable]
-}
+	startNodes map[uintptr]nodeInstantiator[IMPL, startable]
+	// middle nodes are only stored for inspection. Bypassed middle nodes are nil
+	middleNodes map[uintptr]nodeInstantiator[IMPL, inspectable]
+	finalNodes  map[uintptr]nodeInstantiator[IMPL, doneable]
 
-type nodeOrProvider[N any] struct {
-	node     N
-	provider *reflectProvider
+	// built is true after the first successful invocation to Build, as the NodesMap
+	// nodes can't be shared by multiple Runner instances
+	built bool
 }
 
+// ErrAlreadyBuilt is returned when the Build method of a Builder is invoked more than once.
+var ErrAlreadyBuilt = errors.New("the Builder has already built a pipeline")
+
+// nodeInstantiator creates a node and assigns it to its field in the passed NodesMap.
+// The name of the node in the Runner is passed to the nodes that nest other nodes under it.
+// If fresh is false, the nodes that were created and assigned to the NodesMap when they were
+// added to the Builder are reused. Nodes added from providers are always created by invoking
+// the provider.
+// Its generic-typed implementation allows connecting nodes from diverse input and output types
+// without requiring reflection.
+type nodeInstantiator[IMPL NodesMap, N any] func(nodesMap IMPL, name string, fresh bool) (N, error)
+
 // NewBuilder creates a pipeline builder whose nodes and connections are defined by the
 // passed NodesMap implementation.
 // It accepts a set of default options that would apply to all the nodes and connections
 // in the pipeline.
+// Unless the NodesMap implements the NodesNamer, TagConnector and NodesFactory interfaces (e.g.
+// because its methods are generated by the pipesgen tool), the Builder inspects its fields by
+// reflection to name the nodes, to connect the tagged fields and to create new instances of it.
 func NewBuilder[IMPL NodesMap](nodesMap IMPL, defaultOpts ...Option) *Builder[IMPL] {
 	return &Builder[IMPL]{
 		nodesMap:    nodesMap,
 		opts:        defaultOpts,
-		startNodes:  map[uintptr]nodeOrProvider[startable]{},
-		middleNodes: map[uintptr]nodeOrProvider[struct{}]{},
-		finalNodes:  map[uintptr]nodeOrProvider[doneable]{},
+		startNodes:  map[uintptr]nodeInstantiator[IMPL, startable]{},
+		middleNodes: map[uintptr]nodeInstantiator[IMPL, inspectable]{},
+		finalNodes:  map[uintptr]nodeInstantiator[IMPL, doneable]{},
 	}
 }
 
@@ -54,88 +102,160 @@ func (b *Builder[IMPL]) joinOpts(opts ...Option) []Option {
 	return opt
 }
 
-// reflected providers hides some "reflection magic" to allow connecting nodes from diverse
-// input and output types. Despite reflection API is not type safe, the typesafe public Go API
-// ensures that, for example, you can't connect two nodes from different out->in types.
-type reflectProvider struct {
-	// start and final nodes accept nillable functions. Middle nodes require creating a bypasser
-	acceptNilFunc  bool
-	middleBypasser *reflect.Value
-	asNode         reflect.Value
-	fieldGetter    reflect.Value
-	fn             reflect.Value
+// Build a pipe Runner ready to Start processing data until all the nodes are Done.
+// It can be only invoked once for each Builder: after a successful invocation, successive
+// invocations return ErrAlreadyBuilt. If it fails (e.g. because a provider returned an error),
+// it can be invoked again. Use BuildWith to create multiple Runners from the same Builder.
+func (b *Builder[IMPL]) Build() (*Runner, error) {
+	if b.built {
+		return nil, ErrAlreadyBuilt
+	}
+	runner, err := b.build(b.nodesMap, false)
+	if err != nil {
+		return nil, err
+	}
+	b.built = true
+	return runner, nil
 }
 
-func (rp *reflectProvider) call(nodesMap interface{}) (reflect.Value, uintptr, error) {
-	// nodeFn, err := Provider()
-	res := rp.fn.Call(nil)
-	nodeFn, err := res[0], res[1]
-	if !err.IsNil() {
-		return reflect.Value{}, 0, fmt.Errorf("error invoking provider: %w", err.Interface().(error))
-	}
-	// fieldPtr = fieldGetter(nodesMap)
-	fieldPtr := rp.fieldGetter.Call([]reflect.Value{reflect.ValueOf(nodesMap)})[0]
-
-	// a middle node getting a nil function is a bypasser
-	var node reflect.Value
-	if nodeFn.IsNil() && !rp.acceptNilFunc {
-		if rp.middleBypasser != nil {
-			// node = bypass[T]{}
-			node = *rp.middleBypasser
-		} else {
-			return reflect.Value{}, 0, fmt.Errorf("middle provider returned a nil function. Expecting %s", nodeFn.Type().String())
-		}
-	} else {
-		// node = AsNode(nodeFn)
-		node = rp.asNode.Call([]reflect.Value{nodeFn})[0]
+// BuildWith builds a new pipe Runner whose nodes are assigned to the fields of the passed
+// NodesMap instead of the NodesMap that was passed to NewBuilder. This allows building
+// multiple independent Runners with the same topology from a single Builder:
+//
+//	builder := pipe.NewBuilder(&TenantNodes{})
+//	pipe.AddStartProvider(builder, ingestPtr, IngestProvider(cfg))
+//	...
+//	for _, tenant := range tenants {
+//		runner, err := builder.BuildWith(&TenantNodes{})
+//		...
+//	}
+//
+// All the nodes are created again for each invocation, and the providers are re-invoked.
+// Each invocation requires a new NodesMap instance. If the passed NodesMap is the same that
+// was passed to NewBuilder, it is equivalent to invoking Build.
+func (b *Builder[IMPL]) BuildWith(nodesMap IMPL) (*Runner, error) {
+	if sameNodesMap(nodesMap, b.nodesMap) {
+		return b.Build()
 	}
-	// *fieldPtr = AsNode(nodeFn)
-	fieldPtr.Elem().Set(node)
-	return node, fieldPtr.Pointer(), nil
+	return b.build(nodesMap, true)
 }
 
-// Build a pipe Runner ready to Start processing data until all the nodes are Done.
-func (b *Builder[IMPL]) Build() (*Runner, error) {
-	runner := &Runner{
-		startNodes: map[uintptr]startable{},
-		finalNodes: map[uintptr]doneable{},
-	}
-	for dstPtr, sn := range b.startNodes {
-		if sp := sn.provider; sp == nil {
-			// node explicitly set via AddStart, AddMiddle, AddFinal
-			runner.startNodes[dstPtr] = sn.node
-		} else {
-			// node provided from AddStartProvider argument func
-			sp := sn.provider
-			if node, dstFieldPtr, err := sp.call(b.nodesMap); err != nil {
-				return nil, fmt.Errorf("invoking Start node provider: %w", err)
-			} else {
-				runner.startNodes[dstFieldPtr] = node.Interface().(startable)
-			}
+func (b *Builder[IMPL]) build(nodesMap IMPL, fresh bool) (*Runner, error) {
+	options := getOptions(b.opts...)
+	runner, err := newRunner(&options)
+	if err != nil {
+		return nil, err
+	}
+	for dstPtr, instantiate := range b.startNodes {
+		node, err := instantiate(nodesMap, b.nodeName(dstPtr), fresh)
+		if err != nil {
+			return nil, fmt.Errorf("invoking Start node provider %s: %w", b.nodeName(dstPtr), err)
 		}
+		runner.startNodes[dstPtr] = node
 	}
-	for _, mn := range b.middleNodes {
-		// we only care about nodes added by AddMiddleProviders, as nodes
-		// from AddMiddle are already created and assigned to its field
-		if mp := mn.provider; mp != nil {
-			if _, _, err := mp.call(b.nodesMap); err != nil {
-				return nil, fmt.Errorf("invoking Middle node provider: %w", err)
-			}
+	for dstPtr, instantiate := range b.middleNodes {
+		name := b.nodeName(dstPtr)
+		node, err := instantiate(nodesMap, name, fresh)
+		if err != nil {
+			return nil, fmt.Errorf("invoking Middle node provider %s: %w", name, err)
+		}
+		if node != nil {
+			runner.nodes[name] = node
+		}
+		if n, ok := node.(nested); ok {
+			runner.nest(n.subRunner())
 		}
 	}
-	for dstPtr, fn := range b.finalNodes {
-		if fp := fn.provider; fp == nil {
-			// node explicitly set via AddFinal
-			runner.finalNodes[dstPtr] = fn.node
-		} else {
-			// node provided from AddMiddleProvider argument func
-			if node, dstFieldPtr, err := fp.call(b.nodesMap); err != nil {
-				return nil, fmt.Errorf("invoking Final node provider: %w", err)
-			} else {
-				runner.finalNodes[dstFieldPtr] = node.Interface().(doneable)
-			}
+	for dstPtr, instantiate := range b.finalNodes {
+		node, err := instantiate(nodesMap, b.nodeName(dstPtr), fresh)
+		if err != nil {
+			return nil, fmt.Errorf("invoking Final node provider %s: %w", b.nodeName(dstPtr), err)
+		}
+		runner.finalNodes[dstPtr] = node
+	}
+	if checker, ok := any(nodesMap).(NodesChecker); ok {
+		if err := checker.CheckNodes(); err != nil {
+			return nil, fmt.Errorf("checking nodes: %w", err)
+		}
+	}
+	if tc, ok := any(nodesMap).(TagConnector); ok {
+		tc.ConnectTagged()
+	} else if err := connectTagged(nodesMap); err != nil {
+		return nil, fmt.Errorf("connecting tagged nodes: %w", err)
+	}
+	nodesMap.Connect()
+	for dstPtr, sn := range runner.startNodes {
+		if in, ok := sn.(inspectable); ok {
+			runner.nodes[b.nodeName(dstPtr)] = in
 		}
 	}
-	b.nodesMap.Connect()
+	for dstPtr, fn := range runner.finalNodes {
+		if in, ok := fn.(inspectable); ok {
+			runner.nodes[b.nodeName(dstPtr)] = in
+		}
+	}
+	if err := runner.checkNodes(); err != nil {
+		return nil, err
+	}
 	return runner, nil
 }
+
+// newNodesMap returns a new, zero-valued instance of the NodesMap passed to NewBuilder.
+func (b *Builder[IMPL]) newNodesMap() (IMPL, error) {
+	if factory, ok := any(b.nodesMap).(NodesFactory); ok {
+		if nm, ok := factory.NewNodes().(IMPL); ok {
+			return nm, nil
+		}
+	}
+	nm := reflect.ValueOf(b.nodesMap)
+	if nm.Kind() != reflect.Pointer {
+		var zero IMPL
+		return zero, fmt.Errorf("can't create a new instance of %T. It must be a pointer or implement NodesFactory", b.nodesMap)
+	}
+	return reflect.New(nm.Type().Elem()).Interface().(IMPL), nil
+}
+
+// sameNodesMap returns true if both NodesMap implementations are the same instance.
+// NodesMap implementations are pointers, as the nodes are assigned to their fields.
+func sameNodesMap(a, b any) bool {
+	return a == b
+}
+
+// nodeName returns the name of the node stored in the NodesMap field whose address is passed
+// as argument, nested under the name of the parent node if the Builder defines a sub-pipeline.
+func (b *Builder[IMPL]) nodeName(fieldPtr uintptr) string {
+	b.namesOnce.Do(func() {
+		b.names = nodeNames(b.nodesMap)
+	})
+	if name, ok := b.names[fieldPtr]; ok {
+		return b.namePrefix + name
+	}
+	return fmt.Sprintf("%s%T@%#x", b.namePrefix, b.nodesMap, fieldPtr)
+}
+
+// nodeNames returns the names of the NodesMap fields indexed by their addresses, to be used to
+// identify the nodes in errors and diagnostics. If the NodesMap does not implement NodesNamer,
+// the names are looked up by reflection.
+func nodeNames(nodesMap any) map[uintptr]string {
+	names := map[uintptr]string{}
+	if namer, ok := nodesMap.(NodesNamer); ok {
+		for _, field := range namer.NodeFields() {
+			names[field.address] = field.name
+		}
+		return names
+	}
+	nm := reflect.ValueOf(nodesMap)
+	if nm.Kind() == reflect.Pointer && nm.Elem().Kind() == reflect.Struct {
+		nm = nm.Elem()
+		for i := 0; i < nm.NumField(); i++ {
+			names[nm.Field(i).UnsafeAddr()] = nm.Type().Field(i).Name
+		}
+	}
+	return names
+}
+
+// fieldAddress returns the address of a NodesMap field, which is used to index
+// the nodes in the Builder and the Runner
+func fieldAddress[T any](field *T) uintptr {
+	return uintptr(unsafe.Pointer(field))
+}
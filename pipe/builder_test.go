@@ -1,12 +1,14 @@
 package pipe_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
 )
 
 type StartError struct{}
@@ -59,3 +61,136 @@ func TestError_FinalNode(t *testing.T) {
 	require.Error(t, err)
 	assert.ErrorIs(t, err, FinalError{})
 }
+
+type checkedPipe struct {
+	smfPipe
+	checkErr error
+}
+
+func (c *checkedPipe) CheckNodes() error { return c.checkErr }
+
+func cStart(c *checkedPipe) *pipe.Start[int]     { return &c.start }
+func cMid(c *checkedPipe) *pipe.Middle[int, int] { return &c.mid }
+
+func TestError_CheckNodes(t *testing.T) {
+	b := pipe.NewBuilder(&checkedPipe{checkErr: FinalError{}})
+	pipe.AddStart(b, cStart, func(out chan<- int) {})
+	pipe.AddMiddle(b, cMid, func(in <-chan int, out chan<- int) {})
+
+	// the error is returned before connecting the nodes, despite the final node is missing
+	assert.NotPanics(t, func() {
+		_, err := b.Build()
+		require.Error(t, err)
+		assert.ErrorIs(t, err, FinalError{})
+	})
+}
+
+func TestError_BuildTwice(t *testing.T) {
+	b := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStart(b, start, func(out chan<- int) {})
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {})
+	pipe.AddFinal(b, final, func(in <-chan int) {})
+
+	_, err := b.Build()
+	require.NoError(t, err)
+	_, err = b.Build()
+	assert.ErrorIs(t, err, pipe.ErrAlreadyBuilt)
+}
+
+func TestError_BuildRetry(t *testing.T) {
+	fail := true
+	b := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStartProvider(b, start, func() (pipe.StartFunc[int], error) {
+		if fail {
+			return nil, errors.New("provider failed")
+		}
+		return Counter(1, 3), nil
+	})
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	var received []int
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		for i := range in {
+			received = append(received, i)
+		}
+	})
+
+	// a failed build doesn't prevent retrying it
+	_, err := b.Build()
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, pipe.ErrAlreadyBuilt)
+	assert.Contains(t, err.Error(), "provider failed")
+
+	fail = false
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Run()
+	assert.Equal(t, []int{1, 2, 3}, received)
+
+	_, err = b.Build()
+	assert.ErrorIs(t, err, pipe.ErrAlreadyBuilt)
+}
+
+func TestBuildWith(t *testing.T) {
+	providerInvocations := 0
+	b := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStartProvider(b, start, func() (pipe.StartFunc[int], error) {
+		providerInvocations++
+		return Counter(providerInvocations*10, providerInvocations*10+2), nil
+	})
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- -i
+		}
+	})
+	// each final node stores the received data in a different slice
+	var outputs []*[]int
+	pipe.AddFinalProvider(b, final, func() (pipe.FinalFunc[int], error) {
+		output := &[]int{}
+		outputs = append(outputs, output)
+		return collectInto(output), nil
+	})
+
+	var runners []*pipe.Runner
+	var nodesMaps []*smfPipe
+	for i := 0; i < 3; i++ {
+		nodes := &smfPipe{}
+		r, err := b.BuildWith(nodes)
+		require.NoError(t, err)
+		runners = append(runners, r)
+		nodesMaps = append(nodesMaps, nodes)
+	}
+	// all the runners have independent nodes
+	assert.NotSame(t, nodesMaps[0].mid, nodesMaps[1].mid)
+	assert.NotSame(t, nodesMaps[1].mid, nodesMaps[2].mid)
+	for _, r := range runners {
+		r.Start()
+	}
+	for _, r := range runners {
+		testers.ReadChannel(t, r.Done(), timeout)
+	}
+	require.Len(t, outputs, 3)
+	assert.Equal(t, []int{-10, -11, -12}, *outputs[0])
+	assert.Equal(t, []int{-20, -21, -22}, *outputs[1])
+	assert.Equal(t, []int{-30, -31, -32}, *outputs[2])
+}
+
+func TestBuildWith_SubPipeline(t *testing.T) {
+	var invalid []pipe.DeadLetter[int]
+	b := pipe.NewBuilder(&parentPipe{})
+	pipe.AddStart(b, numbersPtr, Counter(1, 3))
+	pipe.AddSubPipeline(b, enrichPtr, enricherBuilder(&invalid), enricherInPtr, enricherOutPtr)
+	var stored []string
+	pipe.AddFinal(b, storePtr, collectInto(&stored))
+
+	for i := 0; i < 2; i++ {
+		r, err := b.BuildWith(&parentPipe{})
+		require.NoError(t, err)
+		require.NoError(t, r.Run())
+	}
+	assert.Equal(t, []string{"odd:1", "odd:3", "odd:1", "odd:3"}, stored)
+	assert.Len(t, invalid, 2)
+}
@@ -0,0 +1,48 @@
+package pipe
+
+import (
+	"time"
+
+	"github.com/mariomac/pipes/pipe/internal/connect"
+)
+
+// Clock provides the current time and the timers to the time-based features of the library,
+// so they can be deterministically tested by providing a fake implementation (e.g. testers.FakeClock).
+// It has the following methods:
+//
+//	// Now returns the current time
+//	Now() time.Time
+//	// After waits for the duration to elapse and then sends the current time on the returned channel
+//	After(d time.Duration) <-chan time.Time
+//	// NewTimer is equivalent to After, but it also returns a function that stops the timer, so
+//	// it doesn't fire if the returned channel is abandoned. The stop function returns false if
+//	// the timer already fired or was stopped
+//	NewTimer(d time.Duration) (c <-chan time.Time, stop func() bool)
+//	// Sleep pauses the current goroutine for at least the duration d
+//	Sleep(d time.Duration)
+type Clock = connect.Clock
+
+// SystemClock returns the Clock that is used by default, which relies on the time
+// functions from the standard library.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	timer := time.NewTimer(d)
+	return timer.C, timer.Stop
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
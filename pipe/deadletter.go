@@ -0,0 +1,237 @@
+package pipe
+
+import (
+	"github.com/mariomac/pipes/pipe/internal/connect"
+)
+
+// DeadLetter wraps an item that a node failed to process, and it is forwarded through
+// the DeadLetters sender of the FailableMiddle and FailableFinal nodes.
+type DeadLetter[T any] struct {
+	// Item that couldn't be processed
+	Item T
+	// Err returned by the node function when processing the item
+	Err error
+	// Node is the name of the NodesMap field that stores the node that failed. If the node
+	// belongs to a sub-pipeline, its name is prefixed by the name of the parent node
+	// (e.g. "enrich/parse")
+	Node string
+}
+
+// FailableMiddle is a Middle node whose function processes the items one by one and might fail
+// processing them. The failed items are forwarded to the Receiver nodes connected to the
+// DeadLetters sender.
+type FailableMiddle[IN, OUT any] interface {
+	Middle[IN, OUT]
+	// DeadLetters returns the Sender that forwards the items that the node failed to process.
+	// If it is not connected to any Receiver, the failed items are discarded.
+	DeadLetters() Sender[DeadLetter[IN]]
+}
+
+// FailableFinal is a Final node whose function processes the items one by one and might fail
+// processing them. The failed items are forwarded to the Receiver nodes connected to the
+// DeadLetters sender.
+type FailableFinal[IN any] interface {
+	Final[IN]
+	// DeadLetters returns the Sender that forwards the items that the node failed to process.
+	// If it is not connected to any Receiver, the failed items are discarded.
+	DeadLetters() Sender[DeadLetter[IN]]
+}
+
+// FailableMiddlePtr is a function that, given a NodesMap, returns a pointer to a
+// FailableMiddle node, which is going to be used as store destination
+// when this function is passed as argument to AddFailableMiddle.
+type FailableMiddlePtr[IMPL NodesMap, IN, OUT any] func(IMPL) *FailableMiddle[IN, OUT]
+
+// FailableFinalPtr is a function that, given a NodesMap, returns a pointer to a
+// FailableFinal node, which is going to be used as store destination
+// when this function is passed as argument to AddFailableFinal.
+type FailableFinalPtr[IMPL NodesMap, IN any] func(IMPL) *FailableFinal[IN]
+
+// AddFailableMiddle creates a FailableMiddle node that invokes the provided function for each
+// received item, forwarding the successful results to the destination nodes and the failed
+// items to the destinations of its DeadLetters sender. Example:
+//
+//	type MyPipeline struct {
+//		Load      pipe.Start[string]
+//		Parse     pipe.FailableMiddle[string, Record]
+//		Store     pipe.Final[Record]
+//		ParseDLQ  pipe.Final[pipe.DeadLetter[string]]
+//	}
+//	func (m *MyPipeline) Connect() {
+//		m.Load.SendTo(m.Parse)
+//		m.Parse.SendTo(m.Store)
+//		m.Parse.DeadLetters().SendTo(m.ParseDLQ)
+//	}
+//
+// The node will be assigned to the field of the NodesMap whose pointer is returned by
+// the provided FailableMiddlePtr function.
+// The options related to the connection to that node can be overridden. Otherwise
+// the global options passed to the pipeline Builder are used.
+func AddFailableMiddle[IMPL NodesMap, IN, OUT any](p *Builder[IMPL], field FailableMiddlePtr[IMPL, IN, OUT], fn func(IN) (OUT, error), opts ...Option) {
+	opts = p.joinOpts(opts...)
+	dstAddress := field(p.nodesMap)
+	middleNode := newFailableMiddle(fn, opts)
+	p.middleNodes[fieldAddress(dstAddress)] = func(nodesMap IMPL, _ string, fresh bool) (inspectable, error) {
+		if !fresh {
+			return middleNode, nil
+		}
+		node := newFailableMiddle(fn, opts)
+		*field(nodesMap) = node
+		return node, nil
+	}
+	*(dstAddress) = middleNode
+}
+
+func newFailableMiddle[IN, OUT any](fn func(IN) (OUT, error), opts []Option) *failableMiddle[IN, OUT] {
+	fm := &failableMiddle[IN, OUT]{}
+	fm.middle = asMiddle(func(in <-chan IN, out chan<- OUT) {
+		send := fm.deadLetters.open()
+		defer fm.deadLetters.release()
+		for i := range in {
+			if o, err := fn(i); err != nil {
+				send(i, err)
+			} else {
+				out <- o
+			}
+		}
+	}, opts...)
+	return fm
+}
+
+// AddFailableFinal creates a FailableFinal node that invokes the provided function for each
+// received item, forwarding the failed items to the destinations of its DeadLetters sender.
+// It can be combined with Retry, so only the items that failed after all the retries are
+// forwarded as dead letters:
+//
+//	pipe.AddFailableFinal(builder, storePtr, pipe.Retry(storeInDB, pipe.RetryMaxAttempts(5)))
+//
+// The node will be assigned to the field of the NodesMap whose pointer is returned by
+// the provided FailableFinalPtr function.
+// The options related to the connection to that node can be overridden. Otherwise
+// the global options passed to the pipeline Builder are used.
+func AddFailableFinal[IMPL NodesMap, IN any](p *Builder[IMPL], field FailableFinalPtr[IMPL, IN], fn func(IN) error, opts ...Option) {
+	opts = p.joinOpts(opts...)
+	dstAddress := field(p.nodesMap)
+	finalNode := newFailableFinal(fn, opts)
+	p.finalNodes[fieldAddress(dstAddress)] = func(nodesMap IMPL, _ string, fresh bool) (doneable, error) {
+		if !fresh {
+			return finalNode, nil
+		}
+		node := newFailableFinal(fn, opts)
+		*field(nodesMap) = node
+		return node, nil
+	}
+	*(dstAddress) = finalNode
+}
+
+func newFailableFinal[IN any](fn func(IN) error, opts []Option) *failableFinal[IN] {
+	ff := &failableFinal[IN]{}
+	ff.terminal = asFinal(func(in <-chan IN) {
+		send := ff.deadLetters.open()
+		defer ff.deadLetters.release()
+		for i := range in {
+			if err := fn(i); err != nil {
+				send(i, err)
+			}
+		}
+	}, opts...)
+	return ff
+}
+
+type failableMiddle[IN, OUT any] struct {
+	*middle[IN, OUT]
+	deadLetters deadLetters[IN]
+}
+
+func (fm *failableMiddle[IN, OUT]) DeadLetters() Sender[DeadLetter[IN]] {
+	return &fm.deadLetters
+}
+
+//nolint:unused
+func (fm *failableMiddle[IN, OUT]) track(tracker *connect.Tracker, name string) {
+	fm.middle.track(tracker, name)
+	fm.deadLetters.name = name
+	fm.deadLetters.track(tracker, name+deadLettersSuffix)
+}
+
+//nolint:unused
+func (fm *failableMiddle[IN, OUT]) start() {
+	fm.deadLetters.startReceivers()
+	fm.middle.start()
+}
+
+type failableFinal[IN any] struct {
+	*terminal[IN]
+	deadLetters deadLetters[IN]
+}
+
+func (ff *failableFinal[IN]) DeadLetters() Sender[DeadLetter[IN]] {
+	return &ff.deadLetters
+}
+
+//nolint:unused
+func (ff *failableFinal[IN]) track(tracker *connect.Tracker, name string) {
+	ff.terminal.track(tracker, name)
+	ff.deadLetters.name = name
+	ff.deadLetters.track(tracker, name+deadLettersSuffix)
+}
+
+//nolint:unused
+func (ff *failableFinal[IN]) start() {
+	ff.deadLetters.startReceivers()
+	ff.terminal.start()
+}
+
+//nolint:unused
+func (fm *failableMiddle[IN, OUT]) destinations() []any {
+	return append(fm.middle.destinations(), fm.deadLetters.destinations()...)
+}
+
+//nolint:unused
+func (ff *failableFinal[IN]) destinations() []any {
+	return ff.deadLetters.destinations()
+}
+
+// deadLettersSuffix is appended to the name of a failable node to name its dead letters Sender
+const deadLettersSuffix = ".DeadLetters"
+
+// deadLetters is the Sender of the failed items of a failable node
+type deadLetters[IN any] struct {
+	receiverGroup[DeadLetter[IN]]
+	// name of the failing node in the Runner. It is assigned when the Runner starts, as
+	// the node could be nested into a sub-pipeline after being added to its Builder
+	name   string
+	forker *connect.Forker[DeadLetter[IN]]
+}
+
+// startReceivers must be invoked before the node that sends the dead letters is started,
+// to make sure that all the receivers are started and their joiners acquired by the time
+// the node starts sending data.
+func (dl *deadLetters[IN]) startReceivers() {
+	if len(dl.Outs) == 0 {
+		return
+	}
+	forker, err := dl.StartReceivers()
+	if err != nil {
+		panic("dead letters: " + err.Error())
+	}
+	dl.forker = forker
+}
+
+// open returns a function to send the failed items to the dead letters receivers, if any.
+func (dl *deadLetters[IN]) open() func(IN, error) {
+	if dl.forker == nil {
+		return func(IN, error) {}
+	}
+	dlq := dl.forker.AcquireSender()
+	name := dl.name
+	return func(item IN, err error) {
+		dlq <- DeadLetter[IN]{Item: item, Err: err, Node: name}
+	}
+}
+
+func (dl *deadLetters[IN]) release() {
+	if dl.forker != nil {
+		dl.forker.ReleaseSender()
+	}
+}
@@ -0,0 +1,121 @@
+package pipe_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+type deadLettersPipe struct {
+	start      pipe.Start[string]
+	parser     pipe.FailableMiddle[string, int]
+	store      pipe.FailableFinal[int]
+	parseDLQ   pipe.Final[pipe.DeadLetter[string]]
+	storageDLQ pipe.Final[pipe.DeadLetter[int]]
+}
+
+func (d *deadLettersPipe) Connect() {
+	d.start.SendTo(d.parser)
+	d.parser.SendTo(d.store)
+	d.parser.DeadLetters().SendTo(d.parseDLQ)
+	d.store.DeadLetters().SendTo(d.storageDLQ)
+}
+
+func (d *deadLettersPipe) startPtr() *pipe.Start[string]                     { return &d.start }
+func (d *deadLettersPipe) parserPtr() *pipe.FailableMiddle[string, int]      { return &d.parser }
+func (d *deadLettersPipe) storePtr() *pipe.FailableFinal[int]                { return &d.store }
+func (d *deadLettersPipe) parseDLQPtr() *pipe.Final[pipe.DeadLetter[string]] { return &d.parseDLQ }
+func (d *deadLettersPipe) storageDLQPtr() *pipe.Final[pipe.DeadLetter[int]]  { return &d.storageDLQ }
+
+var errOddNumber = errors.New("odd number")
+
+func TestDeadLetters(t *testing.T) {
+	p := pipe.NewBuilder(&deadLettersPipe{})
+	pipe.AddStart(p, (*deadLettersPipe).startPtr, func(out chan<- string) {
+		for _, s := range []string{"1", "2", "three", "4", "5"} {
+			out <- s
+		}
+	})
+	pipe.AddFailableMiddle(p, (*deadLettersPipe).parserPtr, strconv.Atoi)
+	var stored []int
+	pipe.AddFailableFinal(p, (*deadLettersPipe).storePtr, func(i int) error {
+		if i%2 == 1 {
+			return fmt.Errorf("storing %d: %w", i, errOddNumber)
+		}
+		stored = append(stored, i)
+		return nil
+	})
+	var parseDLQ []pipe.DeadLetter[string]
+	pipe.AddFinal(p, (*deadLettersPipe).parseDLQPtr, collectInto(&parseDLQ))
+	var storageDLQ []pipe.DeadLetter[int]
+	pipe.AddFinal(p, (*deadLettersPipe).storageDLQPtr, collectInto(&storageDLQ))
+
+	r, err := p.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.Equal(t, []int{2, 4}, stored)
+
+	require.Len(t, parseDLQ, 1)
+	assert.Equal(t, "three", parseDLQ[0].Item)
+	assert.Equal(t, "parser", parseDLQ[0].Node)
+	var numErr *strconv.NumError
+	assert.ErrorAs(t, parseDLQ[0].Err, &numErr)
+
+	require.Len(t, storageDLQ, 2)
+	for i, item := range []int{1, 5} {
+		assert.Equal(t, item, storageDLQ[i].Item)
+		assert.Equal(t, "store", storageDLQ[i].Node)
+		assert.ErrorIs(t, storageDLQ[i].Err, errOddNumber)
+	}
+}
+
+type unconnectedDLQPipe struct {
+	start  pipe.Start[string]
+	parser pipe.FailableMiddle[string, int]
+	store  pipe.FailableFinal[int]
+	dlq    pipe.Final[pipe.DeadLetter[string]]
+}
+
+func (d *unconnectedDLQPipe) Connect() {
+	d.start.SendTo(d.parser)
+	d.parser.SendTo(d.store)
+	d.parser.DeadLetters().SendTo(d.dlq)
+	// store dead letters are not connected, so they are discarded
+}
+
+func (d *unconnectedDLQPipe) startPtr() *pipe.Start[string]                { return &d.start }
+func (d *unconnectedDLQPipe) parserPtr() *pipe.FailableMiddle[string, int] { return &d.parser }
+func (d *unconnectedDLQPipe) storePtr() *pipe.FailableFinal[int]           { return &d.store }
+func (d *unconnectedDLQPipe) dlqPtr() *pipe.Final[pipe.DeadLetter[string]] { return &d.dlq }
+
+func TestDeadLetters_Unconnected(t *testing.T) {
+	p := pipe.NewBuilder(&unconnectedDLQPipe{})
+	pipe.AddStart(p, (*unconnectedDLQPipe).startPtr, func(out chan<- string) {
+		for _, s := range []string{"1", "2", "three"} {
+			out <- s
+		}
+	})
+	pipe.AddFailableMiddle(p, (*unconnectedDLQPipe).parserPtr, strconv.Atoi)
+	pipe.AddFailableFinal(p, (*unconnectedDLQPipe).storePtr, func(i int) error {
+		return errOddNumber
+	})
+	var dlq []pipe.DeadLetter[string]
+	pipe.AddFinal(p, (*unconnectedDLQPipe).dlqPtr, collectInto(&dlq))
+
+	r, err := p.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	require.Len(t, dlq, 1)
+	assert.Equal(t, "three", dlq[0].Item)
+}
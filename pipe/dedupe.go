@@ -0,0 +1,264 @@
+package pipe
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"time"
+)
+
+const defaultDedupeCapacity = 1024
+
+type dedupeOptions struct {
+	// maximum number of keys remembered in exact mode. 0 means unbounded
+	capacity int
+	// maximum time that a key is remembered since the last time it was seen.
+	// 0 means that keys never expire
+	ttl time.Duration
+	// if > 0, the approximate (Bloom filter) mode is enabled
+	expectedItems     int
+	falsePositiveRate float64
+	clock             Clock
+}
+
+// DedupeOption allows overriding the default properties of a Dedupe node.
+type DedupeOption func(options *dedupeOptions)
+
+// DedupeLRU is a DedupeOption that bounds the number of keys remembered by
+// the Dedupe node. When the capacity is reached, the least recently seen key
+// is forgotten. The default capacity is 1024. A value of 0 means that the
+// number of remembered keys is unbounded (use it in conjunction with DedupeTTL).
+func DedupeLRU(capacity int) DedupeOption {
+	return func(options *dedupeOptions) {
+		options.capacity = capacity
+	}
+}
+
+// DedupeTTL is a DedupeOption that makes the Dedupe node forget the keys that
+// haven't been seen during the provided duration. It can be combined with DedupeLRU.
+func DedupeTTL(ttl time.Duration) DedupeOption {
+	return func(options *dedupeOptions) {
+		options.ttl = ttl
+	}
+}
+
+// DedupeApproximate is a DedupeOption that replaces the exact seen-set by a pair of
+// rotating Bloom filters, sized for the expected number of distinct items and the
+// provided false positive rate. It keeps a constant memory footprint for high-cardinality
+// keys, at the cost of dropping a small ratio of non-duplicate items.
+// DedupeLRU and DedupeTTL are ignored in approximate mode.
+// The false positive rate must be greater than 0 and lower than 1.
+func DedupeApproximate(expectedItems int, falsePositiveRate float64) DedupeOption {
+	return func(options *dedupeOptions) {
+		options.expectedItems = expectedItems
+		options.falsePositiveRate = falsePositiveRate
+	}
+}
+
+// DedupeClock is a DedupeOption that specifies the Clock used to expire the keys
+// remembered by the DedupeTTL option. Default: SystemClock.
+func DedupeClock(clock Clock) DedupeOption {
+	return func(options *dedupeOptions) {
+		options.clock = clock
+	}
+}
+
+// Dedupe returns a MiddleFunc that forwards the received items, dropping those whose
+// key, as returned by the key function, has already been seen.
+// The memory used to remember the seen keys is bounded by the DedupeLRU, DedupeTTL or
+// DedupeApproximate options.
+// If the false positive rate of the DedupeApproximate option is out of range, it is replaced by
+// 0.01. Use DedupeProvider to get an error instead.
+func Dedupe[T any, K comparable](key func(T) K, opts ...DedupeOption) MiddleFunc[T, T] {
+	return dedupe(key, getDedupeOptions(opts))
+}
+
+// DedupeProvider returns a MiddleProvider of the Dedupe function. Unlike Dedupe, it returns an
+// error if any of the passed options is invalid.
+func DedupeProvider[T any, K comparable](key func(T) K, opts ...DedupeOption) MiddleProvider[T, T] {
+	return func() (MiddleFunc[T, T], error) {
+		options := getDedupeOptions(opts)
+		if options.expectedItems > 0 && (options.falsePositiveRate <= 0 || options.falsePositiveRate >= 1) {
+			return nil, fmt.Errorf("dedupe: the false positive rate must be between 0 and 1. Got: %v",
+				options.falsePositiveRate)
+		}
+		return dedupe(key, options), nil
+	}
+}
+
+func getDedupeOptions(opts []DedupeOption) dedupeOptions {
+	options := dedupeOptions{capacity: defaultDedupeCapacity, clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+func dedupe[T any, K comparable](key func(T) K, options dedupeOptions) MiddleFunc[T, T] {
+	return func(in <-chan T, out chan<- T) {
+		var seen seenSet[K]
+		if options.expectedItems > 0 {
+			seen = newBloomSet[K](options.expectedItems, options.falsePositiveRate)
+		} else {
+			seen = newLRUSet[K](options.capacity, options.ttl, options.clock)
+		}
+		for i := range in {
+			if !seen.testAndAdd(key(i)) {
+				out <- i
+			}
+		}
+	}
+}
+
+type seenSet[K comparable] interface {
+	// testAndAdd returns whether the key was already in the set, and adds it otherwise
+	testAndAdd(key K) bool
+}
+
+type lruEntry[K comparable] struct {
+	key      K
+	lastSeen time.Time
+}
+
+// lruSet keeps the seen keys sorted by the last time they were seen, from the most recent
+// to the least recent, so both the LRU and the TTL eviction just need to remove entries from the back.
+type lruSet[K comparable] struct {
+	capacity int
+	ttl      time.Duration
+	clock    Clock
+	entries  map[K]*list.Element
+	recency  *list.List
+}
+
+func newLRUSet[K comparable](capacity int, ttl time.Duration, clock Clock) *lruSet[K] {
+	return &lruSet[K]{
+		capacity: capacity,
+		ttl:      ttl,
+		clock:    clock,
+		entries:  map[K]*list.Element{},
+		recency:  list.New(),
+	}
+}
+
+func (s *lruSet[K]) testAndAdd(key K) bool {
+	now := s.clock.Now()
+	s.expire(now)
+	if elem, ok := s.entries[key]; ok {
+		elem.Value.(*lruEntry[K]).lastSeen = now
+		s.recency.MoveToFront(elem)
+		return true
+	}
+	s.entries[key] = s.recency.PushFront(&lruEntry[K]{key: key, lastSeen: now})
+	if s.capacity > 0 && s.recency.Len() > s.capacity {
+		s.remove(s.recency.Back())
+	}
+	return false
+}
+
+func (s *lruSet[K]) expire(now time.Time) {
+	if s.ttl <= 0 {
+		return
+	}
+	for last := s.recency.Back(); last != nil; last = s.recency.Back() {
+		if now.Sub(last.Value.(*lruEntry[K]).lastSeen) < s.ttl {
+			return
+		}
+		s.remove(last)
+	}
+}
+
+func (s *lruSet[K]) remove(elem *list.Element) {
+	s.recency.Remove(elem)
+	delete(s.entries, elem.Value.(*lruEntry[K]).key)
+}
+
+// bloomSet is an approximate seen-set formed by two Bloom filters. New keys are
+// added to the current filter and, when it reaches the expected number of items,
+// it replaces the previous filter and a new current filter is created. This way
+// the false positive rate does not grow indefinitely.
+type bloomSet[K comparable] struct {
+	bits      uint64
+	hashes    int
+	maxItems  int
+	items     int
+	current   []uint64
+	previous  []uint64
+	hashInput []byte
+}
+
+// newBloomSet replaces an out of range false positive rate by 0.01
+func newBloomSet[K comparable](expectedItems int, falsePositiveRate float64) *bloomSet[K] {
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+	bits := math.Ceil(-float64(expectedItems) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashes := int(math.Max(1, math.Round(bits/float64(expectedItems)*math.Ln2)))
+	words := (uint64(bits) + 63) / 64
+	return &bloomSet[K]{
+		bits:     words * 64,
+		hashes:   hashes,
+		maxItems: expectedItems,
+		current:  make([]uint64, words),
+		previous: make([]uint64, words),
+	}
+}
+
+func (s *bloomSet[K]) testAndAdd(key K) bool {
+	h1, h2 := s.hash(key)
+	inCurrent, inPrevious := true, true
+	for i := 0; i < s.hashes; i++ {
+		// double hashing to simulate k independent hash functions
+		bit := (h1 + uint64(i)*h2) % s.bits
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		inCurrent = inCurrent && s.current[word]&mask != 0
+		inPrevious = inPrevious && s.previous[word]&mask != 0
+	}
+	if inCurrent {
+		return true
+	}
+	if s.items >= s.maxItems {
+		s.previous, s.current = s.current, s.previous
+		for i := range s.current {
+			s.current[i] = 0
+		}
+		s.items = 0
+	}
+	for i := 0; i < s.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % s.bits
+		s.current[bit/64] |= uint64(1) << (bit % 64)
+	}
+	s.items++
+	return inPrevious
+}
+
+func (s *bloomSet[K]) hash(key K) (uint64, uint64) {
+	s.hashInput = appendKey(s.hashInput[:0], key)
+	h := fnv.New64a()
+	_, _ = h.Write(s.hashInput)
+	sum := h.Sum64()
+	// h2 must be odd so the double hashing visits different bits
+	return sum, (sum>>32 | sum<<32) | 1
+}
+
+// appendKey appends a byte representation of the key, avoiding the fmt
+// package for the most common key types.
+func appendKey(dst []byte, key any) []byte {
+	switch k := key.(type) {
+	case string:
+		return append(dst, k...)
+	case int:
+		return strconv.AppendInt(dst, int64(k), 10)
+	case int64:
+		return strconv.AppendInt(dst, k, 10)
+	case uint64:
+		return strconv.AppendUint(dst, k, 10)
+	case int32:
+		return strconv.AppendInt(dst, int64(k), 10)
+	case uint32:
+		return strconv.AppendUint(dst, uint64(k), 10)
+	default:
+		return append(dst, fmt.Sprintf("%#v", k)...)
+	}
+}
@@ -0,0 +1,149 @@
+package pipe_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+type event struct {
+	id      int
+	payload string
+}
+
+func eventID(e event) int { return e.id }
+
+func TestDedupe_Pipeline(t *testing.T) {
+	p := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStart(p, start, func(out chan<- int) {
+		for _, n := range []int{1, 2, 1, 3, 2, 4, 4, 1} {
+			out <- n
+		}
+	})
+	pipe.AddMiddle(p, mid, pipe.Dedupe(func(i int) int { return i }))
+	var collected []int
+	pipe.AddFinal(p, final, func(in <-chan int) {
+		for i := range in {
+			collected = append(collected, i)
+		}
+	})
+	r, err := p.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.Equal(t, []int{1, 2, 3, 4}, collected)
+}
+
+func TestDedupeProvider(t *testing.T) {
+	p := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStart(p, start, func(out chan<- int) {
+		for _, n := range []int{1, 2, 1, 3} {
+			out <- n
+		}
+	})
+	pipe.AddMiddleProvider(p, mid, pipe.DedupeProvider(func(i int) int { return i }, pipe.DedupeApproximate(100, 0.01)))
+	var collected []int
+	pipe.AddFinal(p, final, func(in <-chan int) {
+		for i := range in {
+			collected = append(collected, i)
+		}
+	})
+	r, err := p.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.Equal(t, []int{1, 2, 3}, collected)
+}
+
+func TestDedupeProvider_InvalidRate(t *testing.T) {
+	for _, rate := range []float64{0, -0.1, 1, 1.5} {
+		_, err := pipe.DedupeProvider(eventID, pipe.DedupeApproximate(100, rate))()
+		assert.Error(t, err, "rate: %v", rate)
+	}
+	// the rate is ignored if the approximate mode is not enabled
+	_, err := pipe.DedupeProvider(eventID, pipe.DedupeLRU(10))()
+	assert.NoError(t, err)
+}
+
+func TestDedupe_LRU(t *testing.T) {
+	in, out := runDedupe(pipe.Dedupe(eventID, pipe.DedupeLRU(2)))
+
+	in <- event{id: 1, payload: "a"}
+	assert.Equal(t, "a", testers.ReadChannel(t, out, timeout).payload)
+	in <- event{id: 2, payload: "b"}
+	assert.Equal(t, "b", testers.ReadChannel(t, out, timeout).payload)
+	// duplicate is dropped, and 1 becomes the most recently seen key
+	in <- event{id: 1, payload: "dup"}
+	in <- event{id: 3, payload: "c"}
+	assert.Equal(t, "c", testers.ReadChannel(t, out, timeout).payload)
+	// 2 was evicted as the least recently seen key, so it is forwarded again
+	in <- event{id: 1, payload: "dup"}
+	in <- event{id: 2, payload: "b again"}
+	assert.Equal(t, "b again", testers.ReadChannel(t, out, timeout).payload)
+	close(in)
+	_, ok := <-out
+	assert.False(t, ok)
+}
+
+func TestDedupe_TTL(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+	in, out := runDedupe(pipe.Dedupe(eventID, pipe.DedupeLRU(0),
+		pipe.DedupeTTL(time.Minute), pipe.DedupeClock(clock)))
+
+	in <- event{id: 1, payload: "a"}
+	assert.Equal(t, "a", testers.ReadChannel(t, out, timeout).payload)
+	in <- event{id: 1, payload: "dup"}
+	in <- event{id: 2, payload: "b"}
+	assert.Equal(t, "b", testers.ReadChannel(t, out, timeout).payload)
+
+	clock.Advance(50 * time.Second)
+	in <- event{id: 2, payload: "dup"}
+	in <- event{id: 3, payload: "c"}
+	assert.Equal(t, "c", testers.ReadChannel(t, out, timeout).payload)
+
+	clock.Advance(50 * time.Second)
+	// id 1 expired but id 2 was seen again 50 seconds ago
+	in <- event{id: 2, payload: "dup"}
+	in <- event{id: 1, payload: "a again"}
+	assert.Equal(t, "a again", testers.ReadChannel(t, out, timeout).payload)
+	close(in)
+	_, ok := <-out
+	assert.False(t, ok)
+}
+
+func TestDedupe_Approximate(t *testing.T) {
+	in, out := runDedupe(pipe.Dedupe(eventID, pipe.DedupeApproximate(1000, 0.001)))
+
+	go func() {
+		for i := 0; i < 500; i++ {
+			in <- event{id: i}
+			in <- event{id: i}
+		}
+		close(in)
+	}()
+	forwarded := map[int]int{}
+	for e := range out {
+		forwarded[e.id]++
+	}
+	// false positives might drop a few unique items, but duplicates are never forwarded
+	assert.Greater(t, len(forwarded), 490)
+	for id, times := range forwarded {
+		assert.Equalf(t, 1, times, "id %d was forwarded %d times", id, times)
+	}
+}
+
+func runDedupe(fn pipe.MiddleFunc[event, event]) (chan<- event, <-chan event) {
+	in, out := make(chan event), make(chan event, 10)
+	go func() {
+		fn(in, out)
+		close(out)
+	}()
+	return in, out
+}
@@ -0,0 +1,10 @@
+package pipe
+
+// Unfused is an Option that prevents the Map and Filter nodes from being fused into the goroutine
+// of their sender, so their connections are benchmarked with the same number of goroutines as
+// the ring-buffered ones.
+func Unfused() Option {
+	return func(options *creationOptions) {
+		options.unfused = true
+	}
+}
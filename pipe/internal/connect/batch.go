@@ -0,0 +1,154 @@
+package connect
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NewBatchedJoiner creates a Joiner whose senders transfer the items in slices of up to the
+// provided size, so the number of channel operations per item is reduced. Each sender forwards
+// its slice when it's full, when flushLatency has passed since its first item was sent, or when
+// the sender is released. If flushLatency is 0, the slices are not forwarded until they are
+// full or the sender is released. The bufferLength is the number of slices that can be buffered.
+// The senders that acquire the Joiner as a channel send their items through an extra goroutine.
+func NewBatchedJoiner[IN any](bufferLength, size int, flushLatency time.Duration, clock Clock) Joiner[IN] {
+	if size < 1 {
+		size = 1
+	}
+	j := NewJoiner[IN](bufferLength)
+	j.transport = &batchTransport[IN]{
+		size:         size,
+		flushLatency: flushLatency,
+		clock:        clock,
+		batches:      make(chan []IN, bufferLength),
+	}
+	return j
+}
+
+type batchTransport[T any] struct {
+	size         int
+	flushLatency time.Duration
+	clock        Clock
+	batches      chan []T
+	// items counts the items of the forwarded batches that haven't been consumed
+	items atomic.Int64
+}
+
+func (bt *batchTransport[T]) pusher(spawn Spawner) (func(T), func()) {
+	b := &batcher[T]{bt: bt, items: make([]T, 0, bt.size)}
+	if bt.flushLatency <= 0 {
+		return b.push, b.flush
+	}
+	b.started = make(chan struct{}, 1)
+	spawn.Go(b.flushOnLatency)
+	return b.push, func() {
+		b.flush()
+		close(b.started)
+	}
+}
+
+func (bt *batchTransport[T]) close() {
+	close(bt.batches)
+}
+
+func (bt *batchTransport[T]) consume(fn func(T)) {
+	for batch := range bt.batches {
+		for _, item := range batch {
+			fn(item)
+		}
+		bt.items.Add(-int64(len(batch)))
+	}
+}
+
+func (bt *batchTransport[T]) len() int {
+	return int(bt.items.Load())
+}
+
+func (bt *batchTransport[T]) full() bool {
+	return len(bt.batches) == cap(bt.batches)
+}
+
+func (bt *batchTransport[T]) singlePusher() bool {
+	return false
+}
+
+// batcher accumulates the items of a single sender
+type batcher[T any] struct {
+	bt *batchTransport[T]
+	// mt is locked while the items are accumulated or forwarded, as the batch can be
+	// forwarded by both the sender and the flushOnLatency goroutine
+	mt    sync.Mutex
+	items []T
+	// deadline of the batch being accumulated, after which it must be forwarded
+	deadline time.Time
+	// started notifies the flushOnLatency goroutine that a new batch has been started.
+	// It's nil if there is no flush latency
+	started chan struct{}
+}
+
+func (b *batcher[T]) push(item T) {
+	b.mt.Lock()
+	defer b.mt.Unlock()
+	b.items = append(b.items, item)
+	if len(b.items) == 1 && b.started != nil {
+		b.deadline = b.bt.clock.Now().Add(b.bt.flushLatency)
+		// if there is already a pending notification, the flushOnLatency goroutine will
+		// read the new deadline anyway
+		select {
+		case b.started <- struct{}{}:
+		default:
+		}
+	}
+	if len(b.items) >= b.bt.size {
+		b.forward()
+	}
+}
+
+func (b *batcher[T]) flush() {
+	b.mt.Lock()
+	defer b.mt.Unlock()
+	if len(b.items) > 0 {
+		b.forward()
+	}
+}
+
+// forward the accumulated items. It must be invoked with the lock held
+func (b *batcher[T]) forward() {
+	b.bt.items.Add(int64(len(b.items)))
+	b.bt.batches <- b.items
+	b.items = make([]T, 0, b.bt.size)
+}
+
+// flushOnLatency forwards the batches whose deadline has passed, until the sender is released.
+// There is at most one pending timer at a time: when a batch is forwarded because it's full
+// before its timer fires, the timer is reset to the deadline of the next batch instead of
+// creating a new timer for it.
+func (b *batcher[T]) flushOnLatency() {
+	var timeout <-chan time.Time
+	stop := func() bool { return false }
+	defer func() { stop() }()
+	for {
+		select {
+		case _, ok := <-b.started:
+			if !ok {
+				return
+			}
+			if timeout == nil {
+				timeout, stop = b.bt.clock.NewTimer(b.bt.flushLatency)
+			}
+		case <-timeout:
+			timeout = nil
+			b.mt.Lock()
+			if len(b.items) > 0 {
+				if wait := b.deadline.Sub(b.bt.clock.Now()); wait > 0 {
+					// the timer was started for a batch that has been already forwarded
+					timeout, stop = b.bt.clock.NewTimer(wait)
+				} else {
+					b.forward()
+				}
+			}
+			b.mt.Unlock()
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package connect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	helpers "github.com/mariomac/pipes/testers"
+)
+
+// consumeAll forwards the items of the Joiner to the returned channel, which is closed when
+// all the senders are released
+func consumeAll[T any](j *Joiner[T]) <-chan T {
+	out := make(chan T, 100)
+	go func() {
+		j.Consume(func(i T) { out <- i })
+		close(out)
+	}()
+	return out
+}
+
+func TestBatchedJoiner(t *testing.T) {
+	j := NewBatchedJoiner[int](10, 3, 0, nil)
+	push, release := j.AcquirePusher()
+	sender := j.AcquireSender()
+
+	for i := 1; i <= 4; i++ {
+		push(i)
+	}
+	// the pending items are not forwarded until the batch is full
+	assert.Equal(t, 3, j.Buffered())
+	out := consumeAll(&j)
+	for i := 1; i <= 3; i++ {
+		assert.Equal(t, i, helpers.ReadChannel(t, out, timeout))
+	}
+	// the items from the senders that acquired the channel are batched by another goroutine
+	sender <- 10
+	j.ReleaseSender()
+	// the incomplete batches are forwarded when the senders are released
+	release()
+	assert.Equal(t, 4, helpers.ReadChannel(t, out, timeout))
+	assert.Equal(t, 10, helpers.ReadChannel(t, out, timeout))
+	_, ok := <-out
+	assert.False(t, ok)
+	assert.Zero(t, j.Buffered())
+}
+
+func TestBatchedJoiner_FlushLatency(t *testing.T) {
+	clock := helpers.NewFakeClock(time.Now())
+	j := NewBatchedJoiner[int](10, 3, time.Second, clock)
+	push, release := j.AcquirePusher()
+	out := consumeAll(&j)
+
+	push(1)
+	clock.BlockUntil(1)
+	clock.Advance(999 * time.Millisecond)
+	assert.Zero(t, j.Buffered())
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, 1, helpers.ReadChannel(t, out, timeout))
+
+	// a full batch is forwarded before its timer fires
+	push(2)
+	clock.BlockUntil(1)
+	clock.Advance(500 * time.Millisecond)
+	push(3)
+	push(4)
+	assert.Equal(t, 2, helpers.ReadChannel(t, out, timeout))
+	assert.Equal(t, 3, helpers.ReadChannel(t, out, timeout))
+	assert.Equal(t, 4, helpers.ReadChannel(t, out, timeout))
+
+	// the pending timer of the forwarded batch doesn't flush the next batch before its
+	// deadline, and it's reset instead of creating another timer
+	push(5)
+	clock.Advance(500 * time.Millisecond)
+	clock.BlockUntil(1)
+	assert.Equal(t, 1, clock.Timers())
+	assert.Zero(t, j.Buffered())
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, 5, helpers.ReadChannel(t, out, timeout))
+
+	release()
+	_, ok := <-out
+	assert.False(t, ok)
+}
+
+func TestBatchedJoiner_FanOut(t *testing.T) {
+	j1 := NewBatchedJoiner[int](10, 2, 0, nil)
+	j2 := NewJoiner[int](10)
+	forker := Fork(&j1, &j2)
+	out1, out2 := consumeAll(&j1), consumeAll(&j2)
+	sender := forker.AcquireSender()
+	for i := 1; i <= 3; i++ {
+		sender <- i
+	}
+	forker.ReleaseSender()
+	for i := 1; i <= 3; i++ {
+		assert.Equal(t, i, helpers.ReadChannel(t, out1, timeout))
+		assert.Equal(t, i, helpers.ReadChannel(t, out2, timeout))
+	}
+	_, ok := <-out1
+	assert.False(t, ok)
+}
@@ -1,6 +1,7 @@
 package connect
 
 import (
+	"sync"
 	"sync/atomic"
 )
 
@@ -9,37 +10,209 @@ type Joiner[IN any] struct {
 	totalSenders int32
 	bufLen       int
 	channel      chan IN
+	// transport is nil if the items are sent through the channel. Otherwise, the channel
+	// only receives the items of the senders that acquire it, which are pushed to the
+	// transport by the ingress goroutine
+	transport   transport[IN]
+	ingressOnce *sync.Once
+	ingress     bool
+	// received forwards the items of the transport to the receiver, if it reads them from a channel
+	receivedOnce *sync.Once
+	received     chan IN
+	// spawn starts the goroutines that forward the items between the channels and the transport
+	spawn Spawner
+	// probed forwards the items of the channel to the receiver through the probe.
+	// Both are nil if the Joiner is not watched
+	probed chan IN
+	probe  *Probe
 }
 
 // NewJoiner creates a joiner for a given channel type and buffer length
 func NewJoiner[IN any](bufferLength int) Joiner[IN] {
 	return Joiner[IN]{
-		bufLen:  bufferLength,
-		channel: make(chan IN, bufferLength),
+		bufLen:       bufferLength,
+		channel:      make(chan IN, bufferLength),
+		ingressOnce:  &sync.Once{},
+		receivedOnce: &sync.Once{},
 	}
 }
 
-// Receiver gets access to the channel as a receiver
+// Track sets the Spawner that starts the goroutines of the Joiner. It must be invoked before
+// any other method.
+func (j *Joiner[IN]) Track(spawn Spawner) {
+	j.spawn = spawn
+}
+
+// ExpectSenders informs the Joiner about the number of sender nodes that will acquire it.
+// It must be invoked before any of them acquires it. The Joiners whose transport supports
+// a single sender use their channel if there is more than one.
+func (j *Joiner[IN]) ExpectSenders(senders int) {
+	if j.transport != nil && j.transport.singlePusher() && senders > 1 {
+		j.transport = nil
+	}
+}
+
+// Receiver gets access to the channel as a receiver. If the Joiner is watched, the
+// returned channel forwards the items through the Probe. If the Joiner has a transport
+// other than a channel, its items are forwarded to the returned channel by an extra goroutine.
 func (j *Joiner[IN]) Receiver() chan IN {
-	return j.channel
+	if j.probed != nil {
+		return j.probed
+	}
+	if j.transport == nil {
+		return j.channel
+	}
+	j.receivedOnce.Do(func() {
+		j.received = make(chan IN)
+		j.spawn.Go(func() {
+			j.transport.consume(func(item IN) {
+				j.received <- item
+			})
+			close(j.received)
+		})
+	})
+	return j.received
+}
+
+// Consume invokes the provided function for each received item, until all the senders
+// are released. Contrary to Receiver, it doesn't require any extra goroutine nor channel
+// operation for the Joiners with a transport. It must be invoked instead of Receiver.
+func (j *Joiner[IN]) Consume(fn func(IN)) {
+	if j.probed != nil {
+		for item := range j.probed {
+			fn(item)
+		}
+		return
+	}
+	j.consume(fn)
+}
+
+func (j *Joiner[IN]) consume(fn func(IN)) {
+	if j.transport != nil {
+		j.transport.consume(fn)
+		return
+	}
+	for item := range j.channel {
+		fn(item)
+	}
+}
+
+// Buffered returns the number of items that are waiting to be received, including the
+// item that a Probe is trying to forward.
+func (j *Joiner[IN]) Buffered() int {
+	buffered := len(j.channel)
+	if j.transport != nil {
+		buffered += j.transport.len()
+	}
+	if j.probe != nil {
+		if _, waiting := j.probe.Progress(); waiting {
+			buffered++
+		}
+	}
+	return buffered
+}
+
+// Full returns true if the senders of the Joiner block until the receiver reads an item.
+func (j *Joiner[IN]) Full() bool {
+	if j.transport != nil {
+		return j.transport.full()
+	}
+	return len(j.channel) == cap(j.channel)
+}
+
+// Watch makes the receiver of the Joiner receive the items through a Probe, which is returned.
+// The items are forwarded by a goroutine, started with the provided Spawner, that holds one
+// item at a time. It must be invoked before Receiver and Consume.
+func (j *Joiner[IN]) Watch(spawn Spawner) *Probe {
+	j.probe = &Probe{}
+	j.probed = make(chan IN)
+	spawn.Go(func() {
+		j.consume(func(item IN) {
+			j.probe.state.Add(1)
+			j.probed <- item
+			j.probe.state.Add(1)
+		})
+		close(j.probed)
+	})
+	return j.probe
 }
 
 // AcquireSender gets acces to the channel as a sender. The acquirer must finally invoke
 // ReleaseSender to make sure that the channel is closed when all the senders released it.
 func (j *Joiner[IN]) AcquireSender() chan IN {
 	atomic.AddInt32(&j.totalSenders, 1)
+	return j.sender()
+}
+
+// sender returns the channel of the Joiner, starting the ingress goroutine if the Joiner
+// has a transport
+func (j *Joiner[IN]) sender() chan IN {
+	if j.transport != nil {
+		j.ingressOnce.Do(func() {
+			j.ingress = true
+			push, flush := j.transport.pusher(j.spawn)
+			j.spawn.Go(func() {
+				for item := range j.channel {
+					push(item)
+				}
+				flush()
+				j.transport.close()
+			})
+		})
+	}
 	return j.channel
 }
 
+// AcquirePusher gets access to the Joiner as a sender, through a function that sends each item.
+// Contrary to AcquireSender, it doesn't require any extra goroutine nor channel operation for the
+// Joiners with a transport. The returned push function must be invoked from a single goroutine,
+// and the returned release function must be invoked after the last item, instead of ReleaseSender.
+func (j *Joiner[IN]) AcquirePusher() (push func(IN), release func()) {
+	atomic.AddInt32(&j.totalSenders, 1)
+	push, flush := j.pusher()
+	return push, func() {
+		flush()
+		j.ReleaseSender()
+	}
+}
+
+func (j *Joiner[IN]) pusher() (func(IN), func()) {
+	if j.transport != nil {
+		return j.transport.pusher(j.spawn)
+	}
+	ch := j.channel
+	return func(item IN) { ch <- item }, func() {}
+}
+
 // ReleaseSender will close the channel when all the invokers of the AcquireSender have invoked
 // this function
 func (j *Joiner[IN]) ReleaseSender() {
 	// if no senders, we close the main channel
 	if atomic.AddInt32(&j.totalSenders, -1) == 0 {
-		close(j.channel)
+		if j.transport == nil || j.ingress {
+			// the ingress goroutine closes the transport after forwarding the channel contents
+			close(j.channel)
+		} else {
+			j.transport.close()
+		}
 	}
 }
 
+// Probe counts the items that a receiver reads from a Joiner, so the receivers that stopped
+// reading can be detected.
+type Probe struct {
+	// state is incremented once when the probe starts forwarding an item, and once when the
+	// item is received, so it's odd while the probe is waiting for the receiver
+	state atomic.Uint64
+}
+
+// Progress returns a value that changes each time that the probe starts forwarding an item
+// or the receiver reads it, and whether the probe is waiting for the receiver to read an item.
+func (p *Probe) Progress() (uint64, bool) {
+	state := p.state.Load()
+	return state, state%2 == 1
+}
+
 // Releaser is a function that will allow releasing a forked channel.
 type Releaser func()
 
@@ -50,39 +223,53 @@ type Forker[OUT any] struct {
 	totalSenders   int32
 	sendCh         chan OUT
 	releaseChannel Releaser
+	// joiner is set if the Forker directly sends the data to a single Joiner, whose sendCh is
+	// only used by the senders that acquire the Forker as a channel
+	joiner *Joiner[OUT]
 }
 
 // Fork provides connection to a group of output Nodes, accessible through their respective
 // Joiner instances.
 func Fork[T any](joiners ...*Joiner[T]) Forker[T] {
+	return fork(nil, joiners...)
+}
+
+// fork is equivalent to Fork, starting the forwarding goroutine with the provided Spawner
+func fork[T any](spawn Spawner, joiners ...*Joiner[T]) Forker[T] {
 	if len(joiners) == 0 {
 		panic("can't fork 0 joiners")
 	}
-	// if there is only one joiner, we directly send the data to the channel, without intermediation
+	// if there is only one joiner, we directly send the data to it, without intermediation
 	if len(joiners) == 1 {
+		atomic.AddInt32(&joiners[0].totalSenders, 1)
 		return Forker[T]{
-			sendCh:         joiners[0].AcquireSender(),
+			joiner:         joiners[0],
 			releaseChannel: joiners[0].ReleaseSender,
 		}
 	}
 	// channel used as input from the source Node
 	sendCh := make(chan T, joiners[0].bufLen)
 
-	// channels that clone the contents of the sendCh
-	forwarders := make([]chan T, len(joiners))
+	// functions that clone the contents of the sendCh
+	pushers := make([]func(T), len(joiners))
+	releasers := make([]func(), len(joiners))
 	for i := 0; i < len(joiners); i++ {
-		forwarders[i] = joiners[i].AcquireSender()
+		pushers[i], releasers[i] = joiners[i].AcquirePusher()
 	}
-	go func() {
+	refs := refCounted[T]()
+	spawn.Go(func() {
 		for in := range sendCh {
+			if refs {
+				share(in, len(joiners))
+			}
 			for i := 0; i < len(joiners); i++ {
-				forwarders[i] <- in
+				pushers[i](in)
 			}
 		}
 		for i := 0; i < len(joiners); i++ {
-			joiners[i].ReleaseSender()
+			releasers[i]()
 		}
-	}()
+	})
 	return Forker[T]{
 		sendCh:         sendCh,
 		releaseChannel: func() { close(sendCh) },
@@ -93,9 +280,30 @@ func Fork[T any](joiners ...*Joiner[T]) Forker[T] {
 // Each call to AcquireSender requires an eventual call to ReleaseSender
 func (f *Forker[OUT]) AcquireSender() chan OUT {
 	atomic.AddInt32(&f.totalSenders, 1)
+	if f.joiner != nil {
+		return f.joiner.sender()
+	}
 	return f.sendCh
 }
 
+// AcquirePusher acquires a function that sends each item from the source node. Contrary to
+// AcquireSender, it doesn't require any extra goroutine nor channel operation when the Forker
+// sends the data to a single Joiner with a transport. The returned push function must be invoked
+// from a single goroutine, and the returned release function must be invoked after the last item,
+// instead of ReleaseSender.
+func (f *Forker[OUT]) AcquirePusher() (push func(OUT), release func()) {
+	atomic.AddInt32(&f.totalSenders, 1)
+	if f.joiner != nil {
+		push, flush := f.joiner.pusher()
+		return push, func() {
+			flush()
+			f.ReleaseSender()
+		}
+	}
+	ch := f.sendCh
+	return func(item OUT) { ch <- item }, f.ReleaseSender
+}
+
 // ReleaseSender closes the input channel when the number of invocations is equal to AcquireSender invocations.
 func (f *Forker[OUT]) ReleaseSender() {
 	if atomic.AddInt32(&f.totalSenders, -1) == 0 {
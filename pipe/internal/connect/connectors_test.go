@@ -99,3 +99,32 @@ func TestForker(t *testing.T) {
 		close(r)
 	})
 }
+
+func TestJoiner_Watch(t *testing.T) {
+	j := NewJoiner[int](1)
+	probe := j.Watch(nil)
+	progress, waiting := probe.Progress()
+	assert.False(t, waiting)
+
+	sender := j.AcquireSender()
+	sender <- 1
+	// the probe holds the item until the receiver reads it
+	for i := 0; i < 100 && !waiting; i++ {
+		time.Sleep(10 * time.Millisecond)
+		_, waiting = probe.Progress()
+	}
+	assert.True(t, waiting)
+	assert.Equal(t, 1, j.Buffered())
+	sender <- 2
+	assert.True(t, j.Full())
+	assert.Equal(t, 2, j.Buffered())
+
+	assert.Equal(t, 1, helpers.ReadChannel(t, j.Receiver(), timeout))
+	newProgress, _ := probe.Progress()
+	assert.NotEqual(t, progress, newProgress)
+
+	j.ReleaseSender()
+	assert.Equal(t, 2, helpers.ReadChannel(t, j.Receiver(), timeout))
+	_, ok := <-j.Receiver()
+	assert.False(t, ok)
+}
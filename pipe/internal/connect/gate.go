@@ -0,0 +1,120 @@
+package connect
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Gate blocks the forwarding of data while it is closed. The zero value is an open Gate.
+type Gate struct {
+	mt sync.Mutex
+	// wait is nil while the gate is open. Otherwise, it's a channel that is closed on Open
+	wait atomic.Pointer[chan struct{}]
+	// stopped is closed on Stop
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func (g *Gate) stopChan() chan struct{} {
+	g.mt.Lock()
+	defer g.mt.Unlock()
+	if g.stopped == nil {
+		g.stopped = make(chan struct{})
+	}
+	return g.stopped
+}
+
+// Close the gate, so the Forkers created by ForkGated will stop forwarding data until the Gate is open.
+// It returns false if the gate was already closed.
+func (g *Gate) Close() bool {
+	g.mt.Lock()
+	defer g.mt.Unlock()
+	if g.wait.Load() != nil {
+		return false
+	}
+	wait := make(chan struct{})
+	g.wait.Store(&wait)
+	return true
+}
+
+// Open the gate, so the Forkers created by ForkGated resume forwarding data.
+// It returns false if the gate was already open.
+func (g *Gate) Open() bool {
+	g.mt.Lock()
+	defer g.mt.Unlock()
+	wait := g.wait.Load()
+	if wait == nil {
+		return false
+	}
+	g.wait.Store(nil)
+	close(*wait)
+	return true
+}
+
+// IsClosed returns whether the gate is closed.
+func (g *Gate) IsClosed() bool {
+	return g.wait.Load() != nil
+}
+
+// Stop the gate permanently: the Forkers created by ForkGated release their destination, so
+// the destination channels are closed, and discard any further data that is sent to them.
+func (g *Gate) Stop() {
+	stopped := g.stopChan()
+	g.stopOnce.Do(func() {
+		close(stopped)
+	})
+}
+
+// pass blocks until the gate is open or stopped
+func (g *Gate) pass(stopped <-chan struct{}) {
+	if wait := g.wait.Load(); wait != nil {
+		select {
+		case <-*wait:
+		case <-stopped:
+		}
+	}
+}
+
+// ForkGated returns a Forker that forwards the data to the provided Forker as long as the Gate is open.
+// When the Gate is closed, the items are retained and the senders of the returned Forker eventually
+// block until the Gate is open again.
+// When the Gate is stopped, the provided Forker is released and any further data is discarded.
+// The discarded RefCounted items are released.
+// The items that were already received before stopping the Gate are still forwarded.
+// The forwarding goroutine is started with the provided Spawner, which can be nil.
+func ForkGated[T any](spawn Spawner, forker *Forker[T], gate *Gate, bufLen int) Forker[T] {
+	sendCh := make(chan T, bufLen)
+	dst := forker.AcquireSender()
+	stopped := gate.stopChan()
+	spawn.Go(func() {
+		forward(sendCh, dst, gate, stopped)
+		forker.ReleaseSender()
+		// discard the data from the senders that are still running after the gate was stopped
+		refs := refCounted[T]()
+		for in := range sendCh {
+			if refs {
+				share(in, 0)
+			}
+		}
+	})
+	return Forker[T]{
+		sendCh:         sendCh,
+		releaseChannel: func() { close(sendCh) },
+	}
+}
+
+// forward the items from src to dst until src is closed or the gate is stopped
+func forward[T any](src <-chan T, dst chan<- T, gate *Gate, stopped <-chan struct{}) {
+	for {
+		select {
+		case in, ok := <-src:
+			if !ok {
+				return
+			}
+			gate.pass(stopped)
+			dst <- in
+		case <-stopped:
+			return
+		}
+	}
+}
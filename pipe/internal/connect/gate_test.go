@@ -0,0 +1,41 @@
+package connect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	helpers "github.com/mariomac/pipes/testers"
+)
+
+func TestForkGated(t *testing.T) {
+	joiner := NewJoiner[int](0)
+	dst := Fork(&joiner)
+	gate := &Gate{}
+	f := ForkGated(nil, &dst, gate, 0)
+	sender := f.AcquireSender()
+
+	sender <- 1
+	assert.Equal(t, 1, helpers.ReadChannel(t, joiner.Receiver(), timeout))
+
+	assert.True(t, gate.Close())
+	assert.False(t, gate.Close())
+	assert.True(t, gate.IsClosed())
+	sender <- 2
+	select {
+	case i := <-joiner.Receiver():
+		assert.Failf(t, "gate is closed", "unexpected item %v", i)
+	case <-time.After(50 * time.Millisecond):
+		// ok!
+	}
+
+	assert.True(t, gate.Open())
+	assert.False(t, gate.Open())
+	assert.False(t, gate.IsClosed())
+	assert.Equal(t, 2, helpers.ReadChannel(t, joiner.Receiver(), timeout))
+
+	f.ReleaseSender()
+	_, ok := <-joiner.Receiver()
+	assert.False(t, ok)
+}
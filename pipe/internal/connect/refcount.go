@@ -0,0 +1,33 @@
+package connect
+
+// RefCounted items keep track of the number of destinations that still have to process them,
+// e.g. to return them to a pool when the last destination has finished.
+// When a Forker sends a RefCounted item to N destinations, it adds N-1 references to the item
+// before sending it. When the item is not sent to any destination (e.g. because it is not
+// accepted by any Route), the Forker releases it.
+type RefCounted interface {
+	// Retain adds n references to the item
+	Retain(n int)
+	// Release removes a reference from the item
+	Release()
+}
+
+// refCounted returns whether the items of the type T implement RefCounted. T must be a
+// concrete type: the items of an interface type are never reference-counted.
+func refCounted[T any]() bool {
+	var item T
+	_, ok := any(item).(RefCounted)
+	return ok
+}
+
+// share updates the references of an item that is going to be sent to the given number of
+// destinations. It must be invoked before sending the item to any of them.
+func share[T any](item T, destinations int) {
+	rc := any(item).(RefCounted)
+	switch {
+	case destinations == 0:
+		rc.Release()
+	case destinations > 1:
+		rc.Retain(destinations - 1)
+	}
+}
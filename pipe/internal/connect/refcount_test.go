@@ -0,0 +1,207 @@
+package connect
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	helpers "github.com/mariomac/pipes/testers"
+)
+
+// refItem starts with a reference and is freed when it's released by all its holders
+type refItem struct {
+	id    int
+	refs  atomic.Int32
+	freed atomic.Bool
+	// misused is set if the item is retained or released after being freed
+	misused atomic.Bool
+}
+
+func newRefItems(n int) []*refItem {
+	items := make([]*refItem, n)
+	for i := range items {
+		items[i] = &refItem{id: i}
+		items[i].refs.Store(1)
+	}
+	return items
+}
+
+func (r *refItem) Retain(n int) {
+	if r.freed.Load() {
+		r.misused.Store(true)
+	}
+	r.refs.Add(int32(n))
+}
+
+func (r *refItem) Release() {
+	if r.freed.Load() {
+		r.misused.Store(true)
+	}
+	if r.refs.Add(-1) == 0 {
+		r.freed.Store(true)
+	}
+}
+
+// releaseAll releases all the items received by the provided joiners
+func releaseAll(t *testing.T, joiners ...*Joiner[*refItem]) {
+	t.Helper()
+	finished := helpers.AsyncWait(len(joiners))
+	for _, j := range joiners {
+		j := j
+		go func() {
+			for item := range j.Receiver() {
+				item.Release()
+			}
+			finished.Done()
+		}()
+	}
+	finished.Wait(t, timeout)
+}
+
+func assertFreed(t *testing.T, items []*refItem) {
+	t.Helper()
+	for _, item := range items {
+		assert.Truef(t, item.freed.Load(), "item %d should have been freed", item.id)
+		assert.Zerof(t, item.refs.Load(), "item %d references", item.id)
+		assert.Falsef(t, item.misused.Load(), "item %d was used after being freed", item.id)
+	}
+}
+
+func TestFork_RefCounted(t *testing.T) {
+	j1, j2, j3 := NewJoiner[*refItem](0), NewJoiner[*refItem](0), NewJoiner[*refItem](0)
+	f := Fork(&j1, &j2, &j3)
+	items := newRefItems(100)
+	go func() {
+		sender := f.AcquireSender()
+		for _, item := range items {
+			sender <- item
+		}
+		f.ReleaseSender()
+	}()
+	releaseAll(t, &j1, &j2, &j3)
+	assertFreed(t, items)
+}
+
+func TestForkRoutes_RefCounted(t *testing.T) {
+	for _, mode := range []RoutingMode{RouteAll, RouteFirst} {
+		evens, smalls, defaults := NewJoiner[*refItem](0), NewJoiner[*refItem](0), NewJoiner[*refItem](0)
+		f := ForkRoutes(nil, mode,
+			Route[*refItem]{Joiners: []*Joiner[*refItem]{&evens}, Accept: func(i *refItem) bool { return i.id%2 == 0 }},
+			Route[*refItem]{Joiners: []*Joiner[*refItem]{&smalls}, Accept: func(i *refItem) bool { return i.id < 50 }},
+			Route[*refItem]{Joiners: []*Joiner[*refItem]{&defaults}, Default: true},
+		)
+		items := newRefItems(100)
+		go func() {
+			sender := f.AcquireSender()
+			for _, item := range items {
+				sender <- item
+			}
+			f.ReleaseSender()
+		}()
+		releaseAll(t, &evens, &smalls, &defaults)
+		assertFreed(t, items)
+	}
+}
+
+func TestForkRoutes_RefCounted_NoDestination(t *testing.T) {
+	evens := NewJoiner[*refItem](0)
+	f := ForkRoutes(nil, RouteAll,
+		Route[*refItem]{Joiners: []*Joiner[*refItem]{&evens}, Accept: func(i *refItem) bool { return i.id%2 == 0 }},
+	)
+	items := newRefItems(10)
+	go func() {
+		sender := f.AcquireSender()
+		for _, item := range items {
+			sender <- item
+		}
+		f.ReleaseSender()
+	}()
+	// the odd items are released by the Forker
+	releaseAll(t, &evens)
+	assertFreed(t, items)
+}
+
+func TestForkTaps_RefCounted(t *testing.T) {
+	static1, static2 := NewJoiner[*refItem](0), NewJoiner[*refItem](0)
+	tap := NewJoiner[*refItem](0)
+	taps := Taps[*refItem]{}
+	assert.NoError(t, taps.Attach(&tap))
+	static := Fork(&static1, &static2)
+	f := ForkTaps(nil, &static, &taps, 0, nil)
+	items := newRefItems(100)
+	go func() {
+		sender := f.AcquireSender()
+		for _, item := range items {
+			sender <- item
+		}
+		f.ReleaseSender()
+	}()
+	releaseAll(t, &static1, &static2, &tap)
+	assertFreed(t, items)
+}
+
+func TestForkRoutes_RefCounted_Clone(t *testing.T) {
+	shared, cloned := NewJoiner[*refItem](0), NewJoiner[*refItem](0)
+	var clones []*refItem
+	f := ForkRoutes(nil, RouteAll,
+		Route[*refItem]{Joiners: []*Joiner[*refItem]{&shared}},
+		Route[*refItem]{Joiners: []*Joiner[*refItem]{&cloned}, Clone: cloneInto(&clones)},
+	)
+	items := newRefItems(100)
+	go func() {
+		sender := f.AcquireSender()
+		for _, item := range items {
+			sender <- item
+		}
+		f.ReleaseSender()
+	}()
+	// the original items are only retained for the shared route
+	releaseAll(t, &shared, &cloned)
+	assertFreed(t, items)
+	assertFreed(t, clones)
+}
+
+func TestForkRoutes_RefCounted_ClonedOnly(t *testing.T) {
+	cloned1, cloned2 := NewJoiner[*refItem](0), NewJoiner[*refItem](0)
+	var clones []*refItem
+	var observed atomic.Int32
+	f := ForkRoutes(nil, RouteAll,
+		Route[*refItem]{Joiners: []*Joiner[*refItem]{&cloned1}, Clone: cloneInto(&clones)},
+		Route[*refItem]{Joiners: []*Joiner[*refItem]{&cloned2}, Clone: cloneInto(&clones),
+			Observe: func(i *refItem) {
+				if i.freed.Load() {
+					i.misused.Store(true)
+				}
+				observed.Add(1)
+			}},
+	)
+	items := newRefItems(100)
+	go func() {
+		sender := f.AcquireSender()
+		for _, item := range items {
+			sender <- item
+		}
+		f.ReleaseSender()
+	}()
+	// the original items are not forwarded to any destination, so they are released by the
+	// Forker after all the routes have cloned and observed them
+	releaseAll(t, &cloned1, &cloned2)
+	assertFreed(t, items)
+	assertFreed(t, clones)
+	assert.EqualValues(t, 100, observed.Load())
+}
+
+// cloneInto returns a Route Clone function that stores the clones into the provided slice.
+// Cloning an item that has been freed marks it as misused.
+func cloneInto(clones *[]*refItem) func(*refItem) *refItem {
+	return func(i *refItem) *refItem {
+		if i.freed.Load() {
+			i.misused.Store(true)
+		}
+		cp := newRefItems(1)[0]
+		cp.id = i.id
+		*clones = append(*clones, cp)
+		return cp
+	}
+}
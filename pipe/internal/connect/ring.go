@@ -0,0 +1,179 @@
+package connect
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// NewRingJoiner creates a Joiner whose sender transfers the items through a Ring of the provided size,
+// instead of through a channel. As the Ring supports a single producer, the Joiner must be acquired by
+// a single sender, or ExpectSenders must be invoked before with the number of senders, so the Joiner
+// uses a channel if there is more than one. The senders that acquire the Joiner as a channel, whose
+// buffer length is bufferLength, send their items through an extra goroutine.
+func NewRingJoiner[IN any](bufferLength, size int) Joiner[IN] {
+	j := NewJoiner[IN](bufferLength)
+	j.transport = &ringTransport[IN]{ring: NewRing[IN](size)}
+	return j
+}
+
+type ringTransport[T any] struct {
+	ring *Ring[T]
+}
+
+func (rt *ringTransport[T]) pusher(Spawner) (func(T), func()) {
+	return rt.ring.Push, func() {}
+}
+
+func (rt *ringTransport[T]) close() {
+	rt.ring.Close()
+}
+
+func (rt *ringTransport[T]) consume(fn func(T)) {
+	for item, ok := rt.ring.Pop(); ok; item, ok = rt.ring.Pop() {
+		fn(item)
+	}
+}
+
+func (rt *ringTransport[T]) len() int {
+	return rt.ring.Len()
+}
+
+func (rt *ringTransport[T]) full() bool {
+	return rt.ring.Len() == rt.ring.Cap()
+}
+
+func (rt *ringTransport[T]) singlePusher() bool {
+	return true
+}
+
+// ringSpins is the number of times that a blocked Ring operation yields the processor before
+// parking its goroutine
+const ringSpins = 64
+
+// cacheLinePad prevents false sharing between the fields that are written by the producer
+// and the fields that are written by the consumer
+type cacheLinePad [64]byte
+
+// Ring is a bounded queue for a single producer goroutine and a single consumer goroutine.
+// The items are exchanged through a circular buffer without locks. When the buffer is full
+// (for the producer) or empty (for the consumer), the blocked goroutine spins for a while
+// before being parked until the other side makes progress.
+type Ring[T any] struct {
+	buf  []T
+	mask uint64
+
+	_ cacheLinePad
+	// head is the position of the next item to read. It's only written by the consumer
+	head atomic.Uint64
+	// consumerParked is true while the consumer waits for wakeConsumer
+	consumerParked atomic.Bool
+	wakeConsumer   chan struct{}
+
+	_ cacheLinePad
+	// tail is the position of the next item to write. It's only written by the producer
+	tail atomic.Uint64
+	// producerParked is true while the producer waits for wakeProducer
+	producerParked atomic.Bool
+	wakeProducer   chan struct{}
+	closed         atomic.Bool
+}
+
+// NewRing creates a Ring whose capacity is the provided size, rounded up to the next power of two.
+func NewRing[T any](size int) *Ring[T] {
+	capacity := 1
+	for capacity < size {
+		capacity <<= 1
+	}
+	return &Ring[T]{
+		buf:          make([]T, capacity),
+		mask:         uint64(capacity - 1),
+		wakeConsumer: make(chan struct{}, 1),
+		wakeProducer: make(chan struct{}, 1),
+	}
+}
+
+// Push appends an item to the ring, blocking while the ring is full.
+// It must be only invoked from the producer goroutine, and never after Close.
+func (r *Ring[T]) Push(item T) {
+	tail := r.tail.Load()
+	capacity := uint64(len(r.buf))
+	for spins := 0; tail-r.head.Load() == capacity; spins++ {
+		r.wait(spins, &r.producerParked, r.wakeProducer, func() bool {
+			return tail-r.head.Load() < capacity
+		})
+	}
+	r.buf[tail&r.mask] = item
+	r.tail.Store(tail + 1)
+	wake(&r.consumerParked, r.wakeConsumer)
+}
+
+// Pop removes the oldest item from the ring, blocking while the ring is empty.
+// It returns false if the ring is empty and closed.
+// It must be only invoked from the consumer goroutine.
+func (r *Ring[T]) Pop() (T, bool) {
+	head := r.head.Load()
+	for spins := 0; head == r.tail.Load(); spins++ {
+		// the producer doesn't push after closing, so the tail needs to be checked again
+		// after observing the ring as closed
+		if r.closed.Load() && head == r.tail.Load() {
+			var zero T
+			return zero, false
+		}
+		r.wait(spins, &r.consumerParked, r.wakeConsumer, func() bool {
+			return head != r.tail.Load() || r.closed.Load()
+		})
+	}
+	idx := head & r.mask
+	item := r.buf[idx]
+	// releasing the reference, so the item can be garbage collected
+	var zero T
+	r.buf[idx] = zero
+	r.head.Store(head + 1)
+	wake(&r.producerParked, r.wakeProducer)
+	return item, true
+}
+
+// Close the ring, so Pop returns false after all the pushed items are consumed.
+// It must be only invoked from the producer goroutine.
+func (r *Ring[T]) Close() {
+	r.closed.Store(true)
+	wake(&r.consumerParked, r.wakeConsumer)
+}
+
+// Len returns the number of items that are waiting in the ring.
+func (r *Ring[T]) Len() int {
+	return int(r.tail.Load() - r.head.Load())
+}
+
+// Cap returns the maximum number of items that the ring can store.
+func (r *Ring[T]) Cap() int {
+	return len(r.buf)
+}
+
+// wait yields the processor during the first ringSpins iterations of a blocked operation.
+// Later, it parks the goroutine until it's woken up by the other side, unless the ready
+// condition becomes true after announcing that it's parked.
+func (r *Ring[T]) wait(spins int, parked *atomic.Bool, wakeCh chan struct{}, ready func() bool) {
+	if spins < ringSpins {
+		runtime.Gosched()
+		return
+	}
+	parked.Store(true)
+	if ready() {
+		parked.Store(false)
+		return
+	}
+	<-wakeCh
+}
+
+// wake unparks the other side of the ring if it's parked. The wake channel is buffered,
+// so a wakeup is not lost if it happens between the parked flag being set and the
+// other side receiving from the channel.
+func wake(parked *atomic.Bool, wakeCh chan struct{}) {
+	if parked.CompareAndSwap(true, false) {
+		select {
+		case wakeCh <- struct{}{}:
+		default:
+		}
+	}
+}
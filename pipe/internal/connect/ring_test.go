@@ -0,0 +1,182 @@
+package connect
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	helpers "github.com/mariomac/pipes/testers"
+)
+
+func TestRing(t *testing.T) {
+	r := NewRing[int](5)
+	assert.Equal(t, 8, r.Cap())
+
+	const items = 10_000
+	go func() {
+		for i := 0; i < items; i++ {
+			r.Push(i)
+		}
+		r.Close()
+	}()
+	finished := helpers.AsyncWait(1)
+	var received []int
+	go func() {
+		for {
+			i, ok := r.Pop()
+			if !ok {
+				break
+			}
+			received = append(received, i)
+		}
+		finished.Done()
+	}()
+	finished.Wait(t, timeout)
+
+	require.Len(t, received, items)
+	for i, n := range received {
+		require.Equal(t, i, n)
+	}
+	assert.Zero(t, r.Len())
+}
+
+func TestRing_ParkWhileFull(t *testing.T) {
+	r := NewRing[int](2)
+	pushed := make(chan int, 10)
+	go func() {
+		for i := 0; i < 5; i++ {
+			r.Push(i)
+			pushed <- i
+		}
+		r.Close()
+	}()
+	assert.Equal(t, 0, helpers.ReadChannel(t, pushed, timeout))
+	assert.Equal(t, 1, helpers.ReadChannel(t, pushed, timeout))
+	// the producer is parked until the consumer pops
+	assertNotReceived(t, pushed, timeout/20)
+	assert.Equal(t, 2, r.Len())
+
+	for i := 0; i < 5; i++ {
+		n, ok := r.Pop()
+		require.True(t, ok)
+		assert.Equal(t, i, n)
+	}
+	_, ok := r.Pop()
+	assert.False(t, ok)
+}
+
+func TestRing_ParkWhileEmpty(t *testing.T) {
+	r := NewRing[string](4)
+	popped := make(chan string, 10)
+	go func() {
+		for {
+			s, ok := r.Pop()
+			if !ok {
+				close(popped)
+				return
+			}
+			popped <- s
+		}
+	}()
+	assertNotReceived(t, popped, timeout/20)
+	r.Push("hello")
+	assert.Equal(t, "hello", helpers.ReadChannel(t, popped, timeout))
+	assertNotReceived(t, popped, timeout/20)
+	r.Push("world")
+	r.Close()
+	assert.Equal(t, "world", helpers.ReadChannel(t, popped, timeout))
+	_, ok := <-popped
+	assert.False(t, ok)
+}
+
+// BenchmarkEdge compares the transfer of items between a single producer and a single consumer
+// through a Ring and through the channel of a Joiner
+func BenchmarkEdge(b *testing.B) {
+	for _, size := range []int{1, 16, 256} {
+		b.Run(fmt.Sprintf("joiner/buf=%d", size), func(b *testing.B) {
+			j := NewJoiner[int](size)
+			done := make(chan struct{})
+			go func() {
+				for range j.Receiver() {
+				}
+				close(done)
+			}()
+			b.ResetTimer()
+			out := j.AcquireSender()
+			for i := 0; i < b.N; i++ {
+				out <- i
+			}
+			j.ReleaseSender()
+			<-done
+		})
+		b.Run(fmt.Sprintf("ring/buf=%d", size), func(b *testing.B) {
+			r := NewRing[int](size)
+			done := make(chan struct{})
+			go func() {
+				for _, ok := r.Pop(); ok; _, ok = r.Pop() {
+				}
+				close(done)
+			}()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.Push(i)
+			}
+			r.Close()
+			<-done
+		})
+	}
+}
+
+func assertNotReceived[T any](t *testing.T, ch <-chan T, wait time.Duration) {
+	t.Helper()
+	select {
+	case item := <-ch:
+		t.Fatalf("unexpected item received: %v", item)
+	case <-time.After(wait):
+	}
+}
+
+func TestRingJoiner(t *testing.T) {
+	j := NewRingJoiner[int](0, 4)
+	push, release := j.AcquirePusher()
+	for i := 1; i <= 4; i++ {
+		push(i)
+	}
+	assert.Equal(t, 4, j.Buffered())
+	assert.True(t, j.Full())
+	out := consumeAll(&j)
+	for i := 1; i <= 4; i++ {
+		assert.Equal(t, i, helpers.ReadChannel(t, out, timeout))
+	}
+	release()
+	_, ok := <-out
+	assert.False(t, ok)
+}
+
+func TestRingJoiner_Channels(t *testing.T) {
+	j := NewRingJoiner[int](0, 4)
+	// the items are moved between the channels and the ring by extra goroutines
+	sender, receiver := j.AcquireSender(), j.Receiver()
+	go func() {
+		for i := 1; i <= 10; i++ {
+			sender <- i
+		}
+		j.ReleaseSender()
+	}()
+	for i := 1; i <= 10; i++ {
+		assert.Equal(t, i, helpers.ReadChannel(t, receiver, timeout))
+	}
+	_, ok := <-receiver
+	assert.False(t, ok)
+}
+
+func TestRingJoiner_MultipleSenders(t *testing.T) {
+	j := NewRingJoiner[int](0, 4)
+	// the ring only supports a single producer, so the Joiner uses its channel
+	j.ExpectSenders(2)
+	assert.Equal(t, j.AcquireSender(), j.Receiver())
+	j.ReleaseSender()
+}
@@ -0,0 +1,222 @@
+package connect
+
+// RoutingMode specifies how a routing Forker selects the destinations of an item
+// when it is accepted by multiple routes.
+type RoutingMode int
+
+const (
+	// RouteAll sends each item to all the routes whose predicate accepts it.
+	RouteAll RoutingMode = iota
+	// RouteFirst sends each item only to the first route whose predicate accepts it.
+	RouteFirst
+)
+
+// Route groups the Joiner instances of a destination node with the conditions
+// that determine whether an item is forwarded to them.
+type Route[T any] struct {
+	Joiners []*Joiner[T]
+	// Accept returns true if the item must be forwarded to the route. If nil,
+	// and the route is not a Default route, all the items are forwarded to the route.
+	Accept func(T) bool
+	// Default routes only receive the items that haven't been accepted by any other route
+	// with an Accept predicate.
+	Default bool
+	// Observe, if not nil, is invoked with each item before it is forwarded to the route.
+	Observe func(T)
+	// Clone, if not nil, returns the copy of the item that is forwarded to each joiner of the route.
+	// The original RefCounted items are not retained for the cloned routes.
+	Clone func(T) T
+	// CloneOnFanOut, if not nil, returns the copies of the item that are forwarded to the joiners
+	// of the CloneOnFanOut routes, except one of them, which receives the original item. The copies
+	// are forwarded before the original item.
+	CloneOnFanOut func(T) T
+}
+
+// ForkRoutes provides connection to a group of output Nodes, accessible through their respective
+// Routes. The routing is evaluated in the same goroutine that forwards the data to the destinations,
+// so routing items does not require any extra goroutine or channel operation in comparison to
+// forwarding them to multiple destinations.
+// If none of the routes is conditional, observed or cloned, it is equivalent to invoking Fork with
+// all the joiners.
+// The forwarding goroutine is started with the provided Spawner, which can be nil.
+func ForkRoutes[T any](spawn Spawner, mode RoutingMode, routes ...Route[T]) Forker[T] {
+	var broadcast, predicated, defaults []Route[T]
+	var allJoiners []*Joiner[T]
+	observed := false
+	for _, r := range routes {
+		allJoiners = append(allJoiners, r.Joiners...)
+		observed = observed || r.Observe != nil || r.Clone != nil || r.CloneOnFanOut != nil
+		switch {
+		case r.Default:
+			defaults = append(defaults, r)
+		case r.Accept != nil:
+			predicated = append(predicated, r)
+		default:
+			broadcast = append(broadcast, r)
+		}
+	}
+	if len(predicated) == 0 && len(defaults) == 0 && !observed {
+		return fork(spawn, allJoiners...)
+	}
+	if len(allJoiners) == 0 {
+		panic("can't route to 0 joiners")
+	}
+	sendCh := make(chan T, allJoiners[0].bufLen)
+	rt := router[T]{
+		mode:       mode,
+		refs:       refCounted[T](),
+		broadcast:  broadcast,
+		predicated: predicated,
+		defaults:   defaults,
+	}
+	rt.broadcastFw = rt.acquirePushers(broadcast)
+	rt.predicatedFw = rt.acquirePushers(predicated)
+	rt.defaultFw = rt.acquirePushers(defaults)
+	spawn.Go(func() {
+		for in := range sendCh {
+			rt.forward(in)
+		}
+		for _, release := range rt.releasers {
+			release()
+		}
+	})
+	return Forker[T]{
+		sendCh:         sendCh,
+		releaseChannel: func() { close(sendCh) },
+	}
+}
+
+// router forwards each item to the routes that accept it
+type router[T any] struct {
+	mode RoutingMode
+	// refs is true if the items are RefCounted
+	refs                                 bool
+	broadcast, predicated, defaults      []Route[T]
+	broadcastFw, predicatedFw, defaultFw [][]func(T)
+	// releasers of the pushers of all the joiners
+	releasers []func()
+	// selected stores the routes that receive the item being forwarded
+	selected []selectedRoute[T]
+}
+
+type selectedRoute[T any] struct {
+	route *Route[T]
+	dsts  []func(T)
+}
+
+func (rt *router[T]) forward(in T) {
+	rt.selectRoutes(in)
+	if rt.refs {
+		// the router keeps its own reference until all the routes have observed, cloned
+		// or forwarded the item, as a destination could release it in the meantime
+		share(in, rt.destinations()+1)
+	}
+	// the copies are forwarded before the original item, so the destinations of the original
+	// item can't modify it while it's observed or copied
+	fanOut := false
+	for _, s := range rt.selected {
+		fanOut = s.route.sendCopies(in, s.dsts, fanOut)
+	}
+	fanOut = false
+	for _, s := range rt.selected {
+		fanOut = s.route.sendOriginal(in, s.dsts, fanOut)
+	}
+	if rt.refs {
+		any(in).(RefCounted).Release()
+	}
+}
+
+// selectRoutes stores the routes that accept the item into the selected slice
+func (rt *router[T]) selectRoutes(in T) {
+	rt.selected = rt.selected[:0]
+	for i := range rt.broadcast {
+		rt.selected = append(rt.selected, selectedRoute[T]{route: &rt.broadcast[i], dsts: rt.broadcastFw[i]})
+	}
+	matched := false
+	for i := range rt.predicated {
+		if (!matched || rt.mode != RouteFirst) && rt.predicated[i].Accept(in) {
+			matched = true
+			rt.selected = append(rt.selected, selectedRoute[T]{route: &rt.predicated[i], dsts: rt.predicatedFw[i]})
+		}
+	}
+	if !matched {
+		for i := range rt.defaults {
+			rt.selected = append(rt.selected, selectedRoute[T]{route: &rt.defaults[i], dsts: rt.defaultFw[i]})
+		}
+	}
+}
+
+// destinations returns the number of joiners that will receive the original item being forwarded
+func (rt *router[T]) destinations() int {
+	n, fanOut := 0, false
+	for _, s := range rt.selected {
+		switch {
+		case s.route.Clone != nil:
+		case s.route.CloneOnFanOut != nil:
+			fanOut = fanOut || len(s.dsts) > 0
+		default:
+			n += len(s.dsts)
+		}
+	}
+	if fanOut {
+		// only one of the CloneOnFanOut joiners receives the original item
+		n++
+	}
+	return n
+}
+
+// acquirePushers returns, for each route, the pushers of all its joiners, as fork does, so the
+// joiners with a transport don't require an extra goroutine
+func (rt *router[T]) acquirePushers(routes []Route[T]) [][]func(T) {
+	forwarders := make([][]func(T), len(routes))
+	for i := range routes {
+		for _, j := range routes[i].Joiners {
+			push, release := j.AcquirePusher()
+			forwarders[i] = append(forwarders[i], push)
+			rt.releasers = append(rt.releasers, release)
+		}
+	}
+	return forwarders
+}
+
+// sendCopies observes the item and sends its copies to the destination pushers of the route.
+// If fanOut is false, the first CloneOnFanOut destination is skipped, as it will receive the
+// original item. It returns whether the first CloneOnFanOut destination has been already skipped.
+func (r *Route[T]) sendCopies(item T, dsts []func(T), fanOut bool) bool {
+	if r.Observe != nil {
+		r.Observe(item)
+	}
+	switch {
+	case r.Clone != nil:
+		for _, dst := range dsts {
+			dst(r.Clone(item))
+		}
+	case r.CloneOnFanOut != nil:
+		for _, dst := range dsts {
+			if fanOut {
+				dst(r.CloneOnFanOut(item))
+			}
+			fanOut = true
+		}
+	}
+	return fanOut
+}
+
+// sendOriginal sends the original item to the destination pushers of the route that don't
+// receive a copy. If fanOut is false, the item is sent to the first CloneOnFanOut destination.
+// It returns whether the item has been already sent to a CloneOnFanOut destination.
+func (r *Route[T]) sendOriginal(item T, dsts []func(T), fanOut bool) bool {
+	switch {
+	case r.Clone != nil:
+	case r.CloneOnFanOut != nil:
+		if !fanOut && len(dsts) > 0 {
+			dsts[0](item)
+			return true
+		}
+	default:
+		for _, dst := range dsts {
+			dst(item)
+		}
+	}
+	return fanOut
+}
@@ -0,0 +1,109 @@
+package connect
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	helpers "github.com/mariomac/pipes/testers"
+)
+
+func TestForkRoutes(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		mode       RoutingMode
+		expectOdds []int
+	}{
+		{name: "all matching routes", mode: RouteAll, expectOdds: []int{1, 3, 5}},
+		{name: "first matching route", mode: RouteFirst, expectOdds: []int{3, 5}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			smalls, odds := NewJoiner[int](20), NewJoiner[int](20)
+			defaults, all := NewJoiner[int](20), NewJoiner[int](20)
+
+			f := ForkRoutes(nil, tc.mode,
+				Route[int]{Joiners: []*Joiner[int]{&smalls}, Accept: func(i int) bool { return i < 3 }},
+				Route[int]{Joiners: []*Joiner[int]{&odds}, Accept: func(i int) bool { return i%2 == 1 }},
+				Route[int]{Joiners: []*Joiner[int]{&defaults}, Default: true},
+				Route[int]{Joiners: []*Joiner[int]{&all}},
+			)
+			sender := f.AcquireSender()
+			for i := 1; i <= 6; i++ {
+				sender <- i
+			}
+			f.ReleaseSender()
+
+			finished := helpers.AsyncWait(4)
+			var smallArr, oddArr, defaultArr, allArr []int
+			for _, c := range []struct {
+				j   *Joiner[int]
+				arr *[]int
+			}{{&smalls, &smallArr}, {&odds, &oddArr}, {&defaults, &defaultArr}, {&all, &allArr}} {
+				c := c
+				go func() {
+					for i := range c.j.Receiver() {
+						*c.arr = append(*c.arr, i)
+					}
+					finished.Done()
+				}()
+			}
+			finished.Wait(t, timeout)
+
+			assert.Equal(t, []int{1, 2}, smallArr)
+			assert.Equal(t, tc.expectOdds, oddArr)
+			assert.Equal(t, []int{4, 6}, defaultArr)
+			assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, allArr)
+		})
+	}
+}
+
+func TestForkRoutes_Transport(t *testing.T) {
+	evens, odds := NewRingJoiner[int](0, 8), NewBatchedJoiner[int](2, 2, 0, nil)
+	f := ForkRoutes(nil, RouteAll,
+		Route[int]{Joiners: []*Joiner[int]{&evens}, Accept: func(i int) bool { return i%2 == 0 }},
+		Route[int]{Joiners: []*Joiner[int]{&odds}, Accept: func(i int) bool { return i%2 == 1 }},
+	)
+	sender := f.AcquireSender()
+	for i := 1; i <= 6; i++ {
+		sender <- i
+	}
+	f.ReleaseSender()
+
+	var evenArr, oddArr []int
+	evens.Consume(func(i int) { evenArr = append(evenArr, i) })
+	odds.Consume(func(i int) { oddArr = append(oddArr, i) })
+	assert.Equal(t, []int{2, 4, 6}, evenArr)
+	assert.Equal(t, []int{1, 3, 5}, oddArr)
+	// the router pushes the items directly into the transports, without ingress goroutines
+	assert.False(t, evens.ingress)
+	assert.False(t, odds.ingress)
+}
+
+func TestForkRoutes_Observe(t *testing.T) {
+	odds, all := NewJoiner[int](20), NewJoiner[int](20)
+	var observedOdds, observedAll []int
+	f := ForkRoutes(nil, RouteAll,
+		Route[int]{Joiners: []*Joiner[int]{&odds}, Accept: func(i int) bool { return i%2 == 1 },
+			Observe: func(i int) { observedOdds = append(observedOdds, i) }},
+		Route[int]{Joiners: []*Joiner[int]{&all},
+			Observe: func(i int) { observedAll = append(observedAll, i) }},
+	)
+	sender := f.AcquireSender()
+	for i := 1; i <= 4; i++ {
+		sender <- i
+	}
+	f.ReleaseSender()
+
+	var oddArr, allArr []int
+	for i := range odds.Receiver() {
+		oddArr = append(oddArr, i)
+	}
+	for i := range all.Receiver() {
+		allArr = append(allArr, i)
+	}
+	assert.Equal(t, []int{1, 3}, oddArr)
+	assert.Equal(t, []int{1, 2, 3, 4}, allArr)
+	// observers are invoked before forwarding the items, so they are already visible here
+	assert.Equal(t, []int{1, 3}, observedOdds)
+	assert.Equal(t, []int{1, 2, 3, 4}, observedAll)
+}
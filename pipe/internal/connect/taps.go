@@ -0,0 +1,185 @@
+package connect
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ErrTapsClosed is returned when a Joiner is attached to a Taps group whose Forker
+// has already been released.
+var ErrTapsClosed = errors.New("the sender has already finished")
+
+// Taps is a dynamic group of Joiner instances that can be attached to and detached
+// from a Forker while it is forwarding data.
+type Taps[T any] struct {
+	mt     sync.Mutex
+	closed bool
+	// taps is never modified in place, so the Forker can send the data to a snapshot of
+	// the attached taps without holding the lock
+	taps []*tap[T]
+	// spawn starts the goroutines of the Final nodes that are attached to the group
+	spawn Spawner
+}
+
+// tap is a Joiner attached to a Taps group
+type tap[T any] struct {
+	joiner *Joiner[T]
+	// sending is locked while the Forker sends an item to the Joiner
+	sending sync.Mutex
+	// detached is closed when the Joiner is detached, to abort any ongoing send
+	detached chan struct{}
+}
+
+// Track makes the Spawner function return the provided Spawner.
+func (t *Taps[T]) Track(spawn Spawner) {
+	t.mt.Lock()
+	defer t.mt.Unlock()
+	t.spawn = spawn
+}
+
+// Spawner returns the Spawner that starts the goroutines of the Final nodes that are attached
+// to the group. It can be nil.
+func (t *Taps[T]) Spawner() Spawner {
+	t.mt.Lock()
+	defer t.mt.Unlock()
+	return t.spawn
+}
+
+// Attach adds a Joiner to the group, which will receive all the items that are sent
+// through the Forker from now on. It acquires the sender of the Joiner, which will
+// be released on Detach or when the Forker is released.
+func (t *Taps[T]) Attach(j *Joiner[T]) error {
+	t.mt.Lock()
+	defer t.mt.Unlock()
+	if t.closed {
+		return ErrTapsClosed
+	}
+	j.AcquireSender()
+	t.taps = append(slices.Clip(t.taps), &tap[T]{joiner: j, detached: make(chan struct{})})
+	return nil
+}
+
+// Detach removes a Joiner from the group and releases its sender, so its channel is
+// closed if no other sender acquired it. If the Forker is blocked sending an item to the
+// Joiner, the item is discarded. The receiver of the Joiner must keep reading its channel
+// until it is closed. Detaching a Joiner that is not attached has no effect.
+func (t *Taps[T]) Detach(j *Joiner[T]) {
+	t.mt.Lock()
+	var detached *tap[T]
+	for i, tp := range t.taps {
+		if tp.joiner == j {
+			detached = tp
+			t.taps = slices.Delete(slices.Clone(t.taps), i, i+1)
+			break
+		}
+	}
+	t.mt.Unlock()
+	if detached == nil {
+		return
+	}
+	close(detached.detached)
+	// waits for any ongoing send to be aborted before closing the channel
+	detached.sending.Lock()
+	defer detached.sending.Unlock()
+	j.ReleaseSender()
+}
+
+// send the item to all the attached joiners. If the items are RefCounted, the references
+// of the item are updated according to the attached joiners plus the other destinations
+// of the item.
+// If clone is not nil and the item has more than one destination, each attached joiner receives
+// its own copy of the item, except the last one if there are no other destinations, which
+// receives the original item after the copies have been created.
+// A slow joiner blocks the Forker, but it does not block attaching or detaching other joiners.
+func (t *Taps[T]) send(item T, refs bool, others int, clone func(T) T) {
+	t.mt.Lock()
+	taps := t.taps
+	t.mt.Unlock()
+	if clone == nil || len(taps)+others <= 1 {
+		if refs {
+			share(item, len(taps)+others)
+		}
+		for _, tp := range taps {
+			tp.send(item, refs)
+		}
+		return
+	}
+	copies, originals := len(taps), others
+	if others == 0 {
+		copies, originals = copies-1, 1
+	}
+	if refs {
+		share(item, originals)
+	}
+	for i, tp := range taps {
+		if i < copies {
+			tp.send(clone(item), refs)
+		} else {
+			tp.send(item, refs)
+		}
+	}
+}
+
+func (tp *tap[T]) send(item T, refs bool) {
+	tp.sending.Lock()
+	defer tp.sending.Unlock()
+	select {
+	case <-tp.detached:
+	default:
+		select {
+		case tp.joiner.channel <- item:
+			return
+		case <-tp.detached:
+		}
+	}
+	// the item wasn't sent because the joiner was detached
+	if refs {
+		any(item).(RefCounted).Release()
+	}
+}
+
+func (t *Taps[T]) close() {
+	t.mt.Lock()
+	defer t.mt.Unlock()
+	t.closed = true
+	for _, tp := range t.taps {
+		tp.joiner.ReleaseSender()
+	}
+	t.taps = nil
+}
+
+// ForkTaps returns a Forker that sends the data to the provided Forker (if not nil) as well
+// as to the Joiners that are dynamically attached to the Taps group.
+// When the returned Forker is released, it releases the wrapped Forker and all the attached Joiners.
+// If clone is not nil, the attached Joiners receive their own copy of each item, unless the item
+// has no other destination. The provided Forker always receives the original items.
+// The forwarding goroutine is started with the provided Spawner, which can be nil.
+func ForkTaps[T any](spawn Spawner, static *Forker[T], taps *Taps[T], bufLen int, clone func(T) T) Forker[T] {
+	sendCh := make(chan T, bufLen)
+	var staticCh chan T
+	statics := 0
+	if static != nil {
+		staticCh = static.AcquireSender()
+		statics = 1
+	}
+	refs := refCounted[T]()
+	spawn.Go(func() {
+		for in := range sendCh {
+			// sending first to the taps guarantees that a Joiner attached after a static
+			// destination received an item won't receive that item
+			taps.send(in, refs, statics, clone)
+			if staticCh != nil {
+				staticCh <- in
+			}
+		}
+		if static != nil {
+			static.ReleaseSender()
+		}
+		taps.close()
+	})
+	return Forker[T]{
+		sendCh:         sendCh,
+		releaseChannel: func() { close(sendCh) },
+	}
+}
@@ -0,0 +1,137 @@
+package connect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	helpers "github.com/mariomac/pipes/testers"
+)
+
+func TestForkTaps(t *testing.T) {
+	static := NewJoiner[int](10)
+	staticFork := Fork(&static)
+	taps := &Taps[int]{}
+	f := ForkTaps(nil, &staticFork, taps, 0, nil)
+	sender := f.AcquireSender()
+
+	tap1 := NewJoiner[int](0)
+	require.NoError(t, taps.Attach(&tap1))
+	sender <- 1
+	assert.Equal(t, 1, helpers.ReadChannel(t, tap1.Receiver(), timeout))
+
+	tap2 := NewJoiner[int](0)
+	require.NoError(t, taps.Attach(&tap2))
+	sender <- 2
+	assert.Equal(t, 2, helpers.ReadChannel(t, tap1.Receiver(), timeout))
+	assert.Equal(t, 2, helpers.ReadChannel(t, tap2.Receiver(), timeout))
+
+	// detaching closes the tap channel
+	taps.Detach(&tap1)
+	_, ok := <-tap1.Receiver()
+	assert.False(t, ok)
+	sender <- 3
+	assert.Equal(t, 3, helpers.ReadChannel(t, tap2.Receiver(), timeout))
+
+	// releasing the forker closes all the channels
+	f.ReleaseSender()
+	_, ok = <-tap2.Receiver()
+	assert.False(t, ok)
+	var staticItems []int
+	for i := range static.Receiver() {
+		staticItems = append(staticItems, i)
+	}
+	assert.Equal(t, []int{1, 2, 3}, staticItems)
+
+	// can't attach to an already released forker
+	tap3 := NewJoiner[int](0)
+	assert.ErrorIs(t, taps.Attach(&tap3), ErrTapsClosed)
+	// detaching a closed tap has no effect
+	taps.Detach(&tap2)
+}
+
+func TestForkTaps_NoStatic(t *testing.T) {
+	taps := &Taps[int]{}
+	f := ForkTaps(nil, nil, taps, 0, nil)
+	sender := f.AcquireSender()
+	// items are discarded when no taps are attached
+	sender <- 1
+	tap := NewJoiner[int](0)
+	require.NoError(t, taps.Attach(&tap))
+	sender <- 2
+	assert.Equal(t, 2, helpers.ReadChannel(t, tap.Receiver(), timeout))
+	f.ReleaseSender()
+	_, ok := <-tap.Receiver()
+	assert.False(t, ok)
+}
+
+func TestForkTaps_Clone(t *testing.T) {
+	taps := &Taps[*int]{}
+	f := ForkTaps(nil, nil, taps, 10, func(i *int) *int {
+		cp := *i
+		return &cp
+	})
+	tap1, tap2 := NewJoiner[*int](10), NewJoiner[*int](10)
+	require.NoError(t, taps.Attach(&tap1))
+	require.NoError(t, taps.Attach(&tap2))
+	item := 1
+	sender := f.AcquireSender()
+	sender <- &item
+	f.ReleaseSender()
+
+	// without other destinations, the first tap receives a copy and the last one the original item
+	copied := helpers.ReadChannel(t, tap1.Receiver(), timeout)
+	assert.NotSame(t, &item, copied)
+	assert.Equal(t, 1, *copied)
+	assert.Same(t, &item, helpers.ReadChannel(t, tap2.Receiver(), timeout))
+}
+
+func TestForkTaps_SlowTap(t *testing.T) {
+	taps := &Taps[int]{}
+	f := ForkTaps(nil, nil, taps, 0, nil)
+	sender := f.AcquireSender()
+	slow, other := NewJoiner[int](0), NewJoiner[int](0)
+	require.NoError(t, taps.Attach(&slow))
+	// the forker gets blocked, as nobody reads the slow tap
+	sender <- 1
+
+	// attaching and detaching other taps is not blocked by the slow tap
+	attached := make(chan struct{})
+	go func() {
+		assert.NoError(t, taps.Attach(&other))
+		taps.Detach(&other)
+		close(attached)
+	}()
+	helpers.ReadChannel(t, attached, timeout)
+	_, ok := <-other.Receiver()
+	assert.False(t, ok)
+
+	// detaching the slow tap discards the item that was blocked and unblocks the forker
+	detached := make(chan struct{})
+	go func() {
+		taps.Detach(&slow)
+		close(detached)
+	}()
+	helpers.ReadChannel(t, detached, timeout)
+	_, ok = <-slow.Receiver()
+	assert.False(t, ok)
+	sender <- 2
+	f.ReleaseSender()
+}
+
+func TestForkTaps_RefCounted_Detach(t *testing.T) {
+	taps := Taps[*refItem]{}
+	slow := NewJoiner[*refItem](0)
+	assert.NoError(t, taps.Attach(&slow))
+	f := ForkTaps(nil, nil, &taps, 0, nil)
+	items := newRefItems(1)
+	sender := f.AcquireSender()
+	sender <- items[0]
+	// the item is released when it couldn't be sent to the detached tap
+	taps.Detach(&slow)
+	f.ReleaseSender()
+	assert.Eventually(t, func() bool { return items[0].freed.Load() }, timeout, time.Millisecond)
+	assertFreed(t, items)
+}
@@ -0,0 +1,174 @@
+package connect
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Spawner starts a goroutine on behalf of a node. A nil Spawner just starts the goroutine.
+type Spawner func(fn func())
+
+// Go runs the function in a new goroutine.
+func (s Spawner) Go(fn func()) {
+	if s == nil {
+		go fn()
+		return
+	}
+	s(fn)
+}
+
+// Tracker keeps track of the goroutines that are started by the nodes and connectors
+// of a pipeline, to report those that didn't exit.
+type Tracker struct {
+	mt   sync.Mutex
+	next uint64
+	// live goroutines, by registration order
+	live map[uint64]*tracked
+}
+
+type tracked struct {
+	owner string
+	// id of the goroutine in the Go runtime. It's 0 until the goroutine parses it
+	id int64
+}
+
+// Goroutine describes a tracked goroutine that is still alive.
+type Goroutine struct {
+	// Owner is the name of the node that started the goroutine
+	Owner string
+	// State of the goroutine, as reported by the Go runtime (e.g. "chan send").
+	// It's empty if the goroutine has just started.
+	State string
+	// Location of the function where the goroutine is blocked
+	Location string
+}
+
+func (g Goroutine) String() string {
+	if g.State == "" {
+		return g.Owner
+	}
+	return fmt.Sprintf("%s: %s in %s", g.Owner, g.State, g.Location)
+}
+
+// Spawner returns a Spawner whose goroutines are tracked under the provided owner name.
+// The goroutines are registered before the Spawner returns.
+func (t *Tracker) Spawner(owner string) Spawner {
+	return func(fn func()) {
+		g := &tracked{owner: owner}
+		t.mt.Lock()
+		if t.live == nil {
+			t.live = map[uint64]*tracked{}
+		}
+		t.next++
+		key := t.next
+		t.live[key] = g
+		t.mt.Unlock()
+		go func() {
+			defer func() {
+				t.mt.Lock()
+				delete(t.live, key)
+				t.mt.Unlock()
+			}()
+			id := goroutineID()
+			t.mt.Lock()
+			g.id = id
+			t.mt.Unlock()
+			fn()
+		}()
+	}
+}
+
+// Live returns the tracked goroutines that haven't exited yet, sorted by owner.
+// It inspects the stacks of all the goroutines to report where each of them is blocked.
+func (t *Tracker) Live() []Goroutine {
+	t.mt.Lock()
+	live := make([]Goroutine, 0, len(t.live))
+	owners := map[int64]int{}
+	for _, g := range t.live {
+		if g.id != 0 {
+			owners[g.id] = len(live)
+		}
+		live = append(live, Goroutine{Owner: g.owner})
+	}
+	t.mt.Unlock()
+	if len(owners) > 0 {
+		for _, trace := range bytes.Split(allStacks(), []byte("\n\n")) {
+			id, g, ok := parseTrace(string(trace))
+			if !ok {
+				continue
+			}
+			if i, ok := owners[id]; ok {
+				g.Owner = live[i].Owner
+				live[i] = g
+			}
+		}
+	}
+	sort.Slice(live, func(i, j int) bool {
+		return live[i].Owner < live[j].Owner
+	})
+	return live
+}
+
+// goroutineID parses the ID of the current goroutine from the header of its stack trace
+func goroutineID() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	// goroutine 18 [running]: ...
+	fields := bytes.Fields(buf)
+	if len(fields) < 2 {
+		return -1
+	}
+	id, err := strconv.ParseInt(string(fields[1]), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return id
+}
+
+func allStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// parseTrace parses the ID, the state and the location of a goroutine from its stack trace:
+//
+//	goroutine 18 [chan send, 2 minutes]:
+//	github.com/mariomac/pipes/pipe.Counter.func1(0xc000010000)
+//		/home/user/pipes/pipe/node_test.go:31 +0x45
+//	...
+func parseTrace(trace string) (int64, Goroutine, bool) {
+	lines := strings.Split(strings.TrimSpace(trace), "\n")
+	header := lines[0]
+	if !strings.HasPrefix(header, "goroutine ") {
+		return 0, Goroutine{}, false
+	}
+	idStr, rest, _ := strings.Cut(strings.TrimPrefix(header, "goroutine "), " ")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, Goroutine{}, false
+	}
+	g := Goroutine{}
+	if start, end := strings.Index(rest, "["), strings.Index(rest, "]"); start >= 0 && end > start {
+		g.State, _, _ = strings.Cut(rest[start+1:end], ",")
+	}
+	if len(lines) > 2 {
+		function := lines[1]
+		if paren := strings.LastIndex(function, "("); paren > 0 {
+			function = function[:paren]
+		}
+		file, _, _ := strings.Cut(strings.TrimSpace(lines[2]), " +")
+		g.Location = function + " (" + file + ")"
+	}
+	return id, g, true
+}
@@ -0,0 +1,89 @@
+package connect
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	helpers "github.com/mariomac/pipes/testers"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := Tracker{}
+	unblock := make(chan struct{})
+	finished := helpers.AsyncWait(2)
+	tracker.Spawner("blocked").Go(func() {
+		<-unblock
+		finished.Done()
+	})
+	tracker.Spawner("returned").Go(func() {
+		finished.Done()
+	})
+
+	// the returned goroutine might take some time to be unregistered
+	var live []Goroutine
+	for i := 0; i < 100; i++ {
+		if live = tracker.Live(); len(live) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Len(t, live, 1)
+	assert.Equal(t, "blocked", live[0].Owner)
+	assert.Equal(t, "chan receive", live[0].State)
+	assert.Contains(t, live[0].Location, "connect.TestTracker")
+	assert.Contains(t, live[0].Location, "tracker_test.go")
+	assert.True(t, strings.HasPrefix(live[0].String(), "blocked: chan receive in "))
+
+	close(unblock)
+	finished.Wait(t, timeout)
+}
+
+func TestTracker_Spawned(t *testing.T) {
+	tracker := Tracker{}
+	unblock := make(chan struct{})
+	finished := helpers.AsyncWait(1)
+	tracker.Spawner("blocked").Go(func() {
+		<-unblock
+		finished.Done()
+	})
+
+	// the goroutine is tracked as soon as it's spawned
+	live := tracker.Live()
+	require.Len(t, live, 1)
+	assert.Equal(t, "blocked", live[0].Owner)
+	assert.True(t, strings.HasPrefix(live[0].String(), "blocked"))
+
+	close(unblock)
+	finished.Wait(t, timeout)
+	for i := 0; i < 100 && len(live) > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		live = tracker.Live()
+	}
+	assert.Empty(t, live)
+}
+
+func TestTracker_NilSpawner(t *testing.T) {
+	var spawn Spawner
+	done := make(chan struct{})
+	spawn.Go(func() { close(done) })
+	helpers.ReadChannel(t, done, timeout)
+}
+
+func TestParseTrace(t *testing.T) {
+	id, g, ok := parseTrace(`goroutine 18 [chan send, 2 minutes]:
+github.com/mariomac/pipes/pipe.Counter.func1(0xc000010000)
+	/home/user/pipes/pipe/node_test.go:31 +0x45
+created by github.com/mariomac/pipes/pipe.(*start[...]).startGated in goroutine 7
+	/home/user/pipes/pipe/node.go:196 +0x1b4`)
+	require.True(t, ok)
+	assert.EqualValues(t, 18, id)
+	assert.Equal(t, "chan send", g.State)
+	assert.Equal(t, "github.com/mariomac/pipes/pipe.Counter.func1 (/home/user/pipes/pipe/node_test.go:31)", g.Location)
+
+	_, _, ok = parseTrace("not a goroutine trace")
+	assert.False(t, ok)
+}
@@ -0,0 +1,37 @@
+package connect
+
+import "time"
+
+// Clock provides the current time and the timers to the time-based features of the library.
+// It is exported as pipe.Clock.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time on the returned channel
+	After(d time.Duration) <-chan time.Time
+	// NewTimer is equivalent to After, but it also returns a function that stops the timer, so
+	// it doesn't fire if the returned channel is abandoned. The stop function returns false if
+	// the timer already fired or was stopped
+	NewTimer(d time.Duration) (c <-chan time.Time, stop func() bool)
+	// Sleep pauses the current goroutine for at least the duration d
+	Sleep(d time.Duration)
+}
+
+// transport moves the items from the senders of a Joiner to its receiver, instead of the
+// Joiner channel.
+type transport[T any] interface {
+	// pusher returns a function that sends the items of a single goroutine, and a function
+	// that must be invoked after its last item. The Spawner starts any goroutine that the
+	// pusher requires.
+	pusher(spawn Spawner) (push func(T), flush func())
+	// close is invoked after all the pushers have been flushed
+	close()
+	// consume invokes the function for each item, until the transport is closed and empty
+	consume(fn func(T))
+	// len returns the number of items that are waiting to be consumed
+	len() int
+	// full returns true if the pushers block until the receiver consumes an item
+	full() bool
+	// singlePusher returns true if the transport doesn't support concurrent pushers
+	singlePusher() bool
+}
@@ -0,0 +1,317 @@
+package pipe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// nodeSpec describes a node instance in a configuration document
+type nodeSpec struct {
+	// id identifies the node instance in the pipeline
+	id string
+	// nodeType is the configuration type name of the node, as registered in the Registry
+	nodeType string
+	// sendTo lists the IDs of the destination nodes
+	sendTo []string
+	// decode the node-specific configuration into the passed value
+	decode func(cfg any) error
+	// options that only apply to this node
+	options nodeOptions
+}
+
+// nodeOptions are the per-node options of a configuration document. They are applied after
+// the options that are passed to the LoadYAML and LoadJSON methods.
+type nodeOptions struct {
+	ChannelBufferLen  *int `yaml:"channelBufferLen" json:"channelBufferLen"`
+	RouteToFirstMatch bool `yaml:"routeToFirstMatch" json:"routeToFirstMatch"`
+	Tappable          bool `yaml:"tappable" json:"tappable"`
+	Pausable          bool `yaml:"pausable" json:"pausable"`
+}
+
+func (o *nodeOptions) asOptions(global []Option) []Option {
+	opts := append([]Option{}, global...)
+	if o.ChannelBufferLen != nil {
+		opts = append(opts, ChannelBufferLen(*o.ChannelBufferLen))
+	}
+	if o.RouteToFirstMatch {
+		opts = append(opts, RouteToFirstMatch())
+	}
+	if o.Tappable {
+		opts = append(opts, Tappable())
+	}
+	if o.Pausable {
+		opts = append(opts, Pausable())
+	}
+	return opts
+}
+
+type yamlDocument struct {
+	Nodes []struct {
+		ID      string      `yaml:"id"`
+		Type    string      `yaml:"type"`
+		Config  yaml.Node   `yaml:"config"`
+		SendTo  []string    `yaml:"sendTo"`
+		Options nodeOptions `yaml:"options"`
+	} `yaml:"nodes"`
+}
+
+type jsonDocument struct {
+	Nodes []struct {
+		ID      string          `json:"id"`
+		Type    string          `json:"type"`
+		Config  json.RawMessage `json:"config"`
+		SendTo  []string        `json:"sendTo"`
+		Options nodeOptions     `json:"options"`
+	} `json:"nodes"`
+}
+
+// LoadYAML reads a YAML document describing the nodes of a pipeline and their connections,
+// and returns a Runner for the described pipeline. Example:
+//
+//	nodes:
+//	  - id: ingest
+//	    type: httpListener
+//	    config:
+//	      port: 8080
+//	    sendTo: [filter]
+//	  - id: filter
+//	    type: matchFilter
+//	    config:
+//	      pattern: "^GET"
+//	    sendTo: [printer, store]
+//	  - id: printer
+//	    type: stdout
+//	  - id: store
+//	    type: fileStorage
+//	    config:
+//	      path: /var/log/gets.log
+//	    options:
+//	      channelBufferLen: 100
+//
+// Each node type must have been previously registered in the Registry by means of the
+// RegisterStart, RegisterMiddle or RegisterFinal functions. The "config" property of each node
+// is decoded into the configuration type of the registered factory, using its yaml tags.
+// The passed options apply to all the nodes and connections in the pipeline. The "options"
+// property of each node overrides them for that node, and accepts the channelBufferLen,
+// routeToFirstMatch, tappable and pausable properties, which correspond to the homonym Option
+// functions.
+//
+// The types of the connected nodes are validated before any provider is invoked, as well as
+// the absence of cycles and duplicate destinations in the connections. An empty document, or a
+// document without nodes, is not a valid pipeline.
+func (r *Registry) LoadYAML(in io.Reader, opts ...Option) (*Runner, error) {
+	doc := yamlDocument{}
+	if err := yaml.NewDecoder(in).Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decoding YAML pipeline: %w", err)
+	}
+	specs := make([]nodeSpec, 0, len(doc.Nodes))
+	for i := range doc.Nodes {
+		cfg := &doc.Nodes[i].Config
+		specs = append(specs, nodeSpec{
+			id:       doc.Nodes[i].ID,
+			nodeType: doc.Nodes[i].Type,
+			sendTo:   doc.Nodes[i].SendTo,
+			options:  doc.Nodes[i].Options,
+			decode: func(dst any) error {
+				if cfg.IsZero() {
+					return nil
+				}
+				return cfg.Decode(dst)
+			},
+		})
+	}
+	return r.load(specs, opts)
+}
+
+// LoadJSON reads a JSON document describing the nodes of a pipeline and their connections,
+// and returns a Runner for the described pipeline. The document has the same structure as
+// the documents accepted by LoadYAML, but the "config" property of each node is decoded into
+// the configuration type of the registered factory using its json tags.
+func (r *Registry) LoadJSON(in io.Reader, opts ...Option) (*Runner, error) {
+	doc := jsonDocument{}
+	if err := json.NewDecoder(in).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding JSON pipeline: %w", err)
+	}
+	specs := make([]nodeSpec, 0, len(doc.Nodes))
+	for i := range doc.Nodes {
+		cfg := doc.Nodes[i].Config
+		specs = append(specs, nodeSpec{
+			id:       doc.Nodes[i].ID,
+			nodeType: doc.Nodes[i].Type,
+			sendTo:   doc.Nodes[i].SendTo,
+			options:  doc.Nodes[i].Options,
+			decode: func(dst any) error {
+				if len(cfg) == 0 {
+					return nil
+				}
+				return json.Unmarshal(cfg, dst)
+			},
+		})
+	}
+	return r.load(specs, opts)
+}
+
+func (r *Registry) load(specs []nodeSpec, opts []Option) (*Runner, error) {
+	if len(specs) == 0 {
+		return nil, errors.New("the pipeline definition does not contain any node")
+	}
+	types, err := r.resolveTypes(specs)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateConnections(specs, types); err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]*loadedNode, len(specs))
+	for i := range specs {
+		node, err := types[specs[i].id].instantiate(specs[i].decode, specs[i].options.asOptions(opts))
+		if err != nil {
+			return nil, fmt.Errorf("instantiating node %q: %w", specs[i].id, err)
+		}
+		nodes[specs[i].id] = node
+	}
+	options := getOptions(opts...)
+	runner, err := newRunner(&options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range specs {
+		node := nodes[specs[i].id]
+		for _, dst := range specs[i].sendTo {
+			node.sendTo(nodes[dst])
+		}
+		// runner nodes are indexed by their order in the configuration document
+		if node.startable != nil {
+			runner.startNodes[uintptr(i)] = node.startable
+		}
+		if node.doneable != nil {
+			runner.finalNodes[uintptr(i)] = node.doneable
+		}
+		if in, ok := node.startable.(inspectable); ok {
+			runner.nodes[specs[i].id] = in
+		} else if in, ok := node.receiver.(inspectable); ok {
+			runner.nodes[specs[i].id] = in
+		}
+	}
+	if err := runner.checkNodes(); err != nil {
+		return nil, err
+	}
+	return runner, nil
+}
+
+// resolveTypes validates the node instances before instantiating them,
+// returning the registered type of each node instance
+func (r *Registry) resolveTypes(specs []nodeSpec) (map[string]*registeredType, error) {
+	types := make(map[string]*registeredType, len(specs))
+	for i := range specs {
+		id := specs[i].id
+		if id == "" {
+			return nil, fmt.Errorf("node #%d: missing id", i)
+		}
+		if _, ok := types[id]; ok {
+			return nil, fmt.Errorf("node %q: duplicate id", id)
+		}
+		rt, ok := r.types[specs[i].nodeType]
+		if !ok {
+			return nil, fmt.Errorf("node %q: unknown type %q", id, specs[i].nodeType)
+		}
+		types[id] = rt
+	}
+	return types, nil
+}
+
+// validateConnections verifies that the connections between nodes are valid before
+// instantiating them
+func validateConnections(specs []nodeSpec, types map[string]*registeredType) error {
+	hasInputs := map[string]bool{}
+	for i := range specs {
+		src, srcType := specs[i].id, types[specs[i].id]
+		if srcType.kind == finalKind {
+			if len(specs[i].sendTo) > 0 {
+				return fmt.Errorf("node %q: Final nodes can't send data to other nodes", src)
+			}
+			continue
+		}
+		if len(specs[i].sendTo) == 0 {
+			return fmt.Errorf("node %q: %s nodes must send data to other nodes", src, srcType.kind)
+		}
+		destinations := make(map[string]struct{}, len(specs[i].sendTo))
+		for _, dst := range specs[i].sendTo {
+			dstType, ok := types[dst]
+			_, duplicate := destinations[dst]
+			switch {
+			case !ok:
+				return fmt.Errorf("node %q: unknown destination node %q", src, dst)
+			case duplicate:
+				return fmt.Errorf("node %q: duplicate destination node %q", src, dst)
+			case dst == src:
+				return fmt.Errorf("node %q: can't send data to itself", src)
+			case dstType.kind == startKind:
+				return fmt.Errorf("node %q: can't send data to Start node %q", src, dst)
+			case dstType.in != srcType.out:
+				return fmt.Errorf("node %q: can't send data of type %s to node %q, which accepts %s",
+					src, srcType.out, dst, dstType.in)
+			}
+			destinations[dst] = struct{}{}
+			hasInputs[dst] = true
+		}
+	}
+	for i := range specs {
+		if types[specs[i].id].kind != startKind && !hasInputs[specs[i].id] {
+			return fmt.Errorf("node %q: no node sends data to it", specs[i].id)
+		}
+	}
+	return validateAcyclic(specs)
+}
+
+// validateAcyclic verifies that the connections between nodes don't form any cycle, as the
+// input channels of the nodes in a cycle would never be closed
+func validateAcyclic(specs []nodeSpec) error {
+	sendTo := make(map[string][]string, len(specs))
+	for i := range specs {
+		sendTo[specs[i].id] = specs[i].sendTo
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(specs))
+	// path stores the nodes that are being visited, to report the cycle
+	var path []string
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visited:
+			return nil
+		case visiting:
+			for i := range path {
+				if path[i] == id {
+					return fmt.Errorf("node %q: the connections form a cycle: %s -> %s",
+						id, strings.Join(path[i:], " -> "), id)
+				}
+			}
+		}
+		state[id] = visiting
+		path = append(path, id)
+		for _, dst := range sendTo[id] {
+			if err := visit(dst); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = visited
+		return nil
+	}
+	for i := range specs {
+		if err := visit(specs[i].id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package pipe_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+type counterCfg struct {
+	From int `yaml:"from" json:"from"`
+	To   int `yaml:"to" json:"to"`
+}
+
+type multiplierCfg struct {
+	Factor int `yaml:"factor" json:"factor"`
+}
+
+type formatterCfg struct {
+	Prefix string `yaml:"prefix" json:"prefix"`
+}
+
+// collectorRegistry returns a registry whose "collector" final nodes store
+// the received strings in the returned slice
+func collectorRegistry(t *testing.T) (*pipe.Registry, *[]string) {
+	reg := pipe.NewRegistry()
+	require.NoError(t, pipe.RegisterStart(reg, "counter", func(cfg counterCfg) pipe.StartProvider[int] {
+		return func() (pipe.StartFunc[int], error) {
+			return Counter(cfg.From, cfg.To), nil
+		}
+	}))
+	require.NoError(t, pipe.RegisterMiddle(reg, "multiplier", func(cfg multiplierCfg) pipe.MiddleProvider[int, int] {
+		return func() (pipe.MiddleFunc[int, int], error) {
+			if cfg.Factor == 1 {
+				return pipe.Bypass[int](), nil
+			}
+			return func(in <-chan int, out chan<- int) {
+				for i := range in {
+					out <- i * cfg.Factor
+				}
+			}, nil
+		}
+	}))
+	require.NoError(t, pipe.RegisterMiddle(reg, "formatter", func(cfg formatterCfg) pipe.MiddleProvider[int, string] {
+		return func() (pipe.MiddleFunc[int, string], error) {
+			return func(in <-chan int, out chan<- string) {
+				for i := range in {
+					out <- fmt.Sprint(cfg.Prefix, i)
+				}
+			}, nil
+		}
+	}))
+	var collected []string
+	mt := sync.Mutex{}
+	require.NoError(t, pipe.RegisterFinal(reg, "collector", func(struct{}) pipe.FinalProvider[string] {
+		return func() (pipe.FinalFunc[string], error) {
+			return func(in <-chan string) {
+				for i := range in {
+					mt.Lock()
+					collected = append(collected, i)
+					mt.Unlock()
+				}
+			}, nil
+		}
+	}))
+	return reg, &collected
+}
+
+func TestLoadYAML(t *testing.T) {
+	reg, collected := collectorRegistry(t)
+	r, err := reg.LoadYAML(strings.NewReader(`
+nodes:
+  - id: numbers
+    type: counter
+    config:
+      from: 1
+      to: 3
+    sendTo: [tenfold, bypassed]
+  - id: tenfold
+    type: multiplier
+    config:
+      factor: 10
+    sendTo: [formatter]
+  - id: bypassed
+    type: multiplier
+    config:
+      factor: 1
+    sendTo: [formatter]
+  - id: formatter
+    type: formatter
+    config:
+      prefix: "n="
+    sendTo: [collector]
+  - id: collector
+    type: collector
+`))
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	sort.Strings(*collected)
+	assert.Equal(t, []string{"n=1", "n=10", "n=2", "n=20", "n=3", "n=30"}, *collected)
+}
+
+func TestLoadJSON(t *testing.T) {
+	reg, collected := collectorRegistry(t)
+	r, err := reg.LoadJSON(strings.NewReader(`{"nodes": [
+		{"id": "numbers", "type": "counter", "config": {"from": 1, "to": 3}, "sendTo": ["formatter"]},
+		{"id": "formatter", "type": "formatter", "config": {"prefix": "#"}, "sendTo": ["collector"]},
+		{"id": "collector", "type": "collector"}
+	]}`))
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.Equal(t, []string{"#1", "#2", "#3"}, *collected)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		doc    string
+		expect string
+	}{{
+		name:   "no nodes",
+		doc:    `{"nodes": []}`,
+		expect: `the pipeline definition does not contain any node`,
+	}, {
+		name: "duplicate destination",
+		doc: `{"nodes": [{"id": "foo", "type": "counter", "sendTo": ["fmt"]},
+				{"id": "fmt", "type": "formatter", "sendTo": ["col", "col"]}, {"id": "col", "type": "collector"}]}`,
+		expect: `node "fmt": duplicate destination node "col"`,
+	}, {
+		name:   "unknown type",
+		doc:    `{"nodes": [{"id": "foo", "type": "bar"}]}`,
+		expect: `node "foo": unknown type "bar"`,
+	}, {
+		name: "duplicate id",
+		doc: `{"nodes": [{"id": "foo", "type": "counter", "sendTo": ["col"]},
+				{"id": "foo", "type": "counter", "sendTo": ["col"]}]}`,
+		expect: `node "foo": duplicate id`,
+	}, {
+		name: "type mismatch",
+		doc: `{"nodes": [{"id": "foo", "type": "counter", "sendTo": ["col"]},
+				{"id": "col", "type": "collector"}]}`,
+		expect: `node "foo": can't send data of type int to node "col", which accepts string`,
+	}, {
+		name: "unknown destination",
+		doc: `{"nodes": [{"id": "foo", "type": "counter", "sendTo": ["col"]},
+				{"id": "col2", "type": "collector"}]}`,
+		expect: `node "foo": unknown destination node "col"`,
+	}, {
+		name: "unconnected final",
+		doc: `{"nodes": [{"id": "foo", "type": "counter", "sendTo": ["fmt"]},
+				{"id": "fmt", "type": "formatter", "sendTo": ["col"]},
+				{"id": "col", "type": "collector"}, {"id": "col2", "type": "collector"}]}`,
+		expect: `node "col2": no node sends data to it`,
+	}, {
+		name:   "middle without destination",
+		doc:    `{"nodes": [{"id": "foo", "type": "counter", "sendTo": ["fmt"]}, {"id": "fmt", "type": "formatter"}]}`,
+		expect: `node "fmt": Middle nodes must send data to other nodes`,
+	}, {
+		name: "cycle",
+		doc: `{"nodes": [{"id": "foo", "type": "counter", "sendTo": ["m1"]},
+				{"id": "m1", "type": "multiplier", "sendTo": ["m2"]},
+				{"id": "m2", "type": "multiplier", "sendTo": ["m3", "fmt"]},
+				{"id": "m3", "type": "multiplier", "sendTo": ["m1"]},
+				{"id": "fmt", "type": "formatter", "sendTo": ["col"]}, {"id": "col", "type": "collector"}]}`,
+		expect: `node "m1": the connections form a cycle: m1 -> m2 -> m3 -> m1`,
+	}, {
+		name: "nil function",
+		doc: `{"nodes": [{"id": "foo", "type": "counter", "sendTo": ["fmt"]},
+				{"id": "fmt", "type": "formatter", "sendTo": ["nil"]}, {"id": "nil", "type": "nilCollector"}]}`,
+		expect: `instantiating node "nil": the provider returned a nil function`,
+	}, {
+		name:   "wrong configuration",
+		doc:    `{"nodes": [{"id": "foo", "type": "counter", "config": {"from": "one"}, "sendTo": ["fmt"]}, {"id": "fmt", "type": "formatter", "sendTo": ["col"]}, {"id": "col", "type": "collector"}]}`,
+		expect: `instantiating node "foo"`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg, _ := collectorRegistry(t)
+			require.NoError(t, pipe.RegisterFinal(reg, "nilCollector", func(struct{}) pipe.FinalProvider[string] {
+				return func() (pipe.FinalFunc[string], error) {
+					return nil, nil
+				}
+			}))
+			_, err := reg.LoadJSON(strings.NewReader(tc.doc))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expect)
+		})
+	}
+}
+
+func TestLoadYAML_Empty(t *testing.T) {
+	for _, doc := range []string{"", "  \n \n", "# just a comment\n", "nodes: []\n"} {
+		reg, _ := collectorRegistry(t)
+		_, err := reg.LoadYAML(strings.NewReader(doc))
+		require.Error(t, err, "document: %q", doc)
+		assert.Contains(t, err.Error(), "the pipeline definition does not contain any node")
+	}
+}
+
+func TestLoad_NodeOptions(t *testing.T) {
+	reg, collected := collectorRegistry(t)
+	for _, pausable := range []bool{false, true} {
+		r, err := reg.LoadYAML(strings.NewReader(fmt.Sprintf(`
+nodes:
+  - id: count
+    type: counter
+    config: {from: 1, to: 3}
+    sendTo: [fmt]
+    options:
+      pausable: %v
+  - id: fmt
+    type: formatter
+    sendTo: [col]
+    options:
+      channelBufferLen: 10
+  - id: col
+    type: collector
+`, pausable)))
+		require.NoError(t, err)
+		if pausable {
+			require.NoError(t, r.Pause())
+			r.Resume()
+		} else {
+			require.ErrorIs(t, r.Pause(), pipe.ErrNotPausable)
+		}
+		require.NoError(t, r.Run())
+	}
+	assert.Equal(t, []string{"1", "2", "3", "1", "2", "3"}, *collected)
+}
+
+func TestLoad_Watchdog(t *testing.T) {
+	reg, _ := collectorRegistry(t)
+	unblock := make(chan struct{})
+	require.NoError(t, pipe.RegisterFinal(reg, "stuck", func(struct{}) pipe.FinalProvider[string] {
+		return func() (pipe.FinalFunc[string], error) {
+			return func(in <-chan string) {
+				<-unblock
+				for range in {
+				}
+			}, nil
+		}
+	}))
+	clock := testers.NewFakeClock(time.Now())
+	stalls := make(chan *pipe.StallError, 10)
+	r, err := reg.LoadJSON(strings.NewReader(`{"nodes": [
+		{"id": "numbers", "type": "counter", "config": {"from": 1, "to": 3}, "sendTo": ["formatter"]},
+		{"id": "formatter", "type": "formatter", "sendTo": ["stuck"]},
+		{"id": "stuck", "type": "stuck"}
+	]}`), pipe.WithClock(clock), pipe.Watchdog(time.Second,
+		pipe.WatchdogOnStall(func(err *pipe.StallError) { stalls <- err })))
+	require.NoError(t, err)
+	r.Start()
+
+	var stall *pipe.StallError
+	for i := 0; stall == nil && i < 100; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(250 * time.Millisecond)
+		select {
+		case stall = <-stalls:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	require.NotNil(t, stall)
+	var names []string
+	for _, node := range stall.Chain {
+		names = append(names, node.Name)
+	}
+	// the numbers node is not blocked, as it sent all its items before the formatter got stuck
+	assert.Equal(t, []string{"formatter", "stuck"}, names)
+
+	close(unblock)
+	testers.ReadChannel(t, r.Done(), timeout)
+}
+
+func TestRegistry_DuplicateType(t *testing.T) {
+	reg, _ := collectorRegistry(t)
+	err := pipe.RegisterStart(reg, "counter", func(cfg counterCfg) pipe.StartProvider[int] {
+		return func() (pipe.StartFunc[int], error) {
+			return Counter(cfg.From, cfg.To), nil
+		}
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"counter" is already registered`)
+}
@@ -0,0 +1,194 @@
+package pipe
+
+import (
+	"sync/atomic"
+
+	"github.com/mariomac/pipes/pipe/internal/connect"
+)
+
+// AddMap creates a Middle node that forwards the result of invoking the provided function
+// for each received item. The node will be assigned to the field of the NodesMap whose
+// pointer is returned by the provided MiddlePtr function.
+//
+// Contrary to the nodes created with AddMiddle, the Map and Filter nodes process the items
+// one by one through a pure function, so straight chains of Map and Filter nodes are fused
+// into a single goroutine, without any intermediate channel: if a Map or Filter node only sends
+// data to another Map or Filter node that doesn't receive data from any other node,
+// the destination function is invoked in the goroutine of the sender node.
+// The ChannelBufferLen and Batching options only apply to the input of the first node of a fused
+// chain. The nodes created with the RingBuffer option are not fused, so it splits a chain into
+// multiple goroutines, connected through ring buffers instead of channels.
+func AddMap[IMPL NodesMap, IN, OUT any](p *Builder[IMPL], field MiddlePtr[IMPL, IN, OUT], fn func(IN) OUT, opts ...Option) {
+	addPerItem(p, field, func(in IN) (OUT, bool) {
+		return fn(in), true
+	}, opts)
+}
+
+// AddFilter creates a Middle node that only forwards the received items that are accepted
+// by the provided predicate function. The node will be assigned to the field of the NodesMap
+// whose pointer is returned by the provided MiddlePtr function.
+// The rejected *Pooled items are released.
+// Straight chains of Map and Filter nodes are fused into a single goroutine. See AddMap for
+// more details.
+func AddFilter[IMPL NodesMap, T any](p *Builder[IMPL], field MiddlePtr[IMPL, T, T], accept func(T) bool, opts ...Option) {
+	addPerItem(p, field, func(in T) (T, bool) {
+		return in, accept(in)
+	}, opts)
+}
+
+func addPerItem[IMPL NodesMap, IN, OUT any](p *Builder[IMPL], field MiddlePtr[IMPL, IN, OUT], fn func(IN) (OUT, bool), opts []Option) {
+	opts = p.joinOpts(opts...)
+	node := asPerItem(fn, opts...)
+	dstAddress := field(p.nodesMap)
+	p.middleNodes[fieldAddress(dstAddress)] = func(nodesMap IMPL, _ string, fresh bool) (inspectable, error) {
+		if !fresh {
+			return node, nil
+		}
+		freshNode := asPerItem(fn, opts...)
+		*field(nodesMap) = freshNode
+		return freshNode, nil
+	}
+	*(dstAddress) = node
+}
+
+// fusable nodes can be invoked from the goroutine of their sender node
+type fusable[IN any] interface {
+	Receiver[IN]
+	// sink starts the node as part of the goroutine of its sender. It returns a function that
+	// processes each item, and a function that must be invoked after the last item
+	sink() (push func(IN), release func())
+	// unfusable returns true if the node must run in its own goroutine, as it receives the data
+	// through a ring buffer
+	unfusable() bool
+}
+
+// fuser nodes can fuse their destination node into their own goroutine
+type fuser interface {
+	// fuse the destination node if it's fusable and it doesn't receive data from any other node.
+	// The senders map counts the number of nodes sending data to each Joiner.
+	fuse(senders map[any]int)
+}
+
+// perItem is a Middle node that processes the items one by one, through a function that
+// returns the output item and whether it must be forwarded.
+type perItem[IN, OUT any] struct {
+	receiverGroup[OUT]
+	inputs connect.Joiner[IN]
+	// unfused is true if the node can't be fused into the goroutine of its sender, as it was
+	// created with the RingBuffer option
+	unfused bool
+	started bool
+	fn      func(IN) (OUT, bool)
+	running atomic.Bool
+	// next is the destination node that is fused into the goroutine of this node
+	next fusable[OUT]
+}
+
+func asPerItem[IN, OUT any](fn func(IN) (OUT, bool), opts ...Option) *perItem[IN, OUT] {
+	options := getOptions(opts...)
+	return &perItem[IN, OUT]{
+		receiverGroup: newReceiverGroup[OUT](&options),
+		inputs:        newJoiner[IN](&options),
+		fn:            fn,
+		unfused:       options.ringBufferLen > 0 || options.unfused,
+	}
+}
+
+//nolint:unused
+func (pi *perItem[IN, OUT]) fuse(senders map[any]int) {
+	if pi.taps != nil || len(pi.Outs) != 1 {
+		return
+	}
+	if next, ok := pi.Outs[0].(fusable[OUT]); ok && !next.unfusable() && senders[next.joiners()[0]] == 1 {
+		pi.next = next
+	}
+}
+
+//nolint:unused
+func (pi *perItem[IN, OUT]) sink() (func(IN), func()) {
+	return pi.process()
+}
+
+//nolint:unused
+func (pi *perItem[IN, OUT]) unfusable() bool {
+	return pi.unfused
+}
+
+// process returns a function that processes each item in the invoker goroutine, and a function
+// that must be invoked after the last item
+//
+//nolint:unused
+func (pi *perItem[IN, OUT]) process() (func(IN), func()) {
+	pi.started = true
+	pi.running.Store(true)
+	var push func(OUT)
+	var release func()
+	if pi.next != nil {
+		push, release = pi.next.sink()
+	} else {
+		forker, err := pi.StartReceivers()
+		if err != nil {
+			panic("middle: " + err.Error())
+		}
+		push, release = forker.AcquirePusher()
+	}
+	fn := pi.fn
+	var zero IN
+	_, refs := any(zero).(connect.RefCounted)
+	return func(in IN) {
+			if o, ok := fn(in); ok {
+				push(o)
+			} else if refs {
+				// the dropped item won't be released by any destination
+				any(in).(connect.RefCounted).Release()
+			}
+		}, func() {
+			release()
+			pi.running.Store(false)
+		}
+}
+
+//nolint:unused
+func (pi *perItem[IN, OUT]) start() {
+	push, release := pi.process()
+	pi.spawn.Go(func() {
+		pi.inputs.Consume(push)
+		release()
+	})
+}
+
+//nolint:unused
+func (pi *perItem[IN, OUT]) track(tracker *connect.Tracker, name string) {
+	pi.receiverGroup.track(tracker, name)
+	pi.inputs.Track(pi.spawn)
+}
+
+//nolint:unused
+func (pi *perItem[IN, OUT]) joiners() []*connect.Joiner[IN] {
+	return []*connect.Joiner[IN]{&pi.inputs}
+}
+
+//nolint:unused
+func (pi *perItem[IN, OUT]) isStarted() bool {
+	return pi.started
+}
+
+//nolint:unused
+func (pi *perItem[IN, OUT]) isRunning() bool {
+	return pi.running.Load()
+}
+
+//nolint:unused
+func (pi *perItem[IN, OUT]) bufferedItems() int {
+	return pi.inputs.Buffered()
+}
+
+//nolint:unused
+func (pi *perItem[IN, OUT]) input() any {
+	return &pi.inputs
+}
+
+//nolint:unused
+func (pi *perItem[IN, OUT]) watch() *connect.Probe {
+	return pi.inputs.Watch(pi.spawn)
+}
@@ -0,0 +1,153 @@
+package pipe_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+type mapFilterPipe struct {
+	numbers pipe.Start[int]
+	others  pipe.Start[int]
+	double  pipe.Middle[int, int]
+	bigs    pipe.Middle[int, int]
+	format  pipe.Middle[int, string]
+	store   pipe.Final[string]
+}
+
+func (m *mapFilterPipe) Connect() {
+	m.numbers.SendTo(m.double)
+	m.double.SendTo(m.bigs)
+	m.bigs.SendTo(m.format)
+	m.format.SendTo(m.store)
+}
+
+func (m *mapFilterPipe) numbersPtr() *pipe.Start[int]         { return &m.numbers }
+func (m *mapFilterPipe) othersPtr() *pipe.Start[int]          { return &m.others }
+func (m *mapFilterPipe) doublePtr() *pipe.Middle[int, int]    { return &m.double }
+func (m *mapFilterPipe) bigsPtr() *pipe.Middle[int, int]      { return &m.bigs }
+func (m *mapFilterPipe) formatPtr() *pipe.Middle[int, string] { return &m.format }
+func (m *mapFilterPipe) storePtr() *pipe.Final[string]        { return &m.store }
+
+// mapFilterFanIn sends also data to the bigs node from another start node
+type mapFilterFanIn mapFilterPipe
+
+func (m *mapFilterFanIn) Connect() {
+	(*mapFilterPipe)(m).Connect()
+	m.others.SendTo(m.bigs)
+}
+
+func TestMapFilter(t *testing.T) {
+	unblock := make(chan struct{})
+	var stored []string
+	nodes := &mapFilterPipe{}
+	b := pipe.NewBuilder(nodes)
+	pipe.AddStart(b, (*mapFilterPipe).numbersPtr, Counter(1, 6))
+	pipe.AddMap(b, (*mapFilterPipe).doublePtr, func(i int) int { return 2 * i })
+	pipe.AddFilter(b, (*mapFilterPipe).bigsPtr, func(i int) bool { return i > 4 })
+	pipe.AddMap(b, (*mapFilterPipe).formatPtr, strconv.Itoa)
+	pipe.AddFinal(b, (*mapFilterPipe).storePtr, func(in <-chan string) {
+		<-unblock
+		for i := range in {
+			stored = append(stored, i)
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	// the chain of Map and Filter nodes runs in the goroutine of the double node
+	assert.Equal(t, []string{"double", "numbers", "store"}, liveOwners(r))
+
+	close(unblock)
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.Equal(t, []string{"6", "8", "10", "12"}, stored)
+}
+
+func TestMapFilter_RingBuffer(t *testing.T) {
+	unblock := make(chan struct{})
+	var stored []string
+	nodes := &mapFilterPipe{}
+	b := pipe.NewBuilder(nodes)
+	pipe.AddStart(b, (*mapFilterPipe).numbersPtr, Counter(1, 100))
+	// the double node receives data from a Start function, which is pushed to the
+	// ring buffer through an extra goroutine
+	pipe.AddMap(b, (*mapFilterPipe).doublePtr, func(i int) int { return 2 * i }, pipe.RingBuffer(4))
+	pipe.AddFilter(b, (*mapFilterPipe).bigsPtr, func(i int) bool { return i > 4 }, pipe.RingBuffer(4))
+	pipe.AddMap(b, (*mapFilterPipe).formatPtr, strconv.Itoa)
+	pipe.AddFinal(b, (*mapFilterPipe).storePtr, func(in <-chan string) {
+		<-unblock
+		for i := range in {
+			stored = append(stored, i)
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	// the bigs node runs in its own goroutine, with the fused format node
+	assert.Equal(t, []string{"bigs", "double", "numbers", "store"}, liveOwners(r))
+
+	close(unblock)
+	testers.ReadChannel(t, r.Done(), timeout)
+	require.Len(t, stored, 98)
+	for i, s := range stored {
+		require.Equal(t, strconv.Itoa(2*(i+3)), s)
+	}
+	testers.VerifyNoLeaks(t, r)
+}
+
+func TestMapFilter_FanIn(t *testing.T) {
+	unblock := make(chan struct{})
+	var stored []string
+	nodes := &mapFilterFanIn{}
+	b := pipe.NewBuilder(nodes)
+	pipe.AddStart(b, func(m *mapFilterFanIn) *pipe.Start[int] { return &m.numbers }, Counter(1, 5))
+	pipe.AddStart(b, func(m *mapFilterFanIn) *pipe.Start[int] { return &m.others }, func(out chan<- int) {
+		// keep the bigs node input open until all the start nodes are running
+		<-unblock
+		out <- 100
+	})
+	pipe.AddMap(b, func(m *mapFilterFanIn) *pipe.Middle[int, int] { return &m.double },
+		func(i int) int { return 2 * i })
+	pipe.AddFilter(b, func(m *mapFilterFanIn) *pipe.Middle[int, int] { return &m.bigs },
+		func(i int) bool { return i > 4 })
+	pipe.AddMap(b, func(m *mapFilterFanIn) *pipe.Middle[int, string] { return &m.format }, strconv.Itoa)
+	pipe.AddFinal(b, func(m *mapFilterFanIn) *pipe.Final[string] { return &m.store },
+		func(in <-chan string) {
+			<-unblock
+			for i := range in {
+				stored = append(stored, i)
+			}
+		})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	// the bigs node receives data from two nodes, so it can't be fused into the double node
+	assert.Equal(t, []string{"bigs", "double", "numbers", "others", "store"}, liveOwners(r))
+
+	close(unblock)
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.ElementsMatch(t, []string{"6", "8", "10", "100"}, stored)
+}
+
+// liveOwners returns the names of the nodes whose goroutines are alive
+func liveOwners(r *pipe.Runner) []string {
+	owners := map[string]struct{}{}
+	var names []string
+	for _, g := range r.LiveGoroutines() {
+		owner, _, _ := strings.Cut(g, ":")
+		if _, ok := owners[owner]; !ok {
+			owners[owner] = struct{}{}
+			names = append(names, owner)
+		}
+	}
+	return names
+}
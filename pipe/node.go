@@ -4,7 +4,10 @@
 package pipe
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sync/atomic"
 
 	"github.com/mariomac/pipes/pipe/internal/connect"
 )
@@ -13,6 +16,10 @@ import (
 // values to that channel during an indefinite amount of time.
 type StartFunc[OUT any] func(out chan<- OUT)
 
+// CancelableStartFunc is a StartFunc that receives a context, which is cancelled when the Runner
+// is shut down, and returns an error if it failed. It must return after the context is cancelled.
+type CancelableStartFunc[OUT any] func(ctx context.Context, out chan<- OUT) error
+
 // MiddleFunc is a function that receives a readable channel as first argument,
 // and a writable channel as second argument.
 // It must process the inputs from the input channel until it's closed.
@@ -63,17 +70,32 @@ type Final[IN any] interface {
 // An start node must have at least one output node.
 type start[OUT any] struct {
 	receiverGroup[OUT]
-	fun StartFunc[OUT]
+	fun      CancelableStartFunc[OUT]
+	pausable bool
+	// cancelable is true if the node was created from a CancelableStartFunc, so it can be stopped
+	// by cancelling its context
+	cancelable bool
+	// relay is true for the input nodes of the sub-pipelines, which forward the data of
+	// the parent pipeline, so they are paused along with the parent nodes
+	relay bool
+	// supervisor is nil if the node is not supervised
+	supervisor *superviseOptions
+	// provider is only set for nodes created from a StartProvider or a CancelableStartProvider,
+	// and it's invoked to create the function of a restarted supervised node
+	provider CancelableStartProvider[OUT]
+	clock    Clock
+	running  atomic.Bool
 }
 
 // middle is any intermediate node that receives data from another node, processes/filters it,
 // and forwards the data to another node.
 // An middle node must have at least one output node.
 type middle[IN, OUT any] struct {
-	outs    []Receiver[OUT]
+	receiverGroup[OUT]
 	inputs  connect.Joiner[IN]
 	started bool
 	fun     MiddleFunc[IN, OUT]
+	running atomic.Bool
 }
 
 func (m *middle[IN, OUT]) joiners() []*connect.Joiner[IN] {
@@ -84,10 +106,6 @@ func (m *middle[IN, OUT]) isStarted() bool {
 	return m.started
 }
 
-func (m *middle[IN, OUT]) SendTo(outputs ...Receiver[OUT]) {
-	m.outs = append(m.outs, outputs...)
-}
-
 // terminal is any node that receives data from another node and does not forward it to another node,
 // but can process it and send the results to outside the pipeline (e.g. memory, storage, web...)
 type terminal[IN any] struct {
@@ -95,6 +113,8 @@ type terminal[IN any] struct {
 	started bool
 	fun     FinalFunc[IN]
 	done    chan struct{}
+	running atomic.Bool
+	spawn   connect.Spawner
 }
 
 func (t *terminal[IN]) joiners() []*connect.Joiner[IN] {
@@ -126,13 +146,36 @@ func (t *terminal[IN]) Done() <-chan struct{} {
 
 // asStart wraps a group of StartFunc with the same signature into a start node.
 // TODO: let just 1 start function as argument
-func asStart[OUT any](fun StartFunc[OUT]) *start[OUT] {
+func asStart[OUT any](fun StartFunc[OUT], opts ...Option) *start[OUT] {
+	return newStart(uncancelable(fun), false, opts...)
+}
+
+// newStart wraps a CancelableStartFunc into a start node. If cancelable is true, the node
+// can be stopped by cancelling the context that is passed to the function.
+func newStart[OUT any](fun CancelableStartFunc[OUT], cancelable bool, opts ...Option) *start[OUT] {
 	if fun == nil {
 		return nil
 	}
+	options := getOptions(opts...)
 	return &start[OUT]{
 		fun:           fun,
-		receiverGroup: receiverGroup[OUT]{},
+		receiverGroup: newReceiverGroup[OUT](&options),
+		pausable:      options.pausable,
+		cancelable:    cancelable,
+		supervisor:    options.supervisor,
+		clock:         options.clock,
+	}
+}
+
+// uncancelable converts a StartFunc into a CancelableStartFunc that ignores its context
+// and never fails. It returns nil if the StartFunc is nil.
+func uncancelable[OUT any](fun StartFunc[OUT]) CancelableStartFunc[OUT] {
+	if fun == nil {
+		return nil
+	}
+	return func(_ context.Context, out chan<- OUT) error {
+		fun(out)
+		return nil
 	}
 }
 
@@ -140,8 +183,9 @@ func asStart[OUT any](fun StartFunc[OUT]) *start[OUT] {
 func asMiddle[IN, OUT any](fun MiddleFunc[IN, OUT], opts ...Option) *middle[IN, OUT] {
 	options := getOptions(opts...)
 	return &middle[IN, OUT]{
-		inputs: connect.NewJoiner[IN](options.channelBufferLen),
-		fun:    fun,
+		receiverGroup: newReceiverGroup[OUT](&options),
+		inputs:        newJoiner[IN](&options),
+		fun:           fun,
 	}
 }
 
@@ -152,7 +196,7 @@ func asFinal[IN any](fun FinalFunc[IN], opts ...Option) *terminal[IN] {
 	}
 	options := getOptions(opts...)
 	return &terminal[IN]{
-		inputs: connect.NewJoiner[IN](options.channelBufferLen),
+		inputs: newJoiner[IN](&options),
 		fun:    fun,
 		done:   make(chan struct{}),
 	}
@@ -164,6 +208,12 @@ func (sn *start[OUT]) Start() {
 	// a nil start node can be started without no effect on the pipeline.
 	// this allows setting optional nillable start nodes and let start all of them
 	// as a group in a more convenient way
+	sn.startGated(context.Background(), nil)
+}
+
+// startGated starts the node. If the node is pausable, its output is only forwarded
+// while the gate is open. The context is passed to the CancelableStartFunc of the node.
+func (sn *start[OUT]) startGated(ctx context.Context, gate *connect.Gate) {
 	if sn == nil {
 		return
 	}
@@ -171,30 +221,43 @@ func (sn *start[OUT]) Start() {
 	if err != nil {
 		panic("start: " + err.Error())
 	}
+	if sn.pausable && gate != nil {
+		gated := connect.ForkGated(sn.spawn, forker, gate, sn.bufLen)
+		forker = &gated
+	}
 
-	go func() {
-		sn.fun(forker.AcquireSender())
+	sn.running.Store(true)
+	sn.spawn.Go(func() {
+		sn.supervise(ctx, forker.AcquireSender())
 		forker.ReleaseSender()
-	}()
+		sn.running.Store(false)
+	})
+}
+
+// isPausable returns true if the node can be paused. A nil start node is
+// considered pausable as it never forwards data.
+func (sn *start[OUT]) isPausable() bool {
+	return sn == nil || sn.pausable || sn.relay
+}
+
+// isStoppable returns true if the node is not running or it can be stopped by the Runner's
+// Shutdown method: it is pausable, or it was created from a CancelableStartFunc.
+func (sn *start[OUT]) isStoppable() bool {
+	return sn == nil || !sn.running.Load() || sn.isPausable() || sn.cancelable
 }
 
 func (m *middle[IN, OUT]) start() {
-	if len(m.outs) == 0 {
-		panic("middle node should have outputs")
-	}
 	m.started = true
-	joiners := make([]*connect.Joiner[OUT], 0, len(m.outs))
-	for _, out := range m.outs {
-		joiners = append(joiners, out.joiners()...)
-		if !out.isStarted() {
-			out.start()
-		}
+	forker, err := m.receiverGroup.StartReceivers()
+	if err != nil {
+		panic("middle: " + err.Error())
 	}
-	forker := connect.Fork(joiners...)
-	go func() {
+	m.running.Store(true)
+	m.spawn.Go(func() {
 		m.fun(m.inputs.Receiver(), forker.AcquireSender())
 		forker.ReleaseSender()
-	}()
+		m.running.Store(false)
+	})
 }
 
 func (t *terminal[IN]) start() {
@@ -202,10 +265,24 @@ func (t *terminal[IN]) start() {
 		return
 	}
 	t.started = true
-	go func() {
+	t.running.Store(true)
+	t.spawn.Go(func() {
 		t.fun(t.inputs.Receiver())
+		t.running.Store(false)
 		close(t.done)
-	}()
+	})
+}
+
+// newJoiner creates the input Joiner of a node, whose transport depends on the options
+func newJoiner[IN any](options *creationOptions) connect.Joiner[IN] {
+	switch {
+	case options.ringBufferLen > 0:
+		return connect.NewRingJoiner[IN](options.channelBufferLen, options.ringBufferLen)
+	case options.batchSize > 0:
+		return connect.NewBatchedJoiner[IN](options.channelBufferLen,
+			options.batchSize, options.batchLatency, options.clock)
+	}
+	return connect.NewJoiner[IN](options.channelBufferLen)
 }
 
 func getOptions(opts ...Option) creationOptions {
@@ -219,7 +296,48 @@ func getOptions(opts ...Option) creationOptions {
 // receiverGroup connects a sender node with a collection
 // of Final nodes through a common connect.Forker instance.
 type receiverGroup[OUT any] struct {
-	Outs []Receiver[OUT]
+	Outs        []Receiver[OUT]
+	routingMode connect.RoutingMode
+	bufLen      int
+	// taps is only set for the nodes created with the Tappable option
+	taps *connect.Taps[OUT]
+	// cloneOnFanOut is only set for the nodes created with the CloneOnFanOut option
+	cloneOnFanOut func(OUT) OUT
+	// optionsErr is not nil if the options of the node can't be applied to its item type
+	optionsErr error
+	// spawn starts the goroutines of the node and its output connection
+	spawn connect.Spawner
+}
+
+func newReceiverGroup[OUT any](options *creationOptions) receiverGroup[OUT] {
+	rg := receiverGroup[OUT]{routingMode: options.routingMode, bufLen: options.channelBufferLen}
+	if options.tappable {
+		rg.taps = &connect.Taps[OUT]{}
+	}
+	if options.cloneOnFanOut {
+		var item OUT
+		if _, ok := any(item).(Cloner[OUT]); ok {
+			rg.cloneOnFanOut = func(o OUT) OUT {
+				return any(o).(Cloner[OUT]).Clone()
+			}
+		} else {
+			rg.optionsErr = fmt.Errorf("CloneOnFanOut: %T does not implement pipe.Cloner[%[1]T]", item)
+		}
+	}
+	return rg
+}
+
+//nolint:unused
+func (rg *receiverGroup[OUT]) tapper() *connect.Taps[OUT] {
+	return rg.taps
+}
+
+//nolint:unused
+func (sn *start[OUT]) tapper() *connect.Taps[OUT] {
+	if sn == nil {
+		return nil
+	}
+	return sn.taps
 }
 
 // SendTo connects a group of receivers to the current receiverGroup
@@ -239,16 +357,182 @@ func (rg *receiverGroup[OUT]) SendTo(outputs ...Receiver[OUT]) {
 // StartReceivers start the receivers and return a connection
 // forker to them
 func (rg *receiverGroup[OUT]) StartReceivers() (*connect.Forker[OUT], error) {
+	if rg.taps != nil {
+		return rg.startTappable(), nil
+	}
 	if len(rg.Outs) == 0 {
 		return nil, errors.New("node should have outputs")
 	}
-	joiners := make([]*connect.Joiner[OUT], 0, len(rg.Outs))
+	return rg.forkRoutes(), nil
+}
+
+// forkRoutes starts the receivers and returns a connection forker to them
+func (rg *receiverGroup[OUT]) forkRoutes() *connect.Forker[OUT] {
+	routes := make([]connect.Route[OUT], 0, len(rg.Outs))
+	fanOut := len(rg.destinations()) > 1
 	for _, out := range rg.Outs {
-		joiners = append(joiners, out.joiners()...)
+		rt := connect.Route[OUT]{Joiners: out.joiners()}
+		if fanOut {
+			rt.CloneOnFanOut = rg.cloneOnFanOut
+		}
+		if r, ok := out.(*route[OUT]); ok {
+			rt.Accept, rt.Default, rt.Observe = r.accept, r.isDefault, r.observe
+			if r.clone != nil {
+				rt.Clone, rt.CloneOnFanOut = r.clone, nil
+			}
+		}
+		routes = append(routes, rt)
 		if !out.isStarted() {
 			out.start()
 		}
 	}
-	forker := connect.Fork(joiners...)
-	return &forker, nil
+	forker := connect.ForkRoutes(rg.spawn, rg.routingMode, routes...)
+	return &forker
+}
+
+// startTappable starts the receivers and returns a connection forker that also
+// forwards the data to the Final nodes that are dynamically attached to the taps.
+// Tappable nodes can be started without outputs.
+func (rg *receiverGroup[OUT]) startTappable() *connect.Forker[OUT] {
+	var static *connect.Forker[OUT]
+	if len(rg.Outs) > 0 {
+		static = rg.forkRoutes()
+	}
+	forker := connect.ForkTaps(rg.spawn, static, rg.taps, rg.bufLen, rg.cloneOnFanOut)
+	return &forker
+}
+
+//nolint:unused
+func (rg *receiverGroup[OUT]) checkOptions() error {
+	return rg.optionsErr
+}
+
+//nolint:unused
+func (sn *start[OUT]) checkOptions() error {
+	if sn == nil {
+		return nil
+	}
+	return sn.optionsErr
+}
+
+//nolint:unused
+func (sn *start[OUT]) isRunning() bool {
+	return sn != nil && sn.running.Load()
+}
+
+//nolint:unused
+func (sn *start[OUT]) bufferedItems() int {
+	return 0
+}
+
+//nolint:unused
+func (m *middle[IN, OUT]) isRunning() bool {
+	return m.running.Load()
+}
+
+//nolint:unused
+func (m *middle[IN, OUT]) bufferedItems() int {
+	return m.inputs.Buffered()
+}
+
+//nolint:unused
+func (t *terminal[IN]) isRunning() bool {
+	return t != nil && t.running.Load()
+}
+
+//nolint:unused
+func (t *terminal[IN]) bufferedItems() int {
+	if t == nil {
+		return 0
+	}
+	return t.inputs.Buffered()
+}
+
+//nolint:unused
+func (rg *receiverGroup[OUT]) track(tracker *connect.Tracker, name string) {
+	rg.spawn = tracker.Spawner(name)
+	if rg.taps != nil {
+		rg.taps.Track(tracker.Spawner(name + tapsSuffix))
+	}
+}
+
+//nolint:unused
+func (sn *start[OUT]) track(tracker *connect.Tracker, name string) {
+	if sn != nil {
+		sn.receiverGroup.track(tracker, name)
+	}
+}
+
+//nolint:unused
+func (m *middle[IN, OUT]) track(tracker *connect.Tracker, name string) {
+	m.receiverGroup.track(tracker, name)
+	m.inputs.Track(m.spawn)
+}
+
+//nolint:unused
+func (t *terminal[IN]) track(tracker *connect.Tracker, name string) {
+	if t != nil {
+		t.spawn = tracker.Spawner(name)
+		t.inputs.Track(t.spawn)
+	}
+}
+
+//nolint:unused
+func (sn *start[OUT]) input() any {
+	return nil
+}
+
+//nolint:unused
+func (m *middle[IN, OUT]) input() any {
+	return &m.inputs
+}
+
+//nolint:unused
+func (t *terminal[IN]) input() any {
+	if t == nil {
+		return nil
+	}
+	return &t.inputs
+}
+
+//nolint:unused
+func (sn *start[OUT]) watch() *connect.Probe {
+	return nil
+}
+
+//nolint:unused
+func (m *middle[IN, OUT]) watch() *connect.Probe {
+	return m.inputs.Watch(m.spawn)
+}
+
+//nolint:unused
+func (t *terminal[IN]) watch() *connect.Probe {
+	if t == nil {
+		return nil
+	}
+	return t.inputs.Watch(t.spawn)
+}
+
+//nolint:unused
+func (rg *receiverGroup[OUT]) destinations() []any {
+	var dsts []any
+	for _, out := range rg.Outs {
+		for _, j := range out.joiners() {
+			dsts = append(dsts, j)
+		}
+	}
+	return dsts
+}
+
+//nolint:unused
+func (sn *start[OUT]) destinations() []any {
+	if sn == nil {
+		return nil
+	}
+	return sn.receiverGroup.destinations()
+}
+
+//nolint:unused
+func (t *terminal[IN]) destinations() []any {
+	return nil
 }
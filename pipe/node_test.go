@@ -76,6 +76,37 @@ func TestBasicGraph(t *testing.T) {
 	}, collected)
 }
 
+func TestBasicGraph_Transports(t *testing.T) {
+	// the ring buffers are only used by the nodes with a single sender (oddsMsg and evensMsg)
+	for name, opts := range map[string][]pipe.Option{
+		"channels":     nil,
+		"ring buffers": {pipe.RingBuffer(4)},
+		"batching":     {pipe.Batching(2, 0)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := pipe.NewBuilder(&basicGraph{}, opts...)
+
+			pipe.AddStart(p, start1, Counter(1, 3))
+			pipe.AddStart(p, start2, Counter(6, 8))
+			pipe.AddMiddle(p, odds, OddFilter)
+			pipe.AddMiddle(p, evens, EvenFilter)
+			pipe.AddMiddle(p, oddsMsg, Messager("odd"))
+			pipe.AddMiddle(p, evensMsg, Messager("even"))
+			var collected []string
+			pipe.AddFinal(p, collector, collectInto(&collected))
+
+			r, err := p.Build()
+			require.NoError(t, err)
+			require.NoError(t, r.Run())
+
+			assert.ElementsMatch(t, []string{
+				"odd: 1", "even: 2", "odd: 3", "even: 6", "odd: 7", "even: 8",
+			}, collected)
+			helpers.VerifyNoLeaks(t, r)
+		})
+	}
+}
+
 type smfPipe struct {
 	start pipe.Start[int]
 	mid   pipe.Middle[int, int]
@@ -1,12 +1,41 @@
 package pipe
 
+import (
+	"time"
+
+	"github.com/mariomac/pipes/pipe/internal/connect"
+)
+
 type creationOptions struct {
 	// if 0, channel is unbuffered
 	channelBufferLen int
+	// if > 0, the node receives the items in batches of up to this size
+	batchSize    int
+	batchLatency time.Duration
+	// if > 0, Map and Filter nodes receive data from a single Map or Filter node through a ring buffer
+	ringBufferLen int
+	// if true, Map and Filter nodes are never fused into the goroutine of their sender. Only set by
+	// the benchmarks, to compare the transports of unfused nodes
+	unfused bool
+	// how the items are forwarded when a node sends data to conditional routes
+	routingMode connect.RoutingMode
+	// if true, a Sender node forwards a copy of each item to each of its destinations
+	cloneOnFanOut bool
+	// if true, Final nodes can be attached to the Sender node while the pipeline is running
+	tappable bool
+	// if true, the Start nodes stop forwarding data while the Runner is paused
+	pausable bool
+	// if not nil, the Start nodes are restarted according to the supervisor options
+	supervisor *superviseOptions
+	// clock used by the time-based features of the nodes
+	clock Clock
+	// if not nil, the Runner periodically checks whether the nodes are stalled
+	watchdog *watchdogOptions
 }
 
 var defaultOptions = creationOptions{
 	channelBufferLen: 0,
+	clock:            systemClock{},
 }
 
 // Option allows overriding the default properties of the nodes and connections of a pipeline.
@@ -20,3 +49,114 @@ func ChannelBufferLen(length int) Option {
 		options.channelBufferLen = length
 	}
 }
+
+// Batching is an Option that makes the senders of a node transfer the items to it in batches of up
+// to the given size, reducing the number of channel operations per item.
+// The node functions still send and receive the items one by one. Each sender forwards its batch
+// when it's full, or when the flushLatency time has passed since its first item was sent. If
+// flushLatency is 0, the batches are only forwarded when they are full or when the sender finishes.
+// The flush latency is measured with the Clock provided by the WithClock option.
+// The ChannelBufferLen option specifies the number of batches that can be buffered.
+//
+// The batches are directly created and consumed by the Map and Filter nodes, and by the senders
+// that forward data to multiple destinations. The functions of the nodes added with AddStart,
+// AddMiddle or AddFinal send and receive the items through channels, so each batched connection
+// from or to them adds up to two goroutine hops, which move the items between those channels and
+// the batches. For them, batching is a pessimization: in the BenchmarkChain benchmark, a chain of
+// Middle nodes is several times slower with batching than without it. Batching only pays off when
+// both ends of the connection are Map or Filter nodes that aren't fused, and the connection is
+// unbuffered: with buffered channels, the plain channels are faster. Benchmark your pipeline before
+// enabling this option.
+// Fused Map and Filter nodes ignore this option, as they don't have any input connection.
+func Batching(size int, flushLatency time.Duration) Option {
+	return func(options *creationOptions) {
+		options.batchSize = size
+		options.batchLatency = flushLatency
+	}
+}
+
+// RingBuffer is an Option that makes a node receive the data through a lock-free ring buffer of
+// the given size (rounded up to the next power of two), instead of through a channel. A blocked
+// sender or receiver spins for a while before being parked, which consumes extra CPU. Ring buffers
+// can be slower than channels: in the BenchmarkChain benchmark, a chain of Map nodes connected
+// through ring buffers is slower than the same chain connected through channels, even when the Map
+// nodes are not fused. Benchmark your pipeline before enabling this option.
+//
+// The ring buffer is only used when the node receives data from a single node. Otherwise, the node
+// input is a channel whose length is given by ChannelBufferLen.
+// The ring buffer is directly accessed by the Map and Filter nodes, and by the senders that forward
+// data to multiple destinations. The functions of the nodes added with AddStart, AddMiddle or
+// AddFinal send and receive the items through channels, so each ring-buffered connection from or to
+// them adds up to two goroutine hops: an ingress goroutine that moves the items from the output
+// channel of the sender node into the ring buffer, and another goroutine that moves them from the
+// ring buffer into the input channel of the receiver node. A chain of Middle nodes connected
+// through ring buffers is several times slower than through channels.
+// Map and Filter nodes created with this option are not fused into the goroutine of their sender.
+func RingBuffer(size int) Option {
+	return func(options *creationOptions) {
+		options.ringBufferLen = size
+	}
+}
+
+// RouteToFirstMatch is an Option that makes a Sender node forward each item only to the first
+// of its Route destinations whose predicate accepts the item, in the order they were passed
+// to SendTo. By default, the items are forwarded to all the matching routes.
+func RouteToFirstMatch() Option {
+	return func(options *creationOptions) {
+		options.routingMode = connect.RouteFirst
+	}
+}
+
+// Cloner is implemented by the item types that can be copied by the nodes created with the
+// CloneOnFanOut option.
+type Cloner[T any] interface {
+	// Clone returns a copy of the item that doesn't share any mutable data with the original.
+	Clone() T
+}
+
+// CloneOnFanOut is an Option that makes a Sender node forward a different copy of each item to
+// each of its destinations, when it sends data to more than one destination. The copies are
+// created by the Clone method of the items, so the item type must implement the Cloner interface.
+// Otherwise, the Builder's Build method returns an error.
+// One of the destinations receives the original item, after the copies for the rest of destinations
+// have been created. The destinations wrapped with Cloned use their own clone function. The Final
+// nodes that are attached to Tappable nodes are also destinations: each of them receives a copy,
+// unless the node has no other destination.
+func CloneOnFanOut() Option {
+	return func(options *creationOptions) {
+		options.cloneOnFanOut = true
+	}
+}
+
+// Tappable is an Option that allows attaching Final nodes to a Start or Middle node while the
+// pipeline is running, by means of the Attach function (e.g. to temporarily sample the data
+// forwarded by a node for debugging purposes).
+// Tappable nodes forward their output through an extra goroutine.
+func Tappable() Option {
+	return func(options *creationOptions) {
+		options.tappable = true
+	}
+}
+
+// Pausable is an Option that allows blocking the output of a Start node while its Runner is paused
+// by the Runner's Pause method, and closing it from the Runner's Shutdown method. It is usually
+// passed as a default option to the Builder, as the Pause method fails if any of the Start nodes
+// is not pausable.
+// Pausable Start nodes forward their output through an extra goroutine, so they can send one more
+// item than the buffer length of their destination nodes.
+func Pausable() Option {
+	return func(options *creationOptions) {
+		options.pausable = true
+	}
+}
+
+// WithClock is an Option that specifies the Clock used by the time-based features of the nodes
+// and the Runner, such as the Supervise backoff, the Batching flush latency, the Watchdog or the
+// Runner.Wait timeout. It is usually passed as a default option to the Builder, to replace the
+// system time by a fake Clock in tests. The functions created out of the Builder, such as Dedupe
+// or RetryFinal, receive their Clock through their own options (DedupeClock and RetryClock).
+func WithClock(clock Clock) Option {
+	return func(options *creationOptions) {
+		options.clock = clock
+	}
+}
@@ -0,0 +1,98 @@
+package pipe
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Pool of reusable items, to reduce the allocations of pipelines whose items are expensive
+// to create, like large []byte buffers. The Start nodes get the items from the Pool and
+// send them through the pipeline wrapped in a *Pooled item, and the Final nodes release
+// them when they have finished processing them:
+//
+//	buffers := pipe.NewPool(func() []byte { return make([]byte, 64*1024) }, nil)
+//	pipe.AddStart(p, (*MyPipe).Reader, func(out chan<- *pipe.Pooled[[]byte]) {
+//		for {
+//			buf := buffers.Get()
+//			n, err := conn.Read(buf.Value)
+//			...
+//			out <- buf
+//		}
+//	})
+//	pipe.AddFinal(p, (*MyPipe).Writer, pipe.Releasing(func(buf []byte) {
+//		...
+//	}))
+//
+// When a node sends a *Pooled item to multiple destinations, the item is returned to
+// the Pool only after all of them have released it. The items that are discarded by
+// the pipeline connections or Filter nodes (e.g. because they are not accepted by any Route or
+// Filter, or because they are sent after the Runner is shut down) are released automatically,
+// but the Middle functions that drop or replace the received items must release them.
+// The items that are never released are garbage collected, as with sync.Pool.
+type Pool[T any] struct {
+	pool  sync.Pool
+	reset func(T)
+}
+
+// NewPool creates a Pool whose new items are created by the newItem function. If the
+// reset function is not nil, it's invoked with the items that are returned to the Pool.
+func NewPool[T any](newItem func() T, reset func(T)) *Pool[T] {
+	p := &Pool[T]{reset: reset}
+	p.pool.New = func() any {
+		return &Pooled[T]{Value: newItem(), pool: p}
+	}
+	return p
+}
+
+// Get an item from the Pool, holding a single reference.
+func (p *Pool[T]) Get() *Pooled[T] {
+	item := p.pool.Get().(*Pooled[T])
+	item.refs.Store(1)
+	return item
+}
+
+func (p *Pool[T]) put(item *Pooled[T]) {
+	if p.reset != nil {
+		p.reset(item.Value)
+	}
+	p.pool.Put(item)
+}
+
+// Pooled wraps an item from a Pool, counting the references to it. The item is returned to
+// its Pool when all the references are released.
+type Pooled[T any] struct {
+	Value T
+	refs  atomic.Int32
+	pool  *Pool[T]
+}
+
+// Retain adds n references to the item. It's automatically invoked by the nodes that send the
+// item to multiple destinations, so it only needs to be explicitly invoked by nodes that keep
+// the item after releasing it (e.g. to forward it later).
+func (p *Pooled[T]) Retain(n int) {
+	p.refs.Add(int32(n))
+}
+
+// Release removes a reference from the item, returning it to its Pool if it was the last one.
+// The item must not be accessed after releasing it. Releasing an item more times than it was
+// retained panics.
+func (p *Pooled[T]) Release() {
+	refs := p.refs.Add(-1)
+	if refs < 0 {
+		panic("pipe: Pooled item released more times than retained")
+	}
+	if refs == 0 {
+		p.pool.put(p)
+	}
+}
+
+// Releasing creates a FinalFunc that invokes the provided function with the value of each
+// received Pooled item, and releases the item afterwards.
+func Releasing[T any](fn func(T)) FinalFunc[*Pooled[T]] {
+	return func(in <-chan *Pooled[T]) {
+		for item := range in {
+			fn(item.Value)
+			item.Release()
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package pipe_test
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+type poolBuffer struct {
+	id        int
+	processed atomic.Int32
+}
+
+type poolPipe struct {
+	start              pipe.Start[*pipe.Pooled[*poolBuffer]]
+	first, second, odd pipe.Final[*pipe.Pooled[*poolBuffer]]
+}
+
+func (p *poolPipe) Connect() {
+	p.start.SendTo(p.first, p.second, pipe.Route(func(b *pipe.Pooled[*poolBuffer]) bool {
+		return b.Value.id%2 == 1
+	}, p.odd))
+}
+
+func TestPool(t *testing.T) {
+	const items = 1000
+	var resets, unfinished atomic.Int32
+	pool := pipe.NewPool(func() *poolBuffer { return &poolBuffer{} }, func(b *poolBuffer) {
+		// the buffer is returned to the pool only after all its destinations processed it
+		expected := int32(2 + b.id%2)
+		if b.processed.Load() != expected {
+			unfinished.Add(1)
+		}
+		b.processed.Store(0)
+		resets.Add(1)
+	})
+
+	p := pipe.NewBuilder(&poolPipe{})
+	pipe.AddStart(p, func(p *poolPipe) *pipe.Start[*pipe.Pooled[*poolBuffer]] { return &p.start },
+		func(out chan<- *pipe.Pooled[*poolBuffer]) {
+			for i := 0; i < items; i++ {
+				b := pool.Get()
+				b.Value.id = i
+				out <- b
+			}
+		})
+	process := pipe.Releasing(func(b *poolBuffer) {
+		b.processed.Add(1)
+	})
+	pipe.AddFinal(p, func(p *poolPipe) *pipe.Final[*pipe.Pooled[*poolBuffer]] { return &p.first }, process)
+	pipe.AddFinal(p, func(p *poolPipe) *pipe.Final[*pipe.Pooled[*poolBuffer]] { return &p.second }, process)
+	pipe.AddFinal(p, func(p *poolPipe) *pipe.Final[*pipe.Pooled[*poolBuffer]] { return &p.odd }, process)
+	r, err := p.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.EqualValues(t, items, resets.Load())
+	assert.Zero(t, unfinished.Load())
+}
+
+type poolFilterPipe struct {
+	start pipe.Start[*pipe.Pooled[*poolBuffer]]
+	odds  pipe.Middle[*pipe.Pooled[*poolBuffer], *pipe.Pooled[*poolBuffer]]
+	final pipe.Final[*pipe.Pooled[*poolBuffer]]
+}
+
+func (p *poolFilterPipe) Connect() {
+	p.start.SendTo(p.odds)
+	p.odds.SendTo(p.final)
+}
+
+func TestPool_Filter(t *testing.T) {
+	const items = 1000
+	var resets, processed atomic.Int32
+	pool := pipe.NewPool(func() *poolBuffer { return &poolBuffer{} }, func(*poolBuffer) {
+		resets.Add(1)
+	})
+
+	p := pipe.NewBuilder(&poolFilterPipe{})
+	pipe.AddStart(p, func(p *poolFilterPipe) *pipe.Start[*pipe.Pooled[*poolBuffer]] { return &p.start },
+		func(out chan<- *pipe.Pooled[*poolBuffer]) {
+			for i := 0; i < items; i++ {
+				b := pool.Get()
+				b.Value.id = i
+				out <- b
+			}
+		})
+	pipe.AddFilter(p, func(p *poolFilterPipe) *pipe.Middle[*pipe.Pooled[*poolBuffer], *pipe.Pooled[*poolBuffer]] {
+		return &p.odds
+	}, func(b *pipe.Pooled[*poolBuffer]) bool {
+		return b.Value.id%2 == 1
+	})
+	pipe.AddFinal(p, func(p *poolFilterPipe) *pipe.Final[*pipe.Pooled[*poolBuffer]] { return &p.final },
+		pipe.Releasing(func(*poolBuffer) { processed.Add(1) }))
+	r, err := p.Build()
+	require.NoError(t, err)
+	require.NoError(t, r.Run())
+
+	// the items rejected by the Filter node are also returned to the pool
+	assert.EqualValues(t, items/2, processed.Load())
+	assert.EqualValues(t, items, resets.Load())
+}
+
+func TestPooled_Release(t *testing.T) {
+	var resets int
+	pool := pipe.NewPool(func() []byte { return make([]byte, 16) }, func([]byte) { resets++ })
+	item := pool.Get()
+	item.Retain(2)
+	item.Release()
+	item.Release()
+	assert.Zero(t, resets)
+	item.Release()
+	assert.Equal(t, 1, resets)
+	assert.Panics(t, item.Release)
+}
@@ -1,7 +1,7 @@
 package pipe
 
 import (
-	"reflect"
+	"fmt"
 )
 
 // NodesMap is any data structure that stores references to the nodes of a pipeline,
@@ -20,12 +20,58 @@ import (
 //
 // The fields are assigned to nodes by the Builder, by means of
 // AddStart, AddStartProvider, AddMiddle, AddMiddleProvider, AddFinal and AddFinalProvider
+//
+// Alternatively, the connections can be defined by means of "pipes" struct tags in the
+// NodesMap fields. See the AutoConnect type for more details.
 type NodesMap interface {
 	// Connect runs the code that connects the nodes of a pipeline. It is invoked
 	// by the Builder before returning the pipeline Runner.
 	Connect()
 }
 
+// NodesChecker can be optionally implemented by a NodesMap to verify that all its nodes
+// have been instantiated, before the Builder invokes its Connect method. If CheckNodes
+// returns an error, the Builder's Build method returns it instead of connecting the nodes.
+// The optional nodes can be added to the Builder with providers that return nil functions.
+// The pipesgen tool (github.com/mariomac/pipes/cmd/pipesgen) can generate its implementation.
+type NodesChecker interface {
+	CheckNodes() error
+}
+
+// NodeField associates the name of a NodesMap node field with its address.
+type NodeField struct {
+	name    string
+	address uintptr
+}
+
+// Field returns the NodeField for the node field with the provided name, whose pointer is passed
+// as second argument.
+func Field[T any](name string, field *T) NodeField {
+	return NodeField{name: name, address: fieldAddress(field)}
+}
+
+// NodesNamer can be optionally implemented by a NodesMap to provide the names of its node fields,
+// so the Builder doesn't need reflection to name the nodes in errors and diagnostics.
+// The pipesgen tool (github.com/mariomac/pipes/cmd/pipesgen) can generate its implementation.
+type NodesNamer interface {
+	NodeFields() []NodeField
+}
+
+// TagConnector can be optionally implemented by a NodesMap to connect the nodes declared
+// by means of "pipes" struct tags, so the Builder doesn't need reflection to inspect
+// the tags. See the AutoConnect type for more details.
+// The pipesgen tool (github.com/mariomac/pipes/cmd/pipesgen) can generate its implementation.
+type TagConnector interface {
+	ConnectTagged()
+}
+
+// NodesFactory can be optionally implemented by a NodesMap to create new, empty instances of
+// itself, so the Builder doesn't need reflection to build a sub-pipeline multiple times.
+// The pipesgen tool (github.com/mariomac/pipes/cmd/pipesgen) can generate its implementation.
+type NodesFactory interface {
+	NewNodes() NodesMap
+}
+
 // StartPtr is a function that, given a NodesMap, returns a pointer to a
 // Start node, which is going to be used as store destination
 // when this function is passed as argument to AddStartProvider
@@ -61,6 +107,9 @@ type FinalPtr[IMPL NodesMap, IN any] func(IMPL) *Final[IN]
 //	return IgnoreStart[T](), nil
 type StartProvider[OUT any] func() (StartFunc[OUT], error)
 
+// CancelableStartProvider is equivalent to StartProvider, for the CancelableStartFunc functions.
+type CancelableStartProvider[OUT any] func() (CancelableStartFunc[OUT], error)
+
 // MiddleProvider is a function that returns a MiddleFunc to be used as
 // Middle node in a pipeline. It also might return an error if there is a
 // problem with the configuration or instantiation of the function.
@@ -103,61 +152,126 @@ type FinalProvider[IN any] func() (FinalFunc[IN], error)
 // AddStartProvider registers a StartProviderFunc into the pipeline Builder.
 // The function returned by the StartProvider will be assigned to the NodesMap
 // field whose pointer is returned by the passed StartPtr function.
-func AddStartProvider[IMPL NodesMap, OUT any](p *Builder[IMPL], field StartPtr[IMPL, OUT], provider StartProvider[OUT]) {
-	dstAddress := reflect.ValueOf(field(p.nodesMap)).Pointer()
-	p.startNodes[dstAddress] = nodeOrProvider[startable]{
-		provider: &reflectProvider{
-			acceptNilFunc: true,
-			asNode:        reflect.ValueOf(asStart[OUT]),
-			fieldGetter:   reflect.ValueOf(field),
-			fn:            reflect.ValueOf(provider),
-		}}
+// The options related to the connections from that Start node can be overridden. Otherwise
+// the global options passed to the pipeline Builder are used.
+func AddStartProvider[IMPL NodesMap, OUT any](p *Builder[IMPL], field StartPtr[IMPL, OUT], provider StartProvider[OUT], opts ...Option) {
+	addStartProvider(p, field, func() (CancelableStartFunc[OUT], error) {
+		fn, err := provider()
+		return uncancelable(fn), err
+	}, false, opts...)
+}
+
+// AddCancelableStartProvider is equivalent to AddStartProvider, for a provider of a
+// CancelableStartFunc. The context that is passed to the function is cancelled when
+// the Runner is shut down.
+func AddCancelableStartProvider[IMPL NodesMap, OUT any](p *Builder[IMPL], field StartPtr[IMPL, OUT], provider CancelableStartProvider[OUT], opts ...Option) {
+	addStartProvider(p, field, provider, true, opts...)
+}
+
+func addStartProvider[IMPL NodesMap, OUT any](p *Builder[IMPL], field StartPtr[IMPL, OUT], provider CancelableStartProvider[OUT], cancelable bool, opts ...Option) {
+	opts = p.joinOpts(opts...)
+	p.startNodes[fieldAddress(field(p.nodesMap))] = func(nodesMap IMPL, _ string, _ bool) (startable, error) {
+		fn, err := provider()
+		if err != nil {
+			return nil, fmt.Errorf("error invoking provider: %w", err)
+		}
+		node := newStart(fn, cancelable, opts...)
+		if node != nil {
+			node.provider = provider
+		}
+		*field(nodesMap) = node
+		return node, nil
+	}
 }
 
 // AddMiddleProvider registers a MiddleProvider into the pipeline Builder.
 // The function returned by the MiddleProvider will be assigned to the NodesMap
 // field whose pointer is returned by the passed MiddlePtr function.
-func AddMiddleProvider[IMPL NodesMap, IN, OUT any](p *Builder[IMPL], field MiddlePtr[IMPL, IN, OUT], provider MiddleProvider[IN, OUT]) {
-	var i IN
-	var o OUT
-	// middle providers where IN & OUT are the same type can be bypassed if they return
-	// a middle function
-	var bypassableNode *reflect.Value
-	if reflect.TypeOf(i) == reflect.TypeOf(o) {
-		rv := reflect.ValueOf(&bypass[IN]{})
-		bypassableNode = &rv
+// The options related to the connections of that Middle node can be overridden. Otherwise
+// the global options passed to the pipeline Builder are used.
+func AddMiddleProvider[IMPL NodesMap, IN, OUT any](p *Builder[IMPL], field MiddlePtr[IMPL, IN, OUT], provider MiddleProvider[IN, OUT], opts ...Option) {
+	opts = p.joinOpts(opts...)
+	p.middleNodes[fieldAddress(field(p.nodesMap))] = func(nodesMap IMPL, _ string, _ bool) (inspectable, error) {
+		fn, err := provider()
+		if err != nil {
+			return nil, fmt.Errorf("error invoking provider: %w", err)
+		}
+		node, err := middleOrBypass(fn, opts...)
+		if err != nil {
+			return nil, err
+		}
+		*field(nodesMap) = node
+		// bypass nodes are not inspectable
+		in, _ := node.(inspectable)
+		return in, nil
 	}
-	dstAddress := reflect.ValueOf(field(p.nodesMap)).Pointer()
-	p.middleNodes[dstAddress] = nodeOrProvider[struct{}]{
-		provider: &reflectProvider{
-			middleBypasser: bypassableNode,
-			asNode:         reflect.ValueOf(asMiddle[IN, OUT]),
-			fieldGetter:    reflect.ValueOf(field),
-			fn:             reflect.ValueOf(provider),
-		}}
 }
 
 // AddFinalProvider registers a FinalProvider into the pipeline Builder.
 // The function returned by the FinalProvider will be assigned to the NodesMap
 // field whose pointer is returned by the passed FinalPtr function.
-func AddFinalProvider[IMPL NodesMap, IN any](p *Builder[IMPL], field FinalPtr[IMPL, IN], provider FinalProvider[IN]) {
-	dstAddress := reflect.ValueOf(field(p.nodesMap)).Pointer()
-	p.finalNodes[dstAddress] = nodeOrProvider[doneable]{
-		provider: &reflectProvider{
-			acceptNilFunc: true,
-			asNode:        reflect.ValueOf(asFinal[IN]),
-			fieldGetter:   reflect.ValueOf(field),
-			fn:            reflect.ValueOf(provider),
-		}}
+// The options related to the connection to that Final node can be overridden. Otherwise
+// the global options passed to the pipeline Builder are used.
+func AddFinalProvider[IMPL NodesMap, IN any](p *Builder[IMPL], field FinalPtr[IMPL, IN], provider FinalProvider[IN], opts ...Option) {
+	opts = p.joinOpts(opts...)
+	p.finalNodes[fieldAddress(field(p.nodesMap))] = func(nodesMap IMPL, _ string, _ bool) (doneable, error) {
+		fn, err := provider()
+		if err != nil {
+			return nil, fmt.Errorf("error invoking provider: %w", err)
+		}
+		node := asFinal(fn, opts...)
+		*field(nodesMap) = node
+		return node, nil
+	}
 }
 
 // AddStart creates a Start node given the provided StartFunc. The node will
 // be assigned to the field of the NodesMap whose pointer is returned by the
 // provided StartPtr function.
-func AddStart[IMPL NodesMap, OUT any](p *Builder[IMPL], field StartPtr[IMPL, OUT], fn StartFunc[OUT]) {
-	startNode := asStart(fn)
+// The options related to the connections from that Start node can be overridden. Otherwise
+// the global options passed to the pipeline Builder are used.
+func AddStart[IMPL NodesMap, OUT any](p *Builder[IMPL], field StartPtr[IMPL, OUT], fn StartFunc[OUT], opts ...Option) {
+	addStart(p, field, uncancelable(fn), false, opts...)
+}
+
+// AddCancelableStart is equivalent to AddStart, for a CancelableStartFunc. The context that is
+// passed to the function is cancelled when the Runner is shut down, so the function can release
+// its resources and return before the Shutdown deadline:
+//
+//	pipe.AddCancelableStart(builder, eventsPtr, func(ctx context.Context, out chan<- Event) error {
+//		sub, err := broker.Subscribe(topic)
+//		if err != nil {
+//			return err
+//		}
+//		defer sub.Close()
+//		for {
+//			select {
+//			case ev := <-sub.Events():
+//				out <- ev
+//			case <-ctx.Done():
+//				return nil
+//			}
+//		}
+//	})
+//
+// The returned error makes the node restart if it is supervised with the RestartOnError or
+// RestartAlways policy. Otherwise, it is ignored and the node ends as if the function returned normally.
+func AddCancelableStart[IMPL NodesMap, OUT any](p *Builder[IMPL], field StartPtr[IMPL, OUT], fn CancelableStartFunc[OUT], opts ...Option) {
+	addStart(p, field, fn, true, opts...)
+}
+
+func addStart[IMPL NodesMap, OUT any](p *Builder[IMPL], field StartPtr[IMPL, OUT], fn CancelableStartFunc[OUT], cancelable bool, opts ...Option) {
+	opts = p.joinOpts(opts...)
+	startNode := newStart(fn, cancelable, opts...)
 	dstAddress := field(p.nodesMap)
-	p.startNodes[reflect.ValueOf(dstAddress).Pointer()] = nodeOrProvider[startable]{node: startNode}
+	p.startNodes[fieldAddress(dstAddress)] = func(nodesMap IMPL, _ string, fresh bool) (startable, error) {
+		if !fresh {
+			return startNode, nil
+		}
+		node := newStart(fn, cancelable, opts...)
+		*field(nodesMap) = node
+		return node, nil
+	}
 	*(dstAddress) = startNode
 }
 
@@ -167,9 +281,18 @@ func AddStart[IMPL NodesMap, OUT any](p *Builder[IMPL], field StartPtr[IMPL, OUT
 // The options related to the connection to that Middle node can be overridden. Otherwise
 // the global options passed to the pipeline Builder are used.
 func AddMiddle[IMPL NodesMap, IN, OUT any](p *Builder[IMPL], field MiddlePtr[IMPL, IN, OUT], fn MiddleFunc[IN, OUT], opts ...Option) {
+	opts = p.joinOpts(opts...)
+	middleNode := asMiddle(fn, opts...)
 	dstAddress := field(p.nodesMap)
-	p.middleNodes[reflect.ValueOf(dstAddress).Pointer()] = nodeOrProvider[struct{}]{}
-	*(dstAddress) = asMiddle(fn, p.joinOpts(opts...)...)
+	p.middleNodes[fieldAddress(dstAddress)] = func(nodesMap IMPL, _ string, fresh bool) (inspectable, error) {
+		if !fresh {
+			return middleNode, nil
+		}
+		node := asMiddle(fn, opts...)
+		*field(nodesMap) = node
+		return node, nil
+	}
+	*(dstAddress) = middleNode
 }
 
 // AddFinal creates a Final node given the provided FinalFunc. The node will
@@ -178,8 +301,30 @@ func AddMiddle[IMPL NodesMap, IN, OUT any](p *Builder[IMPL], field MiddlePtr[IMP
 // The options related to the connection to that Final node can be overridden. Otherwise
 // the global options passed to the pipeline Builder are used.
 func AddFinal[IMPL NodesMap, IN any](p *Builder[IMPL], field FinalPtr[IMPL, IN], fn FinalFunc[IN], opts ...Option) {
-	termNode := asFinal(fn, p.joinOpts(opts...)...)
+	opts = p.joinOpts(opts...)
+	termNode := asFinal(fn, opts...)
 	dstAddress := field(p.nodesMap)
-	p.finalNodes[reflect.ValueOf(dstAddress).Pointer()] = nodeOrProvider[doneable]{node: termNode}
+	p.finalNodes[fieldAddress(dstAddress)] = func(nodesMap IMPL, _ string, fresh bool) (doneable, error) {
+		if !fresh {
+			return termNode, nil
+		}
+		node := asFinal(fn, opts...)
+		*field(nodesMap) = node
+		return node, nil
+	}
 	*(dstAddress) = termNode
 }
+
+// middleOrBypass wraps a MiddleFunc into a middle node. If the function is nil, it returns
+// a bypass node, as long as the input and output types are the same.
+func middleOrBypass[IN, OUT any](fn MiddleFunc[IN, OUT], opts ...Option) (Middle[IN, OUT], error) {
+	if fn != nil {
+		return asMiddle(fn, opts...), nil
+	}
+	// middle providers where IN & OUT are the same type can be bypassed if they return
+	// a nil middle function
+	if bypasser, ok := any(&bypass[IN]{}).(Middle[IN, OUT]); ok {
+		return bypasser, nil
+	}
+	return nil, fmt.Errorf("middle provider returned a nil function. Expecting %T", fn)
+}
@@ -0,0 +1,174 @@
+package pipe
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Encoder writes the encoded representation of the recorded items. It is implemented
+// by *gob.Encoder and *json.Encoder, among others.
+type Encoder interface {
+	Encode(v any) error
+}
+
+// Decoder reads the encoded representation of the recorded items. It is implemented
+// by *gob.Decoder and *json.Decoder, among others.
+type Decoder interface {
+	Decode(v any) error
+}
+
+type recordOptions struct {
+	newEncoder func(io.Writer) Encoder
+	newDecoder func(io.Reader) Decoder
+}
+
+var defaultRecordOptions = recordOptions{
+	newEncoder: func(w io.Writer) Encoder { return gob.NewEncoder(w) },
+	newDecoder: func(r io.Reader) Decoder { return gob.NewDecoder(r) },
+}
+
+// RecordOption allows overriding the default properties of the NewRecorder and NewReplayer functions.
+type RecordOption func(options *recordOptions)
+
+// RecordCodec is a RecordOption that specifies how the recorded items are encoded and decoded.
+// The same codec must be used for recording and replaying the items. Default: encoding/gob.
+func RecordCodec(newEncoder func(io.Writer) Encoder, newDecoder func(io.Reader) Decoder) RecordOption {
+	return func(options *recordOptions) {
+		options.newEncoder = newEncoder
+		options.newDecoder = newDecoder
+	}
+}
+
+// RecordJSON is a RecordOption that encodes the recorded items as a stream of JSON values.
+func RecordJSON() RecordOption {
+	return RecordCodec(
+		func(w io.Writer) Encoder { return json.NewEncoder(w) },
+		func(r io.Reader) Decoder { return json.NewDecoder(r) },
+	)
+}
+
+// Recorder encodes the items that flow across the pipeline connections passed to Record.
+// It's safe to record many connections with the same Recorder.
+type Recorder[T any] struct {
+	mt  sync.Mutex
+	enc Encoder
+	err error
+}
+
+// NewRecorder creates a Recorder that writes the recorded items to the provided io.Writer.
+func NewRecorder[T any](w io.Writer, opts ...RecordOption) *Recorder[T] {
+	options := defaultRecordOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Recorder[T]{enc: options.newEncoder(w)}
+}
+
+// Err returns the first error that happened while encoding the recorded items. After an
+// error, the Recorder stops recording.
+func (r *Recorder[T]) Err() error {
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	return r.err
+}
+
+func (r *Recorder[T]) record(item T) {
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	if r.err != nil {
+		return
+	}
+	if err := r.enc.Encode(item); err != nil {
+		r.err = fmt.Errorf("recording item: %w", err)
+	}
+}
+
+// Record wraps a Receiver so all the items that a Sender forwards to it are also encoded by
+// the provided Recorder, without requiring any extra node between the Sender and the Receiver:
+//
+//	func (m *MyPipeline) Connect() {
+//		m.Parse.SendTo(pipe.Record(m.recorder, m.Enrich))
+//		m.Enrich.SendTo(m.Store)
+//	}
+//
+// The items are encoded by the Sender's output connection before forwarding them to the
+// Receiver. It can be combined with Route and DefaultRoute, so only the routed items are recorded.
+// The recorded items can be replayed later with a Replayer.
+func Record[T any](rec *Recorder[T], r Receiver[T]) Receiver[T] {
+	rt := asRoute(r)
+	if prev := rt.observe; prev != nil {
+		rt.observe = func(item T) {
+			prev(item)
+			rec.record(item)
+		}
+	} else {
+		rt.observe = rec.record
+	}
+	return rt
+}
+
+// Replayer forwards again the items that were encoded by a Recorder, to reproduce the
+// traffic of a pipeline connection offline.
+type Replayer[T any] struct {
+	dec Decoder
+
+	mt       sync.Mutex
+	replayed bool
+	err      error
+}
+
+// NewReplayer creates a Replayer that decodes the items from the provided io.Reader, as they
+// were written by a Recorder created with the same RecordOption instances.
+func NewReplayer[T any](in io.Reader, opts ...RecordOption) *Replayer[T] {
+	options := defaultRecordOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Replayer[T]{dec: options.newDecoder(in)}
+}
+
+// Replay is a StartFunc that forwards the decoded items in the same order as they were
+// recorded. It ends when all the items have been read, or when the data can't be decoded
+// (e.g. because the recording is truncated). In the latter case, the decoding error is
+// returned by the Err method.
+// The items can be replayed only once: further invocations (e.g. from a supervised Start node)
+// return immediately.
+//
+//	replayer := pipe.NewReplayer[Record](file)
+//	pipe.AddStart(builder, func(p *MyPipeline) *pipe.Start[Record] { return &p.Source }, replayer.Replay)
+//	...
+//	runner.Run()
+//	if err := replayer.Err(); err != nil { ...
+func (r *Replayer[T]) Replay(out chan<- T) {
+	r.mt.Lock()
+	replayed := r.replayed
+	r.replayed = true
+	r.mt.Unlock()
+	if replayed {
+		return
+	}
+	for {
+		var item T
+		if err := r.dec.Decode(&item); err != nil {
+			if !errors.Is(err, io.EOF) {
+				r.mt.Lock()
+				r.err = fmt.Errorf("replaying item: %w", err)
+				r.mt.Unlock()
+			}
+			return
+		}
+		out <- item
+	}
+}
+
+// Err returns the error that interrupted the replay of the items, or nil if all the
+// recorded items were replayed.
+func (r *Replayer[T]) Err() error {
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	return r.err
+}
@@ -0,0 +1,164 @@
+package pipe_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+type recordedEvent struct {
+	ID   int
+	Name string
+}
+
+type recordedPipe struct {
+	start  pipe.Start[recordedEvent]
+	evens  pipe.Final[recordedEvent]
+	others pipe.Final[recordedEvent]
+	// recorders are not nodes but they are stored here so they can be accessed from Connect
+	evensRecorder *pipe.Recorder[recordedEvent]
+	allRecorder   *pipe.Recorder[recordedEvent]
+}
+
+func (r *recordedPipe) Connect() {
+	r.start.SendTo(
+		pipe.Record(r.allRecorder,
+			pipe.Record(r.evensRecorder, pipe.Route(func(e recordedEvent) bool { return e.ID%2 == 0 }, r.evens))),
+		pipe.Record(r.allRecorder, pipe.DefaultRoute(r.others)),
+	)
+}
+
+func (r *recordedPipe) startPtr() *pipe.Start[recordedEvent]  { return &r.start }
+func (r *recordedPipe) evensPtr() *pipe.Final[recordedEvent]  { return &r.evens }
+func (r *recordedPipe) othersPtr() *pipe.Final[recordedEvent] { return &r.others }
+
+type replayPipe struct {
+	replay pipe.Start[recordedEvent]
+	store  pipe.Final[recordedEvent]
+}
+
+func (r *replayPipe) Connect()                              { r.replay.SendTo(r.store) }
+func (r *replayPipe) replayPtr() *pipe.Start[recordedEvent] { return &r.replay }
+func (r *replayPipe) storePtr() *pipe.Final[recordedEvent]  { return &r.store }
+
+func recordedEvents(n int) pipe.StartFunc[recordedEvent] {
+	return func(out chan<- recordedEvent) {
+		for i := 1; i <= n; i++ {
+			out <- recordedEvent{ID: i, Name: strings.Repeat("x", i)}
+		}
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []pipe.RecordOption
+	}{
+		{name: "gob"},
+		{name: "json", opts: []pipe.RecordOption{pipe.RecordJSON()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			evensBuf, allBuf := &bytes.Buffer{}, &bytes.Buffer{}
+			nodes := &recordedPipe{
+				evensRecorder: pipe.NewRecorder[recordedEvent](evensBuf, tc.opts...),
+				allRecorder:   pipe.NewRecorder[recordedEvent](allBuf, tc.opts...),
+			}
+			p := pipe.NewBuilder(nodes)
+			pipe.AddStart(p, (*recordedPipe).startPtr, recordedEvents(5))
+			var evens, others []recordedEvent
+			pipe.AddFinal(p, (*recordedPipe).evensPtr, collectInto(&evens))
+			pipe.AddFinal(p, (*recordedPipe).othersPtr, collectInto(&others))
+			r, err := p.Build()
+			require.NoError(t, err)
+			require.NoError(t, r.Run())
+			require.NoError(t, nodes.evensRecorder.Err())
+			require.NoError(t, nodes.allRecorder.Err())
+			assert.Equal(t, []recordedEvent{{2, "xx"}, {4, "xxxx"}}, evens)
+			assert.Equal(t, []recordedEvent{{1, "x"}, {3, "xxx"}, {5, "xxxxx"}}, others)
+
+			// replaying the recorded edges into another pipeline
+			assert.Equal(t, evens, replay(t, evensBuf, tc.opts...))
+			assert.ElementsMatch(t, append(evens, others...), replay(t, allBuf, tc.opts...))
+		})
+	}
+}
+
+func replay(t *testing.T, buf *bytes.Buffer, opts ...pipe.RecordOption) []recordedEvent {
+	t.Helper()
+	p := pipe.NewBuilder(&replayPipe{})
+	replayer := pipe.NewReplayer[recordedEvent](buf, opts...)
+	pipe.AddStart(p, (*replayPipe).replayPtr, replayer.Replay)
+	var stored []recordedEvent
+	pipe.AddFinal(p, (*replayPipe).storePtr, collectInto(&stored))
+	r, err := p.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+	require.NoError(t, replayer.Err())
+	return stored
+}
+
+func TestReplay_DecodeError(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		recording string
+	}{
+		{name: "wrong type", recording: `{"ID":1}{"ID":"wrong"}`},
+		{name: "truncated", recording: `{"ID":1}{"ID":2,"Na`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := pipe.NewBuilder(&replayPipe{})
+			replayer := pipe.NewReplayer[recordedEvent](strings.NewReader(tc.recording), pipe.RecordJSON())
+			// the replay is not restarted by the supervisor
+			pipe.AddStart(p, (*replayPipe).replayPtr, replayer.Replay,
+				pipe.Supervise(pipe.RestartAlways,
+					pipe.SuperviseMaxRestarts(1),
+					pipe.SuperviseBackoff(time.Millisecond, time.Millisecond)))
+			var stored []recordedEvent
+			pipe.AddFinal(p, (*replayPipe).storePtr, collectInto(&stored))
+			r, err := p.Build()
+			require.NoError(t, err)
+			require.NoError(t, r.Run())
+			assert.Equal(t, []recordedEvent{{ID: 1}}, stored)
+			require.Error(t, replayer.Err())
+			assert.Contains(t, replayer.Err().Error(), "replaying item")
+		})
+	}
+}
+
+func TestReplay_DecodeError_NotInPipeline(t *testing.T) {
+	replayer := pipe.NewReplayer[recordedEvent](strings.NewReader(`{"ID":1}{"ID":"wrong"}`), pipe.RecordJSON())
+	assert.Equal(t, []recordedEvent{{ID: 1}}, testers.CollectStart(t, replayer.Replay, 1, timeout))
+	assert.Eventually(t, func() bool { return replayer.Err() != nil }, timeout, time.Millisecond)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestRecorder_Error(t *testing.T) {
+	rec := pipe.NewRecorder[recordedEvent](failingWriter{})
+	nodes := &recordedPipe{evensRecorder: rec, allRecorder: rec}
+	p := pipe.NewBuilder(nodes)
+	pipe.AddStart(p, (*recordedPipe).startPtr, recordedEvents(3))
+	var evens, others []recordedEvent
+	pipe.AddFinal(p, (*recordedPipe).evensPtr, collectInto(&evens))
+	pipe.AddFinal(p, (*recordedPipe).othersPtr, collectInto(&others))
+	r, err := p.Build()
+	require.NoError(t, err)
+	require.NoError(t, r.Run())
+
+	// recording errors do not interrupt the pipeline
+	assert.Equal(t, []recordedEvent{{2, "xx"}}, evens)
+	assert.Equal(t, []recordedEvent{{1, "x"}, {3, "xxx"}}, others)
+	require.Error(t, rec.Err())
+	assert.Contains(t, rec.Err().Error(), "disk full")
+}
@@ -0,0 +1,162 @@
+package pipe
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+type nodeKind int
+
+const (
+	startKind nodeKind = iota
+	middleKind
+	finalKind
+)
+
+func (k nodeKind) String() string {
+	switch k {
+	case startKind:
+		return "Start"
+	case middleKind:
+		return "Middle"
+	default:
+		return "Final"
+	}
+}
+
+// Registry stores the node provider factories that can be instantiated from a configuration
+// document by the LoadYAML and LoadJSON methods. Each factory is registered under a
+// configuration type name, which is referred from the "type" property of the nodes
+// in the configuration document.
+type Registry struct {
+	types map[string]*registeredType
+}
+
+// registeredType hides the generic types of a registered node factory, so the nodes from
+// different types can be instantiated and connected according to a configuration document.
+type registeredType struct {
+	kind nodeKind
+	// in and out are the types of the input and output data, used to validate the
+	// connections before instantiating the nodes. They are nil for the Start and
+	// Final nodes, respectively.
+	in, out reflect.Type
+	// instantiate decodes the node configuration by means of the passed function
+	// and returns the node that is created from the registered factory
+	instantiate func(decode func(cfg any) error, opts []Option) (*loadedNode, error)
+}
+
+// errNilFunction is returned when a registered Start or Final provider returns a nil function.
+// Middle providers return nil to bypass the node.
+var errNilFunction = errors.New("the provider returned a nil function")
+
+// loadedNode is a type-erased node instantiated from a configuration document
+type loadedNode struct {
+	// receiver is nil for start nodes. Otherwise it's a Receiver[IN]
+	receiver any
+	// sendTo connects the node with a destination node. It is nil for final nodes
+	sendTo func(dst *loadedNode)
+	// startable and doneable are only set for start and final nodes, respectively
+	startable startable
+	doneable  doneable
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{types: map[string]*registeredType{}}
+}
+
+func (r *Registry) register(typeName string, rt *registeredType) error {
+	if _, ok := r.types[typeName]; ok {
+		return fmt.Errorf("configuration type %q is already registered. If you want to register"+
+			" another node for the same configuration type, consider defining a new named type"+
+			" with the same underlying type", typeName)
+	}
+	r.types[typeName] = rt
+	return nil
+}
+
+// RegisterStart registers, under the given configuration type name, a factory that creates
+// a StartProvider from a configuration value of type CFG. It returns an error if the
+// configuration type name was already registered.
+func RegisterStart[CFG, OUT any](r *Registry, typeName string, factory func(CFG) StartProvider[OUT]) error {
+	return r.register(typeName, &registeredType{
+		kind: startKind,
+		out:  reflect.TypeOf((*OUT)(nil)).Elem(),
+		instantiate: func(decode func(cfg any) error, opts []Option) (*loadedNode, error) {
+			var cfg CFG
+			if err := decode(&cfg); err != nil {
+				return nil, err
+			}
+			fn, err := factory(cfg)()
+			if err != nil {
+				return nil, err
+			}
+			if fn == nil {
+				return nil, errNilFunction
+			}
+			node := asStart(fn, opts...)
+			return &loadedNode{
+				sendTo:    func(dst *loadedNode) { node.SendTo(dst.receiver.(Receiver[OUT])) },
+				startable: node,
+			}, nil
+		},
+	})
+}
+
+// RegisterMiddle registers, under the given configuration type name, a factory that creates
+// a MiddleProvider from a configuration value of type CFG. It returns an error if the
+// configuration type name was already registered.
+func RegisterMiddle[CFG, IN, OUT any](r *Registry, typeName string, factory func(CFG) MiddleProvider[IN, OUT]) error {
+	return r.register(typeName, &registeredType{
+		kind: middleKind,
+		in:   reflect.TypeOf((*IN)(nil)).Elem(),
+		out:  reflect.TypeOf((*OUT)(nil)).Elem(),
+		instantiate: func(decode func(cfg any) error, opts []Option) (*loadedNode, error) {
+			var cfg CFG
+			if err := decode(&cfg); err != nil {
+				return nil, err
+			}
+			fn, err := factory(cfg)()
+			if err != nil {
+				return nil, err
+			}
+			node, err := middleOrBypass(fn, opts...)
+			if err != nil {
+				return nil, err
+			}
+			return &loadedNode{
+				receiver: node,
+				sendTo:   func(dst *loadedNode) { node.SendTo(dst.receiver.(Receiver[OUT])) },
+			}, nil
+		},
+	})
+}
+
+// RegisterFinal registers, under the given configuration type name, a factory that creates
+// a FinalProvider from a configuration value of type CFG. It returns an error if the
+// configuration type name was already registered.
+func RegisterFinal[CFG, IN any](r *Registry, typeName string, factory func(CFG) FinalProvider[IN]) error {
+	return r.register(typeName, &registeredType{
+		kind: finalKind,
+		in:   reflect.TypeOf((*IN)(nil)).Elem(),
+		instantiate: func(decode func(cfg any) error, opts []Option) (*loadedNode, error) {
+			var cfg CFG
+			if err := decode(&cfg); err != nil {
+				return nil, err
+			}
+			fn, err := factory(cfg)()
+			if err != nil {
+				return nil, err
+			}
+			if fn == nil {
+				return nil, errNilFunction
+			}
+			node := asFinal(fn, opts...)
+			return &loadedNode{
+				receiver: Final[IN](node),
+				doneable: node,
+			}, nil
+		},
+	})
+}
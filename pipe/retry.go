@@ -0,0 +1,298 @@
+package pipe
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by the functions wrapped with Retry when the circuit breaker
+// is open and the item has been shed without invoking the wrapped function.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type retryOptions struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	jitter         float64
+	// retry budget. If disabled, the number of retries is not limited
+	budget           bool
+	budgetRatio      float64
+	budgetMinRetries int
+	// circuit breaker. If breakerThreshold is 0, there is no circuit breaker
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	breakerPark      bool
+	clock            Clock
+}
+
+var defaultRetryOptions = retryOptions{
+	maxAttempts:    3,
+	initialBackoff: 100 * time.Millisecond,
+	maxBackoff:     10 * time.Second,
+	jitter:         0.2,
+	clock:          systemClock{},
+}
+
+// RetryOption allows overriding the default properties of the Retry, RetryFinal and RetryMiddle
+// wrappers.
+type RetryOption func(options *retryOptions)
+
+// RetryMaxAttempts is a RetryOption that specifies the maximum number of times that the wrapped
+// function is invoked for a given item, including the first attempt. Default: 3.
+func RetryMaxAttempts(attempts int) RetryOption {
+	return func(options *retryOptions) {
+		options.maxAttempts = attempts
+	}
+}
+
+// RetryBackoff is a RetryOption that specifies the time to wait before the first retry,
+// which is doubled on each subsequent retry until it reaches the maxBackoff value.
+// Default: 100ms initial backoff, 10s maximum backoff.
+func RetryBackoff(initial, maxBackoff time.Duration) RetryOption {
+	return func(options *retryOptions) {
+		options.initialBackoff = initial
+		options.maxBackoff = maxBackoff
+	}
+}
+
+// RetryJitter is a RetryOption that randomly modifies each backoff time up to the provided
+// fraction (e.g. 0.2 means that each backoff could be up to 20% shorter or longer), so many
+// nodes retrying at the same time do not synchronize their retries. Default: 0.2.
+func RetryJitter(fraction float64) RetryOption {
+	return func(options *retryOptions) {
+		options.jitter = fraction
+	}
+}
+
+// RetryBudget is a RetryOption that limits the total number of retries to the minRetries value
+// plus the given ratio of the items processed by the wrapped function, so a failing sink does not
+// multiply the load on it. For example, a ratio of 0.1 would only allow one retry for each 10
+// processed items. When the budget is exhausted, the failing items are not retried.
+// By default, there is no retry budget.
+func RetryBudget(ratio float64, minRetries int) RetryOption {
+	return func(options *retryOptions) {
+		options.budget = true
+		options.budgetRatio = ratio
+		options.budgetMinRetries = minRetries
+	}
+}
+
+// RetryCircuitBreaker is a RetryOption that opens a circuit breaker after the wrapped function
+// has failed the provided number of consecutive times. While the breaker is open, the items
+// are shed with the ErrCircuitOpen error without invoking the wrapped function. After the
+// cooldown time, the breaker is half-open: it lets pass a single trial item, while the rest of
+// the items are still shed. If the trial succeeds, the breaker is closed again. Otherwise, it
+// is open for another cooldown time. By default, there is no circuit breaker.
+func RetryCircuitBreaker(consecutiveFailures int, cooldown time.Duration) RetryOption {
+	return func(options *retryOptions) {
+		options.breakerThreshold = consecutiveFailures
+		options.breakerCooldown = cooldown
+	}
+}
+
+// RetryParkWhileOpen is a RetryOption that makes the circuit breaker to park the items until
+// the cooldown time has passed and the trial item has been processed, instead of shedding them.
+// It blocks the node and the nodes sending data to it, applying backpressure to the pipeline.
+func RetryParkWhileOpen() RetryOption {
+	return func(options *retryOptions) {
+		options.breakerPark = true
+	}
+}
+
+// RetryClock is a RetryOption that specifies the Clock used to wait for the backoff
+// and the circuit breaker cooldown times. Default: SystemClock.
+func RetryClock(clock Clock) RetryOption {
+	return func(options *retryOptions) {
+		options.clock = clock
+	}
+}
+
+// Retry wraps a function that processes a single item, returning a function that retries it
+// according to the provided RetryOption, with exponential backoff and jitter. The returned
+// function returns the error of the last attempt, if all of them failed.
+// The retry budget and the circuit breaker are shared by all the invocations of the returned
+// function.
+func Retry[T any](fn func(T) error, opts ...RetryOption) func(T) error {
+	r := newRetrier(opts...)
+	return func(item T) error {
+		return r.do(func() error { return fn(item) })
+	}
+}
+
+// RetryFinal returns a FinalFunc that invokes the provided function for each received item,
+// retrying it according to the provided RetryOption.
+// If onFailure is not nil, it is invoked with the items that couldn't be processed and the
+// error of their last attempt, so they can be forwarded to e.g. a dead-letter storage.
+// Each node running the returned function has its own retry budget and circuit breaker.
+func RetryFinal[T any](fn func(T) error, onFailure func(T, error), opts ...RetryOption) FinalFunc[T] {
+	return func(in <-chan T) {
+		r := newRetrier(opts...)
+		for i := range in {
+			if err := r.do(func() error { return fn(i) }); err != nil && onFailure != nil {
+				onFailure(i, err)
+			}
+		}
+	}
+}
+
+// RetryMiddle returns a MiddleFunc that invokes the provided function for each received item,
+// retrying it according to the provided RetryOption, and forwards its successful results.
+// If onFailure is not nil, it is invoked with the items that couldn't be processed and the
+// error of their last attempt, so they can be forwarded to e.g. a dead-letter storage.
+// Each node running the returned function has its own retry budget and circuit breaker.
+func RetryMiddle[IN, OUT any](fn func(IN) (OUT, error), onFailure func(IN, error), opts ...RetryOption) MiddleFunc[IN, OUT] {
+	return func(in <-chan IN, out chan<- OUT) {
+		r := newRetrier(opts...)
+		for i := range in {
+			var result OUT
+			if err := r.do(func() error {
+				var err error
+				result, err = fn(i)
+				return err
+			}); err != nil {
+				if onFailure != nil {
+					onFailure(i, err)
+				}
+				continue
+			}
+			out <- result
+		}
+	}
+}
+
+type retrier struct {
+	retryOptions
+	mt sync.Mutex
+	// retry budget accounting
+	processed int
+	retries   int
+	// circuit breaker state
+	consecutiveFailures int
+	open                bool
+	openUntil           time.Time
+	// trialDone is not nil while the trial item of a half-open breaker is being processed,
+	// and it's closed when the trial finishes
+	trialDone chan struct{}
+}
+
+func newRetrier(opts ...RetryOption) *retrier {
+	options := defaultRetryOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &retrier{retryOptions: options}
+}
+
+func (r *retrier) do(fn func() error) error {
+	r.mt.Lock()
+	r.processed++
+	r.mt.Unlock()
+	var err error
+	for attempt := 1; ; attempt++ {
+		trial, openErr := r.allow()
+		if openErr != nil {
+			if err != nil {
+				return fmt.Errorf("%w after %d attempts: %w", openErr, attempt-1, err)
+			}
+			return openErr
+		}
+		err = fn()
+		r.record(err, trial)
+		if err == nil {
+			return nil
+		}
+		if attempt >= r.maxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		if !r.spendRetry() {
+			return fmt.Errorf("retry budget exhausted after %d attempts: %w", attempt, err)
+		}
+		r.clock.Sleep(r.backoff(attempt))
+	}
+}
+
+// allow checks the status of the circuit breaker, parking the invoker if required.
+// It returns true if the invoker must process the trial item of a half-open breaker.
+func (r *retrier) allow() (bool, error) {
+	if r.breakerThreshold <= 0 {
+		return false, nil
+	}
+	for {
+		r.mt.Lock()
+		if !r.open {
+			r.mt.Unlock()
+			return false, nil
+		}
+		wait := r.openUntil.Sub(r.clock.Now())
+		if wait <= 0 && r.trialDone == nil {
+			r.trialDone = make(chan struct{})
+			r.mt.Unlock()
+			return true, nil
+		}
+		trialDone := r.trialDone
+		r.mt.Unlock()
+		if !r.breakerPark {
+			return false, ErrCircuitOpen
+		}
+		if wait > 0 {
+			r.clock.Sleep(wait)
+		} else {
+			<-trialDone
+		}
+	}
+}
+
+func (r *retrier) record(err error, trial bool) {
+	if r.breakerThreshold <= 0 {
+		return
+	}
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	if trial {
+		close(r.trialDone)
+		r.trialDone = nil
+	}
+	if err == nil {
+		r.consecutiveFailures = 0
+		if trial {
+			r.open = false
+		}
+		return
+	}
+	r.consecutiveFailures++
+	// a failed trial opens the breaker again
+	if trial || (!r.open && r.consecutiveFailures >= r.breakerThreshold) {
+		r.open = true
+		r.openUntil = r.clock.Now().Add(r.breakerCooldown)
+	}
+}
+
+func (r *retrier) spendRetry() bool {
+	if !r.budget {
+		return true
+	}
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	if float64(r.retries) >= float64(r.budgetMinRetries)+r.budgetRatio*float64(r.processed) {
+		return false
+	}
+	r.retries++
+	return true
+}
+
+func (r *retrier) backoff(attempt int) time.Duration {
+	backoff := r.initialBackoff
+	for i := 1; i < attempt && backoff < r.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.maxBackoff {
+		backoff = r.maxBackoff
+	}
+	if r.jitter > 0 {
+		backoff += time.Duration(float64(backoff) * r.jitter * (2*rand.Float64() - 1))
+	}
+	return backoff
+}
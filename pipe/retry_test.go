@@ -0,0 +1,270 @@
+package pipe_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+var errSink = errors.New("sink failed")
+
+// flakySink fails the first 'failures' invocations for each item
+type flakySink struct {
+	failures int
+	attempts map[int]int
+	stored   []int
+}
+
+func (f *flakySink) store(i int) error {
+	f.attempts[i]++
+	if f.attempts[i] <= f.failures {
+		return errSink
+	}
+	f.stored = append(f.stored, i)
+	return nil
+}
+
+func TestRetryFinal(t *testing.T) {
+	sink := flakySink{failures: 2, attempts: map[int]int{}}
+	type failure struct {
+		item int
+		err  error
+	}
+	var failed []failure
+	p := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStart(p, start, Counter(1, 3))
+	pipe.AddMiddle(p, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(p, final, pipe.RetryFinal(sink.store, func(i int, err error) {
+		failed = append(failed, failure{item: i, err: err})
+	}, pipe.RetryBackoff(time.Millisecond, 2*time.Millisecond)))
+
+	r, err := p.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.Equal(t, []int{1, 2, 3}, sink.stored)
+	assert.Equal(t, map[int]int{1: 3, 2: 3, 3: 3}, sink.attempts)
+	assert.Empty(t, failed)
+}
+
+func TestRetry_MaxAttempts(t *testing.T) {
+	sink := flakySink{failures: 5, attempts: map[int]int{}}
+	retried := pipe.Retry(sink.store,
+		pipe.RetryMaxAttempts(4), pipe.RetryBackoff(time.Millisecond, time.Millisecond))
+
+	err := retried(1)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errSink)
+	assert.Contains(t, err.Error(), "4 attempts")
+	assert.Equal(t, 4, sink.attempts[1])
+	assert.Empty(t, sink.stored)
+}
+
+func TestRetry_Budget(t *testing.T) {
+	sink := flakySink{failures: 1, attempts: map[int]int{}}
+	// only the first two failing items are retried
+	retried := pipe.Retry(sink.store,
+		pipe.RetryBudget(0, 2), pipe.RetryBackoff(time.Millisecond, time.Millisecond))
+
+	assert.NoError(t, retried(1))
+	assert.NoError(t, retried(2))
+	err := retried(3)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errSink)
+	assert.Contains(t, err.Error(), "budget exhausted")
+	assert.Equal(t, []int{1, 2}, sink.stored)
+}
+
+func TestRetryFinal_BudgetPerNode(t *testing.T) {
+	sink := flakySink{failures: 1, attempts: map[int]int{}}
+	// the same FinalFunc runs in two nodes (e.g. from two Runners), each with its own retry budget
+	final := pipe.RetryFinal(sink.store, nil,
+		pipe.RetryBudget(0, 1), pipe.RetryBackoff(time.Millisecond, time.Millisecond))
+	testers.RunFinal(t, final, 1)
+	testers.RunFinal(t, final, 2)
+	assert.Equal(t, []int{1, 2}, sink.stored)
+}
+
+func TestRetryFinal_Clock(t *testing.T) {
+	sink := flakySink{failures: 1, attempts: map[int]int{}}
+	clock := testers.NewFakeClock(time.Now())
+	p := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStart(p, start, Counter(1, 1))
+	pipe.AddMiddle(p, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(p, final, pipe.RetryFinal(sink.store, nil,
+		pipe.RetryBackoff(time.Minute, time.Minute), pipe.RetryJitter(0), pipe.RetryClock(clock)))
+	r, err := p.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	// the backoff is measured by the provided Clock
+	clock.BlockUntil(1)
+	assert.Empty(t, sink.stored)
+	clock.Advance(time.Minute)
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.Equal(t, []int{1}, sink.stored)
+}
+
+func TestRetryMiddle_CircuitBreaker(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+	failing := true
+	invocations := 0
+	in, out := make(chan int), make(chan string, 10)
+	var failedErrs []error
+	shed := make(chan struct{}, 10)
+	mid := pipe.RetryMiddle(func(i int) (string, error) {
+		invocations++
+		if failing {
+			return "", errSink
+		}
+		return strconv.Itoa(i), nil
+	}, func(_ int, err error) {
+		failedErrs = append(failedErrs, err)
+		shed <- struct{}{}
+	}, pipe.RetryMaxAttempts(1), pipe.RetryCircuitBreaker(2, time.Minute), pipe.RetryClock(clock))
+	go func() {
+		mid(in, out)
+		close(out)
+	}()
+
+	// two failures open the circuit
+	in <- 1
+	in <- 2
+	// the circuit is open, so the item is shed without invoking the function
+	in <- 3
+	for i := 0; i < 3; i++ {
+		testers.ReadChannel(t, shed, timeout)
+	}
+	assert.Equal(t, 2, invocations)
+	require.Len(t, failedErrs, 3)
+	assert.ErrorIs(t, failedErrs[0], errSink)
+	assert.ErrorIs(t, failedErrs[1], errSink)
+	assert.ErrorIs(t, failedErrs[2], pipe.ErrCircuitOpen)
+
+	// after the cooldown, the circuit lets a trial item pass and is closed again
+	failing = false
+	clock.Advance(time.Minute)
+	in <- 4
+	assert.Equal(t, "4", testers.ReadChannel(t, out, timeout))
+	in <- 5
+	assert.Equal(t, "5", testers.ReadChannel(t, out, timeout))
+	close(in)
+	assert.Equal(t, 4, invocations)
+}
+
+func TestRetry_ParkWhileOpen(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+	invocations := 0
+	retried := pipe.Retry(func(int) error {
+		invocations++
+		if invocations == 1 {
+			return errSink
+		}
+		return nil
+	}, pipe.RetryMaxAttempts(1), pipe.RetryCircuitBreaker(1, time.Minute),
+		pipe.RetryParkWhileOpen(), pipe.RetryClock(clock))
+
+	require.ErrorIs(t, retried(1), errSink)
+	// the breaker is open, so the invocation is parked until the cooldown is over
+	parked := make(chan error, 1)
+	go func() {
+		parked <- retried(2)
+	}()
+	clock.BlockUntil(1)
+	assert.Equal(t, 1, invocations)
+	clock.Advance(time.Minute)
+	require.NoError(t, testers.ReadChannel(t, parked, timeout))
+	assert.Equal(t, 2, invocations)
+}
+
+func TestRetry_HalfOpenSingleTrial(t *testing.T) {
+	for _, park := range []bool{false, true} {
+		t.Run(fmt.Sprintf("park=%v", park), func(t *testing.T) {
+			clock := testers.NewFakeClock(time.Now())
+			var invocations atomic.Int32
+			trialStarted, finishTrial := make(chan struct{}), make(chan struct{})
+			opts := []pipe.RetryOption{pipe.RetryMaxAttempts(1), pipe.RetryCircuitBreaker(1, time.Minute),
+				pipe.RetryClock(clock)}
+			if park {
+				opts = append(opts, pipe.RetryParkWhileOpen())
+			}
+			retried := pipe.Retry(func(i int) error {
+				switch invocations.Add(1) {
+				case 1:
+					return errSink
+				case 2:
+					// the trial item blocks until the rest of the items have been invoked
+					close(trialStarted)
+					<-finishTrial
+				}
+				return nil
+			}, opts...)
+			require.ErrorIs(t, retried(0), errSink)
+			clock.Advance(time.Minute)
+
+			trial := make(chan error, 1)
+			go func() { trial <- retried(1) }()
+			testers.ReadChannel(t, trialStarted, timeout)
+
+			// while the trial is in progress, the rest of the items are shed or parked
+			const concurrent = 10
+			results := make(chan error, concurrent)
+			for i := 0; i < concurrent; i++ {
+				go func(i int) { results <- retried(i) }(i)
+			}
+			if !park {
+				for i := 0; i < concurrent; i++ {
+					assert.ErrorIs(t, testers.ReadChannel(t, results, timeout), pipe.ErrCircuitOpen)
+				}
+			}
+			assert.EqualValues(t, 2, invocations.Load())
+
+			close(finishTrial)
+			require.NoError(t, testers.ReadChannel(t, trial, timeout))
+			if park {
+				// the parked items are processed after the trial succeeded
+				for i := 0; i < concurrent; i++ {
+					assert.NoError(t, testers.ReadChannel(t, results, timeout))
+				}
+				assert.EqualValues(t, 2+concurrent, invocations.Load())
+			}
+			// the breaker is closed again
+			require.NoError(t, retried(100))
+		})
+	}
+}
+
+func TestRetry_CircuitOpenDuringRetries(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+	retried := pipe.Retry(func(int) error { return errSink },
+		pipe.RetryMaxAttempts(5), pipe.RetryBackoff(time.Second, time.Second), pipe.RetryJitter(0),
+		pipe.RetryCircuitBreaker(2, time.Minute), pipe.RetryClock(clock))
+	result := make(chan error, 1)
+	go func() { result <- retried(1) }()
+	for i := 0; i < 2; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+	}
+	// the circuit is opened after the second attempt, and the error of the last attempt is kept
+	err := testers.ReadChannel(t, result, timeout)
+	assert.ErrorIs(t, err, pipe.ErrCircuitOpen)
+	assert.ErrorIs(t, err, errSink)
+}
@@ -0,0 +1,92 @@
+package pipe
+
+import "github.com/mariomac/pipes/pipe/internal/connect"
+
+// Route wraps a Receiver so a Sender only forwards to it the items that are accepted by
+// the provided predicate. The predicate is evaluated by the Sender's output connection,
+// without requiring any extra node between the Sender and the Receiver:
+//
+//	func (m *MyPipeline) Connect() {
+//		m.Ingest.SendTo(
+//			pipe.Route(isMetric, m.MetricsExporter),
+//			pipe.Route(isTrace, m.TracesExporter),
+//			pipe.DefaultRoute(m.Discarder),
+//			m.Logger, // a non-routed receiver gets all the items
+//		)
+//	}
+//
+// By default, an item is sent to all the routes that accept it. Use the
+// RouteToFirstMatch option in the Sender node to forward each item only to the
+// first matching route.
+// Nested routes forward only the items that are accepted by all their predicates.
+func Route[T any](accept func(T) bool, r Receiver[T]) Receiver[T] {
+	rt := asRoute(r)
+	if prev := rt.accept; prev != nil {
+		rt.accept = func(item T) bool { return prev(item) && accept(item) }
+	} else {
+		rt.accept = accept
+	}
+	return rt
+}
+
+// DefaultRoute wraps a Receiver so a Sender only forwards to it the items that
+// haven't been accepted by any other Route passed to the same Sender.
+func DefaultRoute[T any](r Receiver[T]) Receiver[T] {
+	rt := asRoute(r)
+	rt.isDefault = true
+	return rt
+}
+
+// Cloned wraps a Receiver so a Sender forwards to it a copy of each item, returned by the
+// provided clone function. It prevents data races when the item type is a pointer or contains
+// maps or slices, and multiple receivers modify the same items:
+//
+//	func (m *MyPipeline) Connect() {
+//		m.Ingest.SendTo(
+//			pipe.Cloned((*Event).DeepCopy, m.Enricher), // modifies the received events
+//			m.Exporter,
+//		)
+//	}
+//
+// Cloned can be combined with Route and DefaultRoute. Use the CloneOnFanOut option in the Sender
+// node to copy the items for all its destinations.
+func Cloned[T any](clone func(T) T, r Receiver[T]) Receiver[T] {
+	rt := asRoute(r)
+	rt.clone = clone
+	return rt
+}
+
+type route[T any] struct {
+	dst       Receiver[T]
+	accept    func(T) bool
+	isDefault bool
+	// observe is invoked with each item that is forwarded through the route
+	observe func(T)
+	// clone returns the copy of each item that is forwarded through the route
+	clone func(T) T
+}
+
+// asRoute returns a copy of the passed receiver if it's already a route, so the
+// route wrappers can be combined. Otherwise, it wraps the receiver into a new route.
+func asRoute[T any](r Receiver[T]) *route[T] {
+	if rt, ok := r.(*route[T]); ok {
+		cp := *rt
+		return &cp
+	}
+	return &route[T]{dst: r}
+}
+
+//nolint:unused
+func (r *route[T]) isStarted() bool {
+	return r.dst.isStarted()
+}
+
+//nolint:unused
+func (r *route[T]) start() {
+	r.dst.start()
+}
+
+//nolint:unused
+func (r *route[T]) joiners() []*connect.Joiner[T] {
+	return r.dst.joiners()
+}
@@ -0,0 +1,296 @@
+package pipe_test
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+type routedPipe struct {
+	start    pipe.Start[int]
+	evens    pipe.Final[int]
+	bigs     pipe.Final[int]
+	others   pipe.Final[int]
+	everyone pipe.Final[int]
+}
+
+func (r *routedPipe) Connect() {
+	r.start.SendTo(
+		pipe.Route(func(i int) bool { return i%2 == 0 }, r.evens),
+		pipe.Route(func(i int) bool { return i > 3 }, r.bigs),
+		pipe.DefaultRoute(r.others),
+		r.everyone,
+	)
+}
+
+func (r *routedPipe) startPtr() *pipe.Start[int]    { return &r.start }
+func (r *routedPipe) evensPtr() *pipe.Final[int]    { return &r.evens }
+func (r *routedPipe) bigsPtr() *pipe.Final[int]     { return &r.bigs }
+func (r *routedPipe) othersPtr() *pipe.Final[int]   { return &r.others }
+func (r *routedPipe) everyonePtr() *pipe.Final[int] { return &r.everyone }
+
+func TestRouter(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		opts        []pipe.Option
+		expectEvens []int
+		expectBigs  []int
+	}{
+		{name: "all matches", expectEvens: []int{2, 4, 6}, expectBigs: []int{4, 5, 6}},
+		{name: "first match", opts: []pipe.Option{pipe.RouteToFirstMatch()},
+			expectEvens: []int{2, 4, 6}, expectBigs: []int{5}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := pipe.NewBuilder(&routedPipe{})
+			pipe.AddStart(p, (*routedPipe).startPtr, Counter(1, 6), tc.opts...)
+			var evens, bigs, others, everyone []int
+			pipe.AddFinal(p, (*routedPipe).evensPtr, collectInto(&evens))
+			pipe.AddFinal(p, (*routedPipe).bigsPtr, collectInto(&bigs))
+			pipe.AddFinal(p, (*routedPipe).othersPtr, collectInto(&others))
+			pipe.AddFinal(p, (*routedPipe).everyonePtr, collectInto(&everyone))
+
+			r, err := p.Build()
+			require.NoError(t, err)
+			r.Start()
+			testers.ReadChannel(t, r.Done(), timeout)
+
+			assert.Equal(t, tc.expectEvens, evens)
+			assert.Equal(t, tc.expectBigs, bigs)
+			assert.Equal(t, []int{1, 3}, others)
+			assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, everyone)
+		})
+	}
+}
+
+func collectInto[T any](dst *[]T) pipe.FinalFunc[T] {
+	return func(in <-chan T) {
+		for i := range in {
+			*dst = append(*dst, i)
+		}
+	}
+}
+
+type taggedRecord struct {
+	id   int
+	tags map[string]string
+}
+
+func (r *taggedRecord) Clone() *taggedRecord {
+	cp := &taggedRecord{id: r.id, tags: make(map[string]string, len(r.tags))}
+	for k, v := range r.tags {
+		cp.tags[k] = v
+	}
+	return cp
+}
+
+// clonePipe sends the same records to a node that modifies them and to another node that
+// only reads them
+type clonePipe struct {
+	// if true, only the records that are sent to the tagger are copied
+	cloneTagger bool
+	start       pipe.Start[*taggedRecord]
+	tagger      pipe.Final[*taggedRecord]
+	reader      pipe.Final[*taggedRecord]
+}
+
+func (c *clonePipe) Connect() {
+	if c.cloneTagger {
+		c.start.SendTo(pipe.Cloned((*taggedRecord).Clone, c.tagger), c.reader)
+	} else {
+		c.start.SendTo(c.tagger, c.reader)
+	}
+}
+
+func (c *clonePipe) startPtr() *pipe.Start[*taggedRecord]  { return &c.start }
+func (c *clonePipe) taggerPtr() *pipe.Final[*taggedRecord] { return &c.tagger }
+func (c *clonePipe) readerPtr() *pipe.Final[*taggedRecord] { return &c.reader }
+
+// runClonePipe runs the pipeline and returns the records received by the tagger and reader nodes
+func runClonePipe(t *testing.T, nodes *clonePipe, opts ...pipe.Option) (tagged, read []*taggedRecord) {
+	t.Helper()
+	// the reader fails the test if the tagger modifies the records that it receives
+	mutations := testers.NoMutations(t, (*taggedRecord).Clone)
+	p := pipe.NewBuilder(nodes)
+	pipe.AddStart(p, (*clonePipe).startPtr, mutations.Sending(func(out chan<- *taggedRecord) {
+		for i := 0; i < 100; i++ {
+			out <- &taggedRecord{id: i, tags: map[string]string{"source": "start"}}
+		}
+	}), opts...)
+	pipe.AddFinal(p, (*clonePipe).taggerPtr, func(in <-chan *taggedRecord) {
+		for r := range in {
+			r.tags["tagger"] = "seen"
+			tagged = append(tagged, r)
+		}
+	})
+	pipe.AddFinal(p, (*clonePipe).readerPtr, mutations.Receiving(func(in <-chan *taggedRecord) {
+		for r := range in {
+			read = append(read, r)
+		}
+	}))
+	r, err := p.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+	return tagged, read
+}
+
+func assertCopies(t *testing.T, tagged, read []*taggedRecord) {
+	t.Helper()
+	require.Len(t, tagged, 100)
+	require.Len(t, read, 100)
+	for i := range tagged {
+		assert.NotSame(t, tagged[i], read[i])
+		assert.Equal(t, i, tagged[i].id)
+		assert.Equal(t, map[string]string{"source": "start", "tagger": "seen"}, tagged[i].tags)
+		assert.Equal(t, i, read[i].id)
+		assert.Equal(t, map[string]string{"source": "start"}, read[i].tags)
+	}
+}
+
+func TestCloned(t *testing.T) {
+	tagged, read := runClonePipe(t, &clonePipe{cloneTagger: true})
+	assertCopies(t, tagged, read)
+}
+
+func TestCloneOnFanOut(t *testing.T) {
+	tagged, read := runClonePipe(t, &clonePipe{}, pipe.CloneOnFanOut())
+	assertCopies(t, tagged, read)
+}
+
+func TestCloneOnFanOut_NotCloner(t *testing.T) {
+	p := pipe.NewBuilder(&miniPipe{})
+	pipe.AddStart(p, (*miniPipe).startPtr, Counter(1, 3), pipe.CloneOnFanOut())
+	pipe.AddMiddle(p, (*miniPipe).midPtr, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(p, (*miniPipe).endPtr, func(in <-chan int) {
+		for range in {
+		}
+	})
+	_, err := p.Build()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "node start")
+	assert.Contains(t, err.Error(), "CloneOnFanOut: int does not implement pipe.Cloner[int]")
+}
+
+// countedItem counts the copies that are created by its Clone method
+type countedItem struct {
+	id     int
+	copies *atomic.Int32
+}
+
+func (c *countedItem) Clone() *countedItem {
+	c.copies.Add(1)
+	return &countedItem{id: c.id, copies: c.copies}
+}
+
+type fanOutPipe struct {
+	start      pipe.Start[*countedItem]
+	f1, f2, f3 pipe.Final[*countedItem]
+}
+
+func (f *fanOutPipe) Connect() {
+	f.start.SendTo(f.f1, f.f2, f.f3)
+}
+
+func TestCloneOnFanOut_Copies(t *testing.T) {
+	const items = 100
+	var copies atomic.Int32
+	sent := make([]*countedItem, items)
+	p := pipe.NewBuilder(&fanOutPipe{})
+	pipe.AddStart(p, func(f *fanOutPipe) *pipe.Start[*countedItem] { return &f.start },
+		func(out chan<- *countedItem) {
+			for i := range sent {
+				sent[i] = &countedItem{id: i, copies: &copies}
+				out <- sent[i]
+			}
+		}, pipe.CloneOnFanOut())
+	received := make([][]*countedItem, 3)
+	for i, final := range []pipe.FinalPtr[*fanOutPipe, *countedItem]{
+		func(f *fanOutPipe) *pipe.Final[*countedItem] { return &f.f1 },
+		func(f *fanOutPipe) *pipe.Final[*countedItem] { return &f.f2 },
+		func(f *fanOutPipe) *pipe.Final[*countedItem] { return &f.f3 },
+	} {
+		pipe.AddFinal(p, final, collectInto(&received[i]))
+	}
+	r, err := p.Build()
+	require.NoError(t, err)
+	require.NoError(t, r.Run())
+
+	// one destination receives the original item, and the other two receive a copy
+	assert.EqualValues(t, 2*items, copies.Load())
+	for i, item := range sent {
+		originals := 0
+		for _, rcv := range received {
+			require.Len(t, rcv, items)
+			assert.Equal(t, i, rcv[i].id)
+			if rcv[i] == item {
+				originals++
+			}
+		}
+		assert.Equalf(t, 1, originals, "item %d", i)
+	}
+}
+
+type tappedClonePipe struct {
+	start pipe.Start[*countedItem]
+	final pipe.Final[*countedItem]
+}
+
+func (f *tappedClonePipe) Connect() {
+	f.start.SendTo(f.final)
+}
+
+func TestCloneOnFanOut_Tapped(t *testing.T) {
+	const items = 100
+	var copies atomic.Int32
+	sent := make([]*countedItem, items)
+	attached := make(chan struct{})
+	nodes := &tappedClonePipe{}
+	p := pipe.NewBuilder(nodes)
+	pipe.AddStart(p, func(f *tappedClonePipe) *pipe.Start[*countedItem] { return &f.start },
+		func(out chan<- *countedItem) {
+			<-attached
+			for i := range sent {
+				sent[i] = &countedItem{id: i, copies: &copies}
+				out <- sent[i]
+			}
+		}, pipe.CloneOnFanOut(), pipe.Tappable())
+	received := make([][]*countedItem, 3)
+	pipe.AddFinal(p, func(f *tappedClonePipe) *pipe.Final[*countedItem] { return &f.final },
+		collectInto(&received[0]))
+	r, err := p.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	// the attached Final nodes count as destinations of the node
+	detach1, err := pipe.Attach(nodes.start, collectInto(&received[1]))
+	require.NoError(t, err)
+	detach2, err := pipe.Attach(nodes.start, collectInto(&received[2]))
+	require.NoError(t, err)
+	close(attached)
+	testers.ReadChannel(t, r.Done(), timeout)
+	detach1()
+	detach2()
+
+	// the static destination receives the original item, and the attached nodes receive a copy
+	assert.EqualValues(t, 2*items, copies.Load())
+	for i, item := range sent {
+		for j, rcv := range received {
+			require.Len(t, rcv, items)
+			assert.Equal(t, i, rcv[i].id)
+			if j == 0 {
+				assert.Same(t, item, rcv[i])
+			} else {
+				assert.NotSame(t, item, rcv[i])
+			}
+		}
+	}
+}
@@ -1,5 +1,60 @@
 package pipe
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mariomac/pipes/pipe/internal/connect"
+)
+
+var (
+	// ErrAlreadyStarted is the panic value of Runner.Start, and the error returned by
+	// Runner.TryStart, when a Runner is started more than once.
+	ErrAlreadyStarted = errors.New("the Runner has already been started")
+	// ErrNotStarted is returned by the Runner's Wait and Shutdown methods if the Runner hasn't been started.
+	ErrNotStarted = errors.New("the Runner has not been started")
+	// ErrWaitTimeout is returned by the Runner's Wait method if the pipeline didn't finish on time.
+	ErrWaitTimeout = errors.New("timeout waiting for the pipeline to finish")
+)
+
+// ErrNotPausable is returned by the Runner's Pause method if any of its Start nodes was not created
+// with the Pausable option.
+var ErrNotPausable = errors.New("all the Start nodes must be created with the Pausable option")
+
+// RunnerState describes the lifecycle stage of a Runner.
+type RunnerState int
+
+const (
+	// NotStarted is the state of a Runner whose Start method hasn't been invoked yet.
+	NotStarted RunnerState = iota
+	// Running is the state of a started Runner whose nodes are processing data.
+	Running
+	// Paused is the state of a Runner whose Start nodes are blocked by the Pause method.
+	// Middle and Final nodes keep processing the in-flight data.
+	Paused
+	// Finished is the state of a Runner whose nodes have stopped processing data.
+	Finished
+)
+
+func (s RunnerState) String() string {
+	switch s {
+	case NotStarted:
+		return "NotStarted"
+	case Running:
+		return "Running"
+	case Paused:
+		return "Paused"
+	default:
+		return "Finished"
+	}
+}
+
 // Runner stores all the configured nodes of a pipeline once their nodes
 // are instantiated (as specified by AddStart, AddStartProvider,
 // AddMiddle, AddMiddleProvider, AddFinal, AddFinalProvider) and connected
@@ -12,25 +67,372 @@ type Runner struct {
 	// tha last change will prevail, without leaving lost startnodes around there
 	startNodes map[uintptr]startable
 	finalNodes map[uintptr]doneable
+	// nestedStarts are the Start nodes of the sub-pipelines that are nested into this pipeline
+	nestedStarts []startable
+	// nodes stores all the nodes of the pipeline by name, for inspection
+	nodes map[string]inspectable
+
+	mt      sync.Mutex
+	started bool
+	// stop cancels the context of the Start nodes on Shutdown
+	stop context.CancelFunc
+	// gate blocks the output of the start nodes while the Runner is paused
+	gate connect.Gate
+	// tracker registers the goroutines that are started by the nodes of the pipeline
+	tracker connect.Tracker
+	// watchdog is nil if the Watchdog option is not set
+	watchdog *watchdog
+	// clock measures the Wait timeout
+	clock Clock
+
+	doneOnce sync.Once
+	done     chan struct{}
+}
+
+func newRunner(options *creationOptions) (*Runner, error) {
+	r := &Runner{
+		startNodes: map[uintptr]startable{},
+		finalNodes: map[uintptr]doneable{},
+		nodes:      map[string]inspectable{},
+		clock:      options.clock,
+	}
+	if options.watchdog != nil {
+		var err error
+		if r.watchdog, err = newWatchdog(options.watchdog, options.clock); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
 }
 
-// Start the pipeline processing in a background.
+// watchdogName is the owner of the watchdog goroutine, as reported by LiveGoroutines
+const watchdogName = "Runner.Watchdog"
+
+// Start the pipeline processing in a background. It panics with ErrAlreadyStarted if the
+// Runner was already started. Use TryStart to get the error instead.
 func (b *Runner) Start() {
+	if err := b.TryStart(); err != nil {
+		panic(err)
+	}
+}
+
+// TryStart starts the pipeline processing in a background, as Start does, but it returns
+// ErrAlreadyStarted instead of panicking if the Runner was already started.
+func (b *Runner) TryStart() error {
+	b.mt.Lock()
+	if b.started {
+		b.mt.Unlock()
+		return ErrAlreadyStarted
+	}
+	b.started = true
+	ctx, stop := context.WithCancel(context.Background())
+	b.stop = stop
+	b.mt.Unlock()
+	for name, node := range b.nodes {
+		node.track(&b.tracker, name)
+	}
+	senders := b.countSenders()
+	if b.watchdog != nil {
+		// the watchdog can't observe the progress of the fused nodes
+		b.watchdog.watch(b.nodes)
+	} else {
+		b.fuseNodes(senders)
+	}
+	for _, s := range b.nestedStarts {
+		s.startGated(ctx, &b.gate)
+	}
 	for _, s := range b.startNodes {
-		s.Start()
+		s.startGated(ctx, &b.gate)
 	}
+	if b.watchdog != nil {
+		b.tracker.Spawner(watchdogName).Go(func() { b.watchdog.run(b) })
+	}
+	return nil
+}
+
+// Run starts the pipeline and blocks until all its nodes have stopped processing data.
+// It returns ErrAlreadyStarted if the Runner was already started.
+func (b *Runner) Run() error {
+	if err := b.TryStart(); err != nil {
+		return err
+	}
+	<-b.Done()
+	return nil
+}
+
+// Wait blocks until all the nodes of a started pipeline have stopped processing data, or
+// until the timeout expires. In the latter case, it returns an error wrapping
+// ErrWaitTimeout that reports the nodes that are still busy.
+// If the pipeline was built with the Watchdog option, it returns the first *StallError
+// as soon as a stall is detected.
+// The timeout is measured by the Clock provided by the WithClock option.
+func (b *Runner) Wait(timeout time.Duration) error {
+	b.mt.Lock()
+	started := b.started
+	b.mt.Unlock()
+	if !started {
+		return ErrNotStarted
+	}
+	// a nil channel blocks forever if there is no watchdog
+	var stalled <-chan struct{}
+	if b.watchdog != nil {
+		stalled = b.watchdog.detected
+	}
+	expired, stop := b.clock.NewTimer(timeout)
+	defer stop()
+	select {
+	case <-b.Done():
+		return nil
+	case <-stalled:
+		return b.watchdog.err
+	case <-expired:
+		return fmt.Errorf("%w after %s. Busy nodes:%s", ErrWaitTimeout, timeout, describeBusy(b.busyNodes()))
+	}
+}
+
+// Pause stops the Start nodes of the pipeline from forwarding data: they will block when sending
+// data to their output channels. The Middle and Final nodes keep running, so the
+// in-flight data is drained and their internal state is kept. Pause can be invoked
+// before the Runner starts, so it will start in Paused state.
+// All the Start nodes, including the Start nodes of the nested sub-pipelines, must be created with
+// the Pausable option. Otherwise, the Runner is not paused and ErrNotPausable is returned.
+func (b *Runner) Pause() error {
+	for _, s := range b.startNodes {
+		if !s.isPausable() {
+			return ErrNotPausable
+		}
+	}
+	for _, s := range b.nestedStarts {
+		if !s.isPausable() {
+			return ErrNotPausable
+		}
+	}
+	b.gate.Close()
+	return nil
+}
+
+// Resume makes the Start nodes to forward data again after the Runner was paused.
+func (b *Runner) Resume() {
+	b.gate.Open()
+}
+
+// State returns the current lifecycle stage of the Runner.
+func (b *Runner) State() RunnerState {
+	b.mt.Lock()
+	started := b.started
+	b.mt.Unlock()
+	switch {
+	case !started:
+		return NotStarted
+	case b.isDone():
+		return Finished
+	case b.gate.IsClosed():
+		return Paused
+	default:
+		return Running
+	}
+}
+
+// Shutdown stops the Start nodes of the pipeline and waits for the in-flight data to be
+// processed by the rest of the nodes, until all of them are done or the context is cancelled.
+// The context of the Start nodes added with AddCancelableStart or AddCancelableStartProvider is
+// cancelled, so they can release their resources and return, and the supervised Start nodes are not restarted.
+// The output channels of the Start nodes created with the Pausable option are also closed,
+// and any further data sent by them is discarded. The rest of the Start nodes can't be stopped,
+// so the pipeline is done only if they end by themselves.
+// If the context is cancelled before the pipeline is done, it returns a *ShutdownError
+// reporting the nodes that are still busy and the Start nodes that can't be stopped, which
+// wraps the context error.
+// It returns ErrNotStarted if the Runner hasn't been started.
+func (b *Runner) Shutdown(ctx context.Context) error {
+	b.mt.Lock()
+	stop := b.stop
+	b.mt.Unlock()
+	if stop == nil {
+		return ErrNotStarted
+	}
+	stop()
+	b.gate.Stop()
+	select {
+	case <-b.Done():
+		return nil
+	case <-ctx.Done():
+		return &ShutdownError{Busy: b.busyNodes(), Unstoppable: b.unstoppableNodes(), Err: ctx.Err()}
+	}
+}
+
+// BusyNode describes a node that hadn't finished processing its data when the
+// Shutdown context was cancelled.
+type BusyNode struct {
+	// Name of the node: the NodesMap field name, or the node id if the Runner was
+	// created from a configuration document
+	Name string
+	// BufferedItems is the number of items that are waiting in the node input channel
+	BufferedItems int
+}
+
+// ShutdownError is returned by the Runner's Shutdown method when the pipeline couldn't
+// process the in-flight data before the context was cancelled.
+type ShutdownError struct {
+	// Busy nodes, sorted by name
+	Busy []BusyNode
+	// Unstoppable are the names of the busy Start nodes that can't be stopped by Shutdown, as they
+	// were neither created with the Pausable option nor added with AddCancelableStart or
+	// AddCancelableStartProvider.
+	// Sorted by name.
+	Unstoppable []string
+	// Err of the cancelled context
+	Err error
+}
+
+func (e *ShutdownError) Error() string {
+	sb := strings.Builder{}
+	sb.WriteString("pipeline shutdown: ")
+	sb.WriteString(e.Err.Error())
+	sb.WriteString(". Busy nodes:")
+	sb.WriteString(describeBusy(e.Busy))
+	if len(e.Unstoppable) > 0 {
+		sb.WriteString(". Start nodes that can't be stopped: ")
+		sb.WriteString(strings.Join(e.Unstoppable, ", "))
+	}
+	return sb.String()
+}
+
+func describeBusy(busy []BusyNode) string {
+	sb := strings.Builder{}
+	for i, bn := range busy {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(fmt.Sprintf(" %s (%d buffered items)", bn.Name, bn.BufferedItems))
+	}
+	return sb.String()
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+func (b *Runner) busyNodes() []BusyNode {
+	var busy []BusyNode
+	for name, node := range b.nodes {
+		if node.isRunning() {
+			busy = append(busy, BusyNode{Name: name, BufferedItems: node.bufferedItems()})
+		}
+	}
+	sort.Slice(busy, func(i, j int) bool {
+		return busy[i].Name < busy[j].Name
+	})
+	return busy
+}
+
+func (b *Runner) unstoppableNodes() []string {
+	var unstoppable []string
+	for name, node := range b.nodes {
+		if s, ok := node.(startable); ok && !s.isStoppable() {
+			unstoppable = append(unstoppable, name)
+		}
+	}
+	sort.Strings(unstoppable)
+	return unstoppable
 }
 
 // Done returns a channel that is closed when all the nodes of the
 // pipeline have stopped processing data. This is, the functions running
 // the node logic have returned.
 func (b *Runner) Done() <-chan struct{} {
-	done := make(chan struct{})
-	go func() {
-		for _, s := range b.finalNodes {
-			<-s.Done()
-		}
-		close(done)
-	}()
-	return done
+	b.doneOnce.Do(func() {
+		b.done = make(chan struct{})
+		// this goroutine is not tracked, as it can't leak unless any of the tracked nodes leaks
+		go func() {
+			for _, s := range b.finalNodes {
+				<-s.Done()
+			}
+			close(b.done)
+		}()
+	})
+	return b.done
+}
+
+// checkNodes returns an error if the options of any node can't be applied to it
+func (b *Runner) checkNodes() error {
+	names := make([]string, 0, len(b.nodes))
+	for name := range b.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if c, ok := b.nodes[name].(interface{ checkOptions() error }); ok {
+			if err := c.checkOptions(); err != nil {
+				return fmt.Errorf("node %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// nest registers the nodes of a sub-pipeline Runner into this Runner, so they are started, paused,
+// tracked and inspected along with the rest of nodes. Their names must be already prefixed by the
+// name of their parent node.
+func (b *Runner) nest(sub *Runner) {
+	maps.Copy(b.nodes, sub.nodes)
+	for _, s := range sub.startNodes {
+		b.nestedStarts = append(b.nestedStarts, s)
+	}
+	b.nestedStarts = append(b.nestedStarts, sub.nestedStarts...)
+}
+
+// countSenders returns the number of nodes that send data to each input Joiner, and informs
+// the Joiners about it, so the ring buffers with multiple senders are replaced by channels
+func (b *Runner) countSenders() map[any]int {
+	senders := map[any]int{}
+	for _, node := range b.nodes {
+		for _, dst := range node.destinations() {
+			senders[dst]++
+		}
+	}
+	for _, node := range b.nodes {
+		if in, ok := node.input().(interface{ ExpectSenders(int) }); ok {
+			in.ExpectSenders(senders[in])
+		}
+	}
+	return senders
+}
+
+// fuseNodes fuses the chains of nodes that can be run in a single goroutine.
+// The senders map counts the number of nodes sending data to each Joiner.
+func (b *Runner) fuseNodes(senders map[any]int) {
+	for _, node := range b.nodes {
+		if f, ok := node.(fuser); ok {
+			f.fuse(senders)
+		}
+	}
+}
+
+// LiveGoroutines returns a description of each goroutine that has been started by the
+// pipeline nodes, their connections and the Runner, and hasn't exited yet. Each description
+// reports the name of the node that started it and the operation where the goroutine is blocked, e.g.:
+//
+//	Parse: chan send in main.Parse (/home/user/myproject/parse.go:31)
+//
+// After the Runner is Done, a non-empty list usually means that some goroutines are stuck
+// and won't ever exit.
+func (b *Runner) LiveGoroutines() []string {
+	live := b.tracker.Live()
+	descriptions := make([]string, 0, len(live))
+	for _, g := range live {
+		descriptions = append(descriptions, g.String())
+	}
+	return descriptions
+}
+
+func (b *Runner) isDone() bool {
+	for _, s := range b.finalNodes {
+		select {
+		case <-s.Done():
+		default:
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,400 @@
+package pipe_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+func TestRunner_PauseResume(t *testing.T) {
+	input := make(chan int)
+	received := make(chan int, 10)
+	b := pipe.NewBuilder(&smfPipe{}, pipe.Pausable())
+	pipe.AddStart(b, start, func(out chan<- int) {
+		for i := range input {
+			out <- i
+		}
+	})
+	// the middle node keeps an internal state that must survive the pause
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		sum := 0
+		for i := range in {
+			sum += i
+			out <- sum
+		}
+	})
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		for i := range in {
+			received <- i
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	assert.Equal(t, pipe.NotStarted, r.State())
+
+	r.Start()
+	assert.Equal(t, pipe.Running, r.State())
+	input <- 1
+	assert.Equal(t, 1, testers.ReadChannel(t, received, timeout))
+
+	require.NoError(t, r.Pause())
+	assert.Equal(t, pipe.Paused, r.State())
+	input <- 2
+	select {
+	case i := <-received:
+		assert.Failf(t, "runner is paused", "unexpected item %v", i)
+	case <-time.After(50 * time.Millisecond):
+		// ok!
+	}
+
+	r.Resume()
+	assert.Equal(t, pipe.Running, r.State())
+	assert.Equal(t, 3, testers.ReadChannel(t, received, timeout))
+	input <- 3
+	assert.Equal(t, 6, testers.ReadChannel(t, received, timeout))
+
+	close(input)
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.Equal(t, pipe.Finished, r.State())
+}
+
+func TestRunner_StartPaused(t *testing.T) {
+	var received []int
+	b := pipe.NewBuilder(&smfPipe{}, pipe.Pausable())
+	pipe.AddStart(b, start, Counter(1, 3))
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(b, final, collectInto(&received))
+	r, err := b.Build()
+	require.NoError(t, err)
+
+	require.NoError(t, r.Pause())
+	r.Start()
+	assert.Equal(t, pipe.Paused, r.State())
+	select {
+	case <-r.Done():
+		assert.Fail(t, "runner shouldn't finish while paused")
+	case <-time.After(50 * time.Millisecond):
+		// ok!
+	}
+	r.Resume()
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.Equal(t, []int{1, 2, 3}, received)
+}
+
+func TestRunner_NotPausable(t *testing.T) {
+	b := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStart(b, start, Counter(1, 3))
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		for range in {
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	assert.ErrorIs(t, r.Pause(), pipe.ErrNotPausable)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.Equal(t, pipe.Finished, r.State())
+}
+
+func TestRunner_Shutdown(t *testing.T) {
+	b := pipe.NewBuilder(&smfPipe{}, pipe.Pausable())
+	// start node that doesn't end by itself before the pipeline shuts down
+	quit := make(chan struct{})
+	defer close(quit)
+	pipe.AddStart(b, start, func(out chan<- int) {
+		for i := 0; ; i++ {
+			select {
+			case out <- i:
+			case <-quit:
+				return
+			}
+		}
+	})
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		for range in {
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	require.NoError(t, r.Shutdown(ctx))
+	assert.Equal(t, pipe.Finished, r.State())
+}
+
+func TestRunner_Shutdown_CancelableStart(t *testing.T) {
+	for _, pausable := range []bool{false, true} {
+		t.Run(fmt.Sprintf("pausable=%v", pausable), func(t *testing.T) {
+			var opts []pipe.Option
+			if pausable {
+				opts = append(opts, pipe.Pausable())
+			}
+			b := pipe.NewBuilder(&smfPipe{}, opts...)
+			returned := make(chan struct{})
+			pipe.AddCancelableStart(b, start, func(ctx context.Context, out chan<- int) error {
+				defer close(returned)
+				for i := 0; ; i++ {
+					select {
+					case out <- i:
+					case <-ctx.Done():
+						return nil
+					}
+				}
+			}, pipe.Supervise(pipe.RestartAlways))
+			pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+				for i := range in {
+					out <- i
+				}
+			})
+			pipe.AddFinal(b, final, func(in <-chan int) {
+				for range in {
+				}
+			})
+			r, err := b.Build()
+			require.NoError(t, err)
+			r.Start()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			require.NoError(t, r.Shutdown(ctx))
+			// the start function returned and it wasn't restarted by the supervisor
+			testers.ReadChannel(t, returned, timeout)
+			testers.VerifyNoLeaks(t, r)
+		})
+	}
+}
+
+func TestRunner_Shutdown_NotStarted(t *testing.T) {
+	b := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStart(b, start, Counter(1, 3))
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {})
+	pipe.AddFinal(b, final, func(in <-chan int) {})
+	r, err := b.Build()
+	require.NoError(t, err)
+	assert.ErrorIs(t, r.Shutdown(context.Background()), pipe.ErrNotStarted)
+}
+
+func TestRunner_Shutdown_Deadline(t *testing.T) {
+	sent, unblock := make(chan struct{}), make(chan struct{})
+	b := pipe.NewBuilder(&smfPipe{}, pipe.Pausable())
+	pipe.AddStart(b, start, func(out chan<- int) {
+		for i := 1; i <= 5; i++ {
+			out <- i
+		}
+		close(sent)
+	})
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	}, pipe.ChannelBufferLen(3))
+	var received []int
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		<-unblock
+		for i := range in {
+			received = append(received, i)
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, sent, timeout)
+
+	// the middle node holds 1 item and buffers other 3, while the final node is blocked
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = r.Shutdown(ctx)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var shutdownErr *pipe.ShutdownError
+	require.ErrorAs(t, err, &shutdownErr)
+	assert.Equal(t, []pipe.BusyNode{
+		{Name: "final", BufferedItems: 0},
+		{Name: "mid", BufferedItems: 3},
+	}, shutdownErr.Busy)
+	assert.Contains(t, err.Error(), "final (0 buffered items), mid (3 buffered items)")
+
+	// the pipeline finishes processing the in-flight data once the final node is unblocked
+	close(unblock)
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, received)
+}
+
+func TestRunner_Shutdown_Unstoppable(t *testing.T) {
+	b := pipe.NewBuilder(&smfPipe{})
+	// start node that is neither pausable nor cancelable, and doesn't end by itself
+	quit := make(chan struct{})
+	pipe.AddStart(b, start, func(out chan<- int) {
+		out <- 1
+		<-quit
+	})
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	var received []int
+	pipe.AddFinal(b, final, collectInto(&received))
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = r.Shutdown(ctx)
+	var shutdownErr *pipe.ShutdownError
+	require.ErrorAs(t, err, &shutdownErr)
+	assert.Equal(t, []string{"start"}, shutdownErr.Unstoppable)
+	assert.Contains(t, err.Error(), "Start nodes that can't be stopped: start")
+
+	close(quit)
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.Equal(t, []int{1}, received)
+}
+
+func TestRunner_Run(t *testing.T) {
+	var received []int
+	b := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStart(b, start, Counter(1, 3))
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(b, final, collectInto(&received))
+	r, err := b.Build()
+	require.NoError(t, err)
+
+	require.NoError(t, r.Run())
+	assert.Equal(t, []int{1, 2, 3}, received)
+	assert.Equal(t, pipe.Finished, r.State())
+
+	assert.ErrorIs(t, r.Run(), pipe.ErrAlreadyStarted)
+	assert.ErrorIs(t, r.TryStart(), pipe.ErrAlreadyStarted)
+	assert.PanicsWithValue(t, pipe.ErrAlreadyStarted, r.Start)
+}
+
+func TestRunner_Wait(t *testing.T) {
+	unblock := make(chan struct{})
+	clock := testers.NewFakeClock(time.Now())
+	b := pipe.NewBuilder(&smfPipe{}, pipe.WithClock(clock))
+	pipe.AddStart(b, start, Counter(1, 3))
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		<-unblock
+		for range in {
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	assert.ErrorIs(t, r.Wait(timeout), pipe.ErrNotStarted)
+
+	r.Start()
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- r.Wait(time.Minute) }()
+	// the timeout is measured by the Runner clock
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	err = testers.ReadChannel(t, waitErr, timeout)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, pipe.ErrWaitTimeout)
+	assert.Contains(t, err.Error(), "final (0 buffered items)")
+
+	close(unblock)
+	assert.NoError(t, r.Wait(timeout))
+	// Done always returns the same channel
+	assert.Equal(t, r.Done(), r.Done())
+}
+
+func TestRunner_LiveGoroutines(t *testing.T) {
+	quit := make(chan struct{})
+	defer close(quit)
+	b := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStart(b, start, func(out chan<- int) {
+		for i := 0; ; i++ {
+			select {
+			case out <- i:
+			case <-quit:
+				return
+			}
+		}
+	})
+	// the middle node returns without reading all its input, so the start node gets stuck
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		out <- <-in
+	})
+	var received []int
+	pipe.AddFinal(b, final, collectInto(&received))
+	r, err := b.Build()
+	require.NoError(t, err)
+	assert.Empty(t, r.LiveGoroutines())
+
+	require.NoError(t, r.Run())
+	assert.Equal(t, []int{0}, received)
+	// the start node might take some time to block in the select
+	var live []string
+	assert.Eventually(t, func() bool {
+		live = r.LiveGoroutines()
+		return len(live) == 1 && strings.HasPrefix(live[0], "start: select in ")
+	}, timeout, time.Millisecond)
+	require.Len(t, live, 1)
+	assert.Contains(t, live[0], "start: select in github.com/mariomac/pipes/pipe_test.TestRunner_LiveGoroutines")
+	assert.Contains(t, live[0], "runner_test.go")
+}
+
+func TestRunner_LiveGoroutines_Owners(t *testing.T) {
+	unblock := make(chan struct{})
+	b := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStart(b, start, Counter(1, 3))
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		<-unblock
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		for range in {
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	// the goroutines are reported by the name of their node, sorted
+	live := r.LiveGoroutines()
+	require.Len(t, live, 3)
+	for i, node := range []string{"final", "mid", "start"} {
+		assert.True(t, strings.HasPrefix(live[i], node), live[i])
+	}
+
+	close(unblock)
+	testers.VerifyNoLeaks(t, r)
+}
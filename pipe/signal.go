@@ -0,0 +1,53 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// RunUntilSignal starts the Runner and blocks until all its nodes are done or until one of the
+// provided OS signals is received. If no signals are provided, it listens for SIGINT and SIGTERM.
+//
+// On the first signal, it shuts down the Runner gracefully: the Start nodes are stopped and
+// the in-flight data is drained (see Runner.Shutdown).
+// On the second signal, it stops waiting for the pipeline and returns a *ShutdownError
+// reporting the nodes that were still busy.
+//
+// It returns nil if the pipeline finished, either by itself or after a graceful shutdown.
+// It returns ErrAlreadyStarted if the Runner was already started.
+func RunUntilSignal(r *Runner, signals ...os.Signal) error {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	received := make(chan os.Signal, 2)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+
+	if err := r.TryStart(); err != nil {
+		return err
+	}
+	var sig os.Signal
+	select {
+	case <-r.Done():
+		return nil
+	case sig = <-received:
+	}
+
+	// graceful drain until the next signal
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-received:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := r.Shutdown(ctx); err != nil {
+		return fmt.Errorf("forced stop on second signal after %v: %w", sig, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+//go:build !windows
+
+package pipe_test
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+// blockingPipe returns a Runner whose Start node sends numbers until it's stopped,
+// and whose Final node blocks after receiving the first item until the unblock channel is closed
+func blockingPipe(t *testing.T, unblock <-chan struct{}) (*pipe.Runner, <-chan struct{}) {
+	quit := make(chan struct{})
+	t.Cleanup(func() { close(quit) })
+	received := make(chan struct{})
+	b := pipe.NewBuilder(&smfPipe{}, pipe.Pausable())
+	pipe.AddStart(b, start, func(out chan<- int) {
+		for i := 0; ; i++ {
+			select {
+			case out <- i:
+			case <-quit:
+				return
+			}
+		}
+	})
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		<-in
+		close(received)
+		<-unblock
+		for range in {
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	return r, received
+}
+
+func runUntilSignal(r *pipe.Runner) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- pipe.RunUntilSignal(r, syscall.SIGUSR1)
+	}()
+	return result
+}
+
+func TestRunUntilSignal_GracefulDrain(t *testing.T) {
+	unblock := make(chan struct{})
+	r, received := blockingPipe(t, unblock)
+	result := runUntilSignal(r)
+	testers.ReadChannel(t, received, timeout)
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	select {
+	case err := <-result:
+		assert.Failf(t, "pipeline is still draining", "unexpected result: %v", err)
+	case <-time.After(50 * time.Millisecond):
+		// ok!
+	}
+	close(unblock)
+	assert.NoError(t, testers.ReadChannel(t, result, timeout))
+	assert.Equal(t, pipe.Finished, r.State())
+}
+
+func TestRunUntilSignal_ForcedStop(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+	r, received := blockingPipe(t, unblock)
+	result := runUntilSignal(r)
+	testers.ReadChannel(t, received, timeout)
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	err := testers.ReadChannel(t, result, timeout)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+	var shutdownErr *pipe.ShutdownError
+	require.ErrorAs(t, err, &shutdownErr)
+	assert.Contains(t, err.Error(), "forced stop on second signal after user defined signal 1")
+}
+
+func TestRunUntilSignal_Finished(t *testing.T) {
+	b := pipe.NewBuilder(&smfPipe{})
+	pipe.AddStart(b, start, Counter(1, 3))
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	var received []int
+	pipe.AddFinal(b, final, collectInto(&received))
+	r, err := b.Build()
+	require.NoError(t, err)
+
+	assert.NoError(t, testers.ReadChannel(t, runUntilSignal(r), timeout))
+	assert.Equal(t, []int{1, 2, 3}, received)
+}
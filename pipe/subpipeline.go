@@ -0,0 +1,156 @@
+package pipe
+
+import (
+	"fmt"
+	"maps"
+)
+
+// SubPipeline returns a MiddleProvider that wraps a whole pipeline into a single Middle node,
+// which can be added to a parent pipeline with AddMiddleProvider. The sub-pipeline receives
+// the input of the Middle node through its Start node whose field is returned by the input
+// StartPtr, and forwards to the output of the Middle node all the data received by its Final
+// node whose field is returned by the output FinalPtr. Both nodes are added by this function
+// to a copy of the sub-pipeline Builder, so they must not be added explicitly.
+//
+// Each time the provider is invoked, the sub-pipeline is built into a new NodesMap instance,
+// and the provider returns any error from the sub-pipeline Builder. The returned Middle
+// function starts the sub-pipeline Runner and returns when all its nodes are done. It can
+// be invoked only once.
+//
+// Prefer AddSubPipeline to add a sub-pipeline to a parent Builder: it nests the names of the
+// sub-pipeline nodes under the name of the parent node, and registers them in the parent
+// Runner, so they are paused, shut down and inspected as any other node of the parent pipeline.
+func SubPipeline[SUB NodesMap, IN, OUT any](sub *Builder[SUB], input StartPtr[SUB, IN], output FinalPtr[SUB, OUT]) MiddleProvider[IN, OUT] {
+	return func() (MiddleFunc[IN, OUT], error) {
+		sp, err := newSubPipeline(sub, sub.namePrefix, input, output)
+		if err != nil {
+			return nil, fmt.Errorf("building sub-pipeline: %w", err)
+		}
+		return func(in <-chan IN, out chan<- OUT) {
+			if err := sp.runner.TryStart(); err != nil {
+				panic("SubPipeline: the Middle function can't be invoked twice: " + err.Error())
+			}
+			sp.run(in, out)
+		}, nil
+	}
+}
+
+// AddSubPipeline wraps the sub-pipeline defined by the provided Builder into a Middle node,
+// and assigns it to the field of the parent NodesMap whose pointer is returned by the passed
+// MiddlePtr function. See SubPipeline for a description of the input and output arguments.
+// Example:
+//
+//	type Enricher struct {
+//		In     pipe.Start[string]
+//		Parse  pipe.Middle[string, Record]
+//		Enrich pipe.Middle[Record, Record]
+//		Out    pipe.Final[Record]
+//	}
+//	...
+//	pipe.AddSubPipeline(parentBuilder, enricherPtr, enricherBuilder, inPtr, outPtr)
+//
+// The sub-pipeline is built each time the parent Builder builds a Runner, and its build
+// errors are returned by the parent Builder. The sub-pipeline Builder is not modified, so
+// it can be added to multiple parent pipelines.
+//
+// The nodes of the sub-pipeline are part of the parent Runner: they are paused and shut down
+// with it, and they are reported by its Shutdown and Wait errors, its LiveGoroutines and
+// its Watchdog. Their names are nested under the name of the parent node field
+// (e.g. "enricher/Parse").
+func AddSubPipeline[IMPL, SUB NodesMap, IN, OUT any](
+	p *Builder[IMPL], field MiddlePtr[IMPL, IN, OUT],
+	sub *Builder[SUB], input StartPtr[SUB, IN], output FinalPtr[SUB, OUT],
+	opts ...Option,
+) {
+	opts = p.joinOpts(opts...)
+	p.middleNodes[fieldAddress(field(p.nodesMap))] = func(nodesMap IMPL, name string, _ bool) (inspectable, error) {
+		sp, err := newSubPipeline(sub, name+"/", input, output)
+		if err != nil {
+			return nil, fmt.Errorf("building sub-pipeline: %w", err)
+		}
+		node := &nestedMiddle[IN, OUT]{middle: asMiddle(sp.run, opts...), sub: sp.runner}
+		*field(nodesMap) = node
+		return node, nil
+	}
+}
+
+// nested is implemented by the nodes that run a sub-pipeline, whose nodes are registered in
+// the parent Runner
+type nested interface {
+	subRunner() *Runner
+}
+
+// nestedMiddle is a Middle node that runs a sub-pipeline
+type nestedMiddle[IN, OUT any] struct {
+	*middle[IN, OUT]
+	sub *Runner
+}
+
+func (n *nestedMiddle[IN, OUT]) subRunner() *Runner {
+	return n.sub
+}
+
+// subPipeline runs a sub-pipeline whose input and output nodes forward the data from the input
+// channel and to the output channel of a Middle node
+type subPipeline[IN, OUT any] struct {
+	runner *Runner
+	in     chan (<-chan IN)
+	out    chan (chan<- OUT)
+}
+
+func newSubPipeline[SUB NodesMap, IN, OUT any](
+	sub *Builder[SUB], namePrefix string, input StartPtr[SUB, IN], output FinalPtr[SUB, OUT],
+) (*subPipeline[IN, OUT], error) {
+	sp := &subPipeline[IN, OUT]{in: make(chan (<-chan IN), 1), out: make(chan (chan<- OUT), 1)}
+	b := sub.copy(namePrefix)
+	AddStartProvider(b, input, func() (StartFunc[IN], error) {
+		return func(out chan<- IN) {
+			for i := range <-sp.in {
+				out <- i
+			}
+		}, nil
+	})
+	AddFinalProvider(b, output, func() (FinalFunc[OUT], error) {
+		return func(in <-chan OUT) {
+			out := <-sp.out
+			for o := range in {
+				out <- o
+			}
+		}, nil
+	})
+	nodesMap, err := b.newNodesMap()
+	if err != nil {
+		return nil, err
+	}
+	if sp.runner, err = b.build(nodesMap, true); err != nil {
+		return nil, err
+	}
+	if in, ok := (*input(nodesMap)).(*start[IN]); ok {
+		in.relay = true
+	}
+	return sp, nil
+}
+
+// run passes the channels of the Middle node to the input and output nodes of the sub-pipeline,
+// and waits for all the sub-pipeline nodes to be done. The sub-pipeline nodes must have been
+// started by either the sub-pipeline Runner or its parent Runner.
+func (sp *subPipeline[IN, OUT]) run(in <-chan IN, out chan<- OUT) {
+	sp.in <- in
+	sp.out <- out
+	for _, final := range sp.runner.finalNodes {
+		<-final.Done()
+	}
+}
+
+// copy returns a copy of the Builder whose nodes are nested under the provided name prefix.
+// Adding nodes to the copy does not modify the original Builder.
+func (b *Builder[IMPL]) copy(namePrefix string) *Builder[IMPL] {
+	return &Builder[IMPL]{
+		nodesMap:    b.nodesMap,
+		opts:        b.opts,
+		namePrefix:  namePrefix,
+		startNodes:  maps.Clone(b.startNodes),
+		middleNodes: maps.Clone(b.middleNodes),
+		finalNodes:  maps.Clone(b.finalNodes),
+	}
+}
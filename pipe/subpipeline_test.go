@@ -0,0 +1,290 @@
+package pipe_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+type parentPipe struct {
+	numbers pipe.Start[int]
+	enrich  pipe.Middle[int, string]
+	store   pipe.Final[string]
+}
+
+func (p *parentPipe) Connect() {
+	p.numbers.SendTo(p.enrich)
+	p.enrich.SendTo(p.store)
+}
+
+func numbersPtr(p *parentPipe) *pipe.Start[int]         { return &p.numbers }
+func enrichPtr(p *parentPipe) *pipe.Middle[int, string] { return &p.enrich }
+func storePtr(p *parentPipe) *pipe.Final[string]        { return &p.store }
+
+type enricherPipe struct {
+	in       pipe.Start[int]
+	validate pipe.FailableMiddle[int, int]
+	format   pipe.Middle[int, string]
+	out      pipe.Final[string]
+	invalid  pipe.Final[pipe.DeadLetter[int]]
+}
+
+func (e *enricherPipe) Connect() {
+	e.in.SendTo(e.validate)
+	e.validate.SendTo(e.format)
+	e.validate.DeadLetters().SendTo(e.invalid)
+	e.format.SendTo(e.out)
+}
+
+func enricherInPtr(e *enricherPipe) *pipe.Start[int]               { return &e.in }
+func enricherOutPtr(e *enricherPipe) *pipe.Final[string]           { return &e.out }
+func validatePtr(e *enricherPipe) *pipe.FailableMiddle[int, int]   { return &e.validate }
+func formatPtr(e *enricherPipe) *pipe.Middle[int, string]          { return &e.format }
+func invalidPtr(e *enricherPipe) *pipe.Final[pipe.DeadLetter[int]] { return &e.invalid }
+
+func enricherBuilder(invalid *[]pipe.DeadLetter[int]) *pipe.Builder[*enricherPipe] {
+	sb := pipe.NewBuilder(&enricherPipe{})
+	pipe.AddFailableMiddle(sb, validatePtr, func(i int) (int, error) {
+		if i%2 == 0 {
+			return 0, errors.New("even number")
+		}
+		return i, nil
+	})
+	pipe.AddMiddle(sb, formatPtr, func(in <-chan int, out chan<- string) {
+		for i := range in {
+			out <- fmt.Sprint("odd:", i)
+		}
+	})
+	pipe.AddFinal(sb, invalidPtr, collectInto(invalid))
+	return sb
+}
+
+func TestAddSubPipeline(t *testing.T) {
+	var invalid []pipe.DeadLetter[int]
+	b := pipe.NewBuilder(&parentPipe{})
+	pipe.AddStart(b, numbersPtr, Counter(1, 5))
+	pipe.AddSubPipeline(b, enrichPtr, enricherBuilder(&invalid), enricherInPtr, enricherOutPtr)
+	var stored []string
+	pipe.AddFinal(b, storePtr, collectInto(&stored))
+
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.Equal(t, []string{"odd:1", "odd:3", "odd:5"}, stored)
+	require.Len(t, invalid, 2)
+	for i, dl := range invalid {
+		assert.Equal(t, (i+1)*2, dl.Item)
+		assert.Equal(t, "enrich/validate", dl.Node)
+	}
+}
+
+func TestSubPipeline_AddMiddleProvider(t *testing.T) {
+	var invalid []pipe.DeadLetter[int]
+	b := pipe.NewBuilder(&parentPipe{})
+	pipe.AddStart(b, numbersPtr, Counter(1, 3))
+	pipe.AddMiddleProvider(b, enrichPtr,
+		pipe.SubPipeline(enricherBuilder(&invalid), enricherInPtr, enricherOutPtr))
+	var stored []string
+	pipe.AddFinal(b, storePtr, collectInto(&stored))
+
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.Equal(t, []string{"odd:1", "odd:3"}, stored)
+	require.Len(t, invalid, 1)
+	assert.Equal(t, "validate", invalid[0].Node)
+}
+
+func TestAddSubPipeline_Error(t *testing.T) {
+	sb := pipe.NewBuilder(&enricherPipe{})
+	pipe.AddFailableMiddle(sb, validatePtr, func(i int) (int, error) { return i, nil })
+	pipe.AddMiddleProvider(sb, formatPtr, func() (pipe.MiddleFunc[int, string], error) {
+		return nil, MidError{}
+	})
+	pipe.AddFinal(sb, invalidPtr, func(in <-chan pipe.DeadLetter[int]) {})
+
+	b := pipe.NewBuilder(&parentPipe{})
+	pipe.AddStart(b, numbersPtr, Counter(1, 3))
+	pipe.AddSubPipeline(b, enrichPtr, sb, enricherInPtr, enricherOutPtr)
+	pipe.AddFinal(b, storePtr, func(in <-chan string) {})
+
+	_, err := b.Build()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, MidError{})
+	assert.Contains(t, err.Error(), "invoking Middle node provider enrich: ")
+	assert.Contains(t, err.Error(), "invoking Middle node provider enrich/format: ")
+}
+
+// forkedPipe sends the numbers to two sub-pipelines
+type forkedPipe struct {
+	numbers pipe.Start[int]
+	odd     pipe.Middle[int, string]
+	even    pipe.Middle[int, string]
+	store   pipe.Final[string]
+}
+
+func (p *forkedPipe) Connect() {
+	p.numbers.SendTo(p.odd, p.even)
+	p.odd.SendTo(p.store)
+	p.even.SendTo(p.store)
+}
+
+func TestAddSubPipeline_SharedBuilder(t *testing.T) {
+	var invalid []pipe.DeadLetter[int]
+	sb := enricherBuilder(&invalid)
+	// both sub-pipelines store their dead letters concurrently
+	var mt sync.Mutex
+	pipe.AddFinal(sb, invalidPtr, func(in <-chan pipe.DeadLetter[int]) {
+		for dl := range in {
+			mt.Lock()
+			invalid = append(invalid, dl)
+			mt.Unlock()
+		}
+	})
+	b := pipe.NewBuilder(&forkedPipe{})
+	pipe.AddStart(b, func(p *forkedPipe) *pipe.Start[int] { return &p.numbers }, Counter(1, 2))
+	// the same sub-pipeline Builder is added twice, and built again for each Runner
+	pipe.AddSubPipeline(b, func(p *forkedPipe) *pipe.Middle[int, string] { return &p.odd },
+		sb, enricherInPtr, enricherOutPtr)
+	pipe.AddSubPipeline(b, func(p *forkedPipe) *pipe.Middle[int, string] { return &p.even },
+		sb, enricherInPtr, enricherOutPtr)
+	var stored []string
+	pipe.AddFinal(b, func(p *forkedPipe) *pipe.Final[string] { return &p.store }, collectInto(&stored))
+
+	for i := 0; i < 2; i++ {
+		r, err := b.BuildWith(&forkedPipe{})
+		require.NoError(t, err)
+		require.NoError(t, r.Run())
+	}
+	assert.Equal(t, []string{"odd:1", "odd:1", "odd:1", "odd:1"}, stored)
+	var nodes []string
+	for _, dl := range invalid {
+		nodes = append(nodes, dl.Node)
+	}
+	sort.Strings(nodes)
+	assert.Equal(t, []string{"even/validate", "even/validate", "odd/validate", "odd/validate"}, nodes)
+}
+
+func TestAddSubPipeline_NodesInParentRunner(t *testing.T) {
+	unblock := make(chan struct{})
+	sb := pipe.NewBuilder(&enricherPipe{})
+	pipe.AddFailableMiddle(sb, validatePtr, func(i int) (int, error) { return i, nil })
+	pipe.AddMiddle(sb, formatPtr, func(in <-chan int, out chan<- string) {
+		<-unblock
+		for i := range in {
+			out <- fmt.Sprint(i)
+		}
+	})
+	pipe.AddFinal(sb, invalidPtr, func(in <-chan pipe.DeadLetter[int]) {})
+
+	b := pipe.NewBuilder(&parentPipe{})
+	pipe.AddStart(b, numbersPtr, Counter(1, 3))
+	pipe.AddSubPipeline(b, enrichPtr, sb, enricherInPtr, enricherOutPtr)
+	var stored []string
+	pipe.AddFinal(b, storePtr, collectInto(&stored))
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	// the sub-pipeline nodes are reported as busy by the parent Runner
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = r.Shutdown(ctx)
+	var shutdownErr *pipe.ShutdownError
+	require.ErrorAs(t, err, &shutdownErr)
+	var busy []string
+	for _, bn := range shutdownErr.Busy {
+		busy = append(busy, bn.Name)
+	}
+	assert.Equal(t, []string{"enrich", "enrich/format", "enrich/in", "enrich/out",
+		"enrich/validate", "numbers", "store"}, busy)
+	// as well as their goroutines
+	assert.Contains(t, strings.Join(r.LiveGoroutines(), "\n"), "enrich/format")
+
+	close(unblock)
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.Equal(t, []string{"1", "2", "3"}, stored)
+}
+
+// mergerPipe is a sub-pipeline with its own Start node, besides its input node
+type mergerPipe struct {
+	in, extra pipe.Start[int]
+	format    pipe.Middle[int, string]
+	out       pipe.Final[string]
+}
+
+func (m *mergerPipe) Connect() {
+	m.in.SendTo(m.format)
+	m.extra.SendTo(m.format)
+	m.format.SendTo(m.out)
+}
+
+func mergerBuilder(opts ...pipe.Option) *pipe.Builder[*mergerPipe] {
+	sb := pipe.NewBuilder(&mergerPipe{}, opts...)
+	pipe.AddStart(sb, func(m *mergerPipe) *pipe.Start[int] { return &m.extra }, Counter(100, 100))
+	pipe.AddMiddle(sb, func(m *mergerPipe) *pipe.Middle[int, string] { return &m.format },
+		func(in <-chan int, out chan<- string) {
+			for i := range in {
+				out <- fmt.Sprint(i)
+			}
+		})
+	return sb
+}
+
+func TestAddSubPipeline_Pause(t *testing.T) {
+	b := pipe.NewBuilder(&parentPipe{}, pipe.Pausable())
+	pipe.AddStart(b, numbersPtr, Counter(1, 1))
+	pipe.AddSubPipeline(b, enrichPtr, mergerBuilder(pipe.Pausable()),
+		func(m *mergerPipe) *pipe.Start[int] { return &m.in },
+		func(m *mergerPipe) *pipe.Final[string] { return &m.out })
+	var stored []string
+	pipe.AddFinal(b, storePtr, collectInto(&stored))
+	r, err := b.Build()
+	require.NoError(t, err)
+
+	// the Start nodes of the sub-pipeline are also paused
+	require.NoError(t, r.Pause())
+	r.Start()
+	assert.Equal(t, pipe.Paused, r.State())
+	select {
+	case <-r.Done():
+		assert.Fail(t, "runner shouldn't finish while paused")
+	case <-time.After(50 * time.Millisecond):
+		// ok!
+	}
+	assert.Empty(t, stored)
+	r.Resume()
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.ElementsMatch(t, []string{"1", "100"}, stored)
+}
+
+func TestAddSubPipeline_NotPausable(t *testing.T) {
+	b := pipe.NewBuilder(&parentPipe{}, pipe.Pausable())
+	pipe.AddStart(b, numbersPtr, Counter(1, 1))
+	pipe.AddSubPipeline(b, enrichPtr, mergerBuilder(),
+		func(m *mergerPipe) *pipe.Start[int] { return &m.in },
+		func(m *mergerPipe) *pipe.Final[string] { return &m.out })
+	pipe.AddFinal(b, storePtr, func(in <-chan string) {
+		for range in {
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	assert.ErrorIs(t, r.Pause(), pipe.ErrNotPausable)
+	require.NoError(t, r.Run())
+}
@@ -0,0 +1,160 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// RestartPolicy specifies when a supervised Start node is restarted after its StartFunc returns.
+type RestartPolicy int
+
+const (
+	// RestartNever does not restart the Start node. Its output is closed when its StartFunc returns.
+	// If the StartFunc panics, the panic is not recovered, as in the Start nodes that are not supervised.
+	RestartNever RestartPolicy = iota
+	// RestartOnError restarts the Start node only if its StartFunc failed. This is, it panicked or
+	// it is a CancelableStartFunc that returned an error.
+	RestartOnError
+	// RestartAlways restarts the Start node each time its StartFunc returns.
+	RestartAlways
+)
+
+type superviseOptions struct {
+	policy         RestartPolicy
+	maxRestarts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	onRestart      func(restart int, err error)
+}
+
+var defaultSuperviseOptions = superviseOptions{
+	initialBackoff: 100 * time.Millisecond,
+	maxBackoff:     10 * time.Second,
+}
+
+// SuperviseOption allows overriding the default properties of the Supervise option.
+type SuperviseOption func(options *superviseOptions)
+
+// SuperviseMaxRestarts is a SuperviseOption that limits the number of times that a Start node is
+// restarted. Default: 0, which means that the number of restarts is not limited.
+func SuperviseMaxRestarts(restarts int) SuperviseOption {
+	return func(options *superviseOptions) {
+		options.maxRestarts = restarts
+	}
+}
+
+// SuperviseBackoff is a SuperviseOption that specifies the time to wait before the first restart,
+// which is doubled on each subsequent restart until it reaches the max value.
+// Default: 100ms initial backoff, 10s maximum backoff.
+func SuperviseBackoff(initial, max time.Duration) SuperviseOption {
+	return func(options *superviseOptions) {
+		options.initialBackoff = initial
+		options.maxBackoff = max
+	}
+}
+
+// SuperviseOnRestart is a SuperviseOption that registers a function that is invoked before each
+// restart, with the number of the restart (starting at 1) and the error that made the
+// Start node to end, or nil if it ended without errors (for the RestartAlways policy).
+func SuperviseOnRestart(fn func(restart int, err error)) SuperviseOption {
+	return func(options *superviseOptions) {
+		options.onRestart = fn
+	}
+}
+
+// Supervise is an Option for Start nodes that restarts them according to the provided RestartPolicy
+// when their StartFunc returns, instead of closing their output and letting the rest of the pipeline
+// finish. The output channel of the node is kept open between restarts.
+// If the node was created with AddStartProvider or AddCancelableStartProvider, the provider is invoked
+// again to create the StartFunc for each restart. If the provider fails, the failure is handled as
+// another error of the node.
+// The panics of the StartFunc are recovered only if the node is going to be restarted. Otherwise (e.g.
+// because the maximum number of restarts has been reached), the panic is propagated.
+func Supervise(policy RestartPolicy, opts ...SuperviseOption) Option {
+	so := defaultSuperviseOptions
+	so.policy = policy
+	for _, opt := range opts {
+		opt(&so)
+	}
+	return func(options *creationOptions) {
+		options.supervisor = &so
+	}
+}
+
+// supervise runs the StartFunc of a Start node, restarting it according to the supervisor options.
+// The node is not restarted after the context is cancelled.
+func (sn *start[OUT]) supervise(ctx context.Context, out chan<- OUT) {
+	fn := sn.fun
+	for restart := 1; ; restart++ {
+		err := sn.invoke(ctx, fn, out, restart)
+		if ctx.Err() != nil || !sn.supervisor.mustRestart(restart, err) {
+			return
+		}
+		if sn.supervisor.onRestart != nil {
+			sn.supervisor.onRestart(restart, err)
+		}
+		backoff, stop := sn.clock.NewTimer(sn.supervisor.backoff(restart))
+		select {
+		case <-backoff:
+		case <-ctx.Done():
+			stop()
+			return
+		}
+		if sn.provider != nil {
+			newFn, perr := sn.provider()
+			switch {
+			case perr != nil:
+				fn = func(context.Context, chan<- OUT) error {
+					return fmt.Errorf("invoking provider: %w", perr)
+				}
+			case newFn != nil:
+				fn = newFn
+			}
+		}
+	}
+}
+
+// invoke runs the StartFunc, returning the error if it failed. Panics are only
+// recovered if the node is supervised and it is going to be restarted after them.
+func (sn *start[OUT]) invoke(ctx context.Context, fn CancelableStartFunc[OUT], out chan<- OUT, restart int) (err error) {
+	if sn.supervisor == nil {
+		return fn(ctx, out)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+			if ctx.Err() != nil || !sn.supervisor.mustRestart(restart, err) {
+				// the deferred function runs on top of the panicking frames, so they are still reported
+				panic(r)
+			}
+		}
+	}()
+	return fn(ctx, out)
+}
+
+func (so *superviseOptions) mustRestart(restart int, err error) bool {
+	if so == nil || (so.maxRestarts > 0 && restart > so.maxRestarts) {
+		return false
+	}
+	switch so.policy {
+	case RestartAlways:
+		return true
+	case RestartOnError:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+func (so *superviseOptions) backoff(restart int) time.Duration {
+	backoff := so.initialBackoff
+	for i := 1; i < restart && backoff < so.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > so.maxBackoff {
+		backoff = so.maxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,245 @@
+package pipe_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+func buildSupervised(t *testing.T, startFn pipe.StartFunc[int], opts ...pipe.Option) (*pipe.Runner, *[]int) {
+	return buildStart(t, func(b *pipe.Builder[*smfPipe]) {
+		pipe.AddStart(b, start, startFn, opts...)
+	})
+}
+
+func buildSupervisedCancelable(t *testing.T, startFn pipe.CancelableStartFunc[int], opts ...pipe.Option) (*pipe.Runner, *[]int) {
+	return buildStart(t, func(b *pipe.Builder[*smfPipe]) {
+		pipe.AddCancelableStart(b, start, startFn, opts...)
+	})
+}
+
+// buildStart builds a pipeline whose Start node is added by the passed function
+func buildStart(t *testing.T, addStart func(b *pipe.Builder[*smfPipe])) (*pipe.Runner, *[]int) {
+	var received []int
+	b := pipe.NewBuilder(&smfPipe{})
+	addStart(b)
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(b, final, collectInto(&received))
+	r, err := b.Build()
+	require.NoError(t, err)
+	return r, &received
+}
+
+func TestSupervise_RestartOnError(t *testing.T) {
+	invocations := 0
+	var restarts []int
+	r, received := buildSupervisedCancelable(t, func(_ context.Context, out chan<- int) error {
+		invocations++
+		out <- invocations
+		if invocations < 3 {
+			return errors.New("connection reset")
+		}
+		return nil
+	}, pipe.Supervise(pipe.RestartOnError,
+		pipe.SuperviseBackoff(time.Millisecond, 5*time.Millisecond),
+		pipe.SuperviseOnRestart(func(restart int, err error) {
+			assert.EqualError(t, err, "connection reset")
+			restarts = append(restarts, restart)
+		})))
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.Equal(t, []int{1, 2, 3}, *received)
+	assert.Equal(t, []int{1, 2}, restarts)
+}
+
+func TestSupervise_RecoverPanics(t *testing.T) {
+	invocations := 0
+	var panicErr error
+	r, received := buildSupervised(t, func(out chan<- int) {
+		invocations++
+		out <- invocations
+		if invocations == 1 {
+			panic("oops")
+		}
+	}, pipe.Supervise(pipe.RestartOnError, pipe.SuperviseBackoff(time.Millisecond, time.Millisecond),
+		pipe.SuperviseOnRestart(func(_ int, err error) {
+			panicErr = err
+		})))
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.Equal(t, []int{1, 2}, *received)
+	// the error keeps the stack trace of the panic
+	require.Error(t, panicErr)
+	assert.Contains(t, panicErr.Error(), "panic: oops")
+	assert.Contains(t, panicErr.Error(), "supervisor_test.go")
+}
+
+func TestSupervise_UnrecoveredPanics(t *testing.T) {
+	policies := map[string]pipe.Option{
+		"never": pipe.Supervise(pipe.RestartNever),
+		"max restarts reached": pipe.Supervise(pipe.RestartOnError, pipe.SuperviseMaxRestarts(1),
+			pipe.SuperviseBackoff(time.Millisecond, time.Millisecond)),
+	}
+	// the panicking pipeline runs in a child process, as the unrecovered panic crashes it
+	if policy, ok := os.LookupEnv("PIPES_TEST_PANIC_POLICY"); ok {
+		r, _ := buildSupervised(t, func(out chan<- int) {
+			out <- 1
+			panic("oops")
+		}, policies[policy])
+		r.Run()
+		return
+	}
+	for policy := range policies {
+		t.Run(policy, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestSupervise_UnrecoveredPanics$")
+			cmd.Env = append(os.Environ(), "PIPES_TEST_PANIC_POLICY="+policy)
+			output, err := cmd.CombinedOutput()
+			var exitErr *exec.ExitError
+			require.ErrorAs(t, err, &exitErr)
+			assert.Contains(t, string(output), "panic: oops")
+			assert.Contains(t, string(output), "supervisor_test.go")
+		})
+	}
+}
+
+func TestSupervise_RestartAlways_MaxRestarts(t *testing.T) {
+	invocations := 0
+	r, received := buildSupervised(t, func(out chan<- int) {
+		invocations++
+		out <- invocations
+	}, pipe.Supervise(pipe.RestartAlways,
+		pipe.SuperviseMaxRestarts(3),
+		pipe.SuperviseBackoff(time.Millisecond, time.Millisecond)))
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.Equal(t, []int{1, 2, 3, 4}, *received)
+}
+
+func TestSupervise_NotSupervised(t *testing.T) {
+	invocations := 0
+	r, received := buildSupervisedCancelable(t, func(_ context.Context, out chan<- int) error {
+		invocations++
+		out <- invocations
+		return errors.New("failure")
+	})
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	assert.Equal(t, []int{1}, *received)
+}
+
+func TestSupervise_ReinvokeProvider(t *testing.T) {
+	providerInvocations := 0
+	var received []int
+	b := pipe.NewBuilder(&smfPipe{})
+	pipe.AddCancelableStartProvider(b, start, func() (pipe.CancelableStartFunc[int], error) {
+		providerInvocations++
+		if providerInvocations == 2 {
+			return nil, errors.New("can't connect")
+		}
+		// each function instance sends the provider invocation when it was created
+		instance := providerInvocations
+		return func(_ context.Context, out chan<- int) error {
+			out <- instance
+			if instance < 3 {
+				return errors.New("disconnected")
+			}
+			return nil
+		}, nil
+	}, pipe.Supervise(pipe.RestartOnError, pipe.SuperviseBackoff(time.Millisecond, time.Millisecond)))
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(b, final, collectInto(&received))
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	// the second provider invocation failed, so the node was restarted again
+	assert.Equal(t, []int{1, 3}, received)
+	assert.Equal(t, 3, providerInvocations)
+}
+
+func TestSupervise_Backoff(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+	r, received := buildSupervised(t, func(out chan<- int) {
+		out <- 1
+	}, pipe.Supervise(pipe.RestartAlways,
+		pipe.SuperviseMaxRestarts(3),
+		pipe.SuperviseBackoff(time.Second, 3*time.Second)),
+		pipe.WithClock(clock))
+	r.Start()
+
+	for _, backoff := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		clock.BlockUntil(1)
+		// the node is not restarted until the backoff time has passed
+		clock.Advance(backoff - time.Millisecond)
+		assert.Equal(t, 1, clock.Timers())
+		clock.Advance(time.Millisecond)
+	}
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.Equal(t, []int{1, 1, 1, 1}, *received)
+}
+
+type fanInPipe struct {
+	supervised, plain pipe.Start[int]
+	final             pipe.Final[int]
+}
+
+func (f *fanInPipe) Connect() {
+	f.supervised.SendTo(f.final)
+	f.plain.SendTo(f.final)
+}
+
+func TestSupervise_FanIn(t *testing.T) {
+	// the supervised node keeps its own restart state when it shares its destination with other Start nodes
+	for i := 0; i < 20; i++ {
+		invocations := 0
+		var restarts []int
+		var received []int
+		b := pipe.NewBuilder(&fanInPipe{})
+		pipe.AddCancelableStart(b, func(f *fanInPipe) *pipe.Start[int] { return &f.supervised },
+			func(_ context.Context, out chan<- int) error {
+				invocations++
+				out <- invocations
+				if invocations < 4 {
+					return errors.New("connection reset")
+				}
+				return nil
+			}, pipe.Supervise(pipe.RestartOnError,
+				pipe.SuperviseBackoff(time.Millisecond, time.Millisecond),
+				pipe.SuperviseOnRestart(func(restart int, _ error) {
+					restarts = append(restarts, restart)
+				})))
+		pipe.AddStart(b, func(f *fanInPipe) *pipe.Start[int] { return &f.plain }, func(out chan<- int) {
+			out <- 100
+		})
+		pipe.AddFinal(b, func(f *fanInPipe) *pipe.Final[int] { return &f.final }, collectInto(&received))
+		r, err := b.Build()
+		require.NoError(t, err)
+		r.Start()
+		testers.ReadChannel(t, r.Done(), timeout)
+
+		assert.Equal(t, []int{1, 2, 3}, restarts)
+		assert.ElementsMatch(t, []int{1, 2, 3, 4, 100}, received)
+	}
+}
@@ -0,0 +1,59 @@
+package pipe
+
+import (
+	"errors"
+
+	"github.com/mariomac/pipes/pipe/internal/connect"
+)
+
+// ErrNotTappable is returned by Attach when the Sender node was not created with the Tappable option.
+var ErrNotTappable = errors.New("the sender node is not tappable. Create it with the Tappable option")
+
+// tapsSuffix is appended to the name of a Tappable node to name the Final nodes that are attached to it
+const tapsSuffix = ".Taps"
+
+// tappable is implemented by the Sender nodes that accept attaching Final nodes at runtime
+type tappable[T any] interface {
+	tapper() *connect.Taps[T]
+}
+
+// Attach connects a new Final node, running the provided FinalFunc, to a running Sender node, which must
+// have been created with the Tappable option. The Final node receives all the items that the Sender forwards
+// after the invocation to Attach. Example:
+//
+//	detach, err := pipe.Attach(nodes.Parser, func(in <-chan Record) {
+//		for r := range in {
+//			log.Println("parsed record:", r)
+//		}
+//	})
+//	...
+//	detach()
+//
+// As any other receiver, a slow attached Final node applies backpressure to the Sender node.
+// The returned detach function disconnects the Final node from the Sender, closing its input channel,
+// and waits for the FinalFunc to return. If the Sender was blocked sending an item to the Final node,
+// the item is discarded.
+// The Final node input channel is also closed when the Sender node finishes. The attached nodes are
+// not taken into account by the Runner's Done method, but their goroutines are reported by the
+// Runner's LiveGoroutines method, under the name of the Sender node followed by ".Taps".
+func Attach[T any](sender Sender[T], fn FinalFunc[T], opts ...Option) (detach func(), err error) {
+	tp, ok := sender.(tappable[T])
+	if !ok || tp.tapper() == nil {
+		return nil, ErrNotTappable
+	}
+	taps := tp.tapper()
+	tap := asFinal(fn, opts...)
+	if tap == nil {
+		return nil, errors.New("can't attach a nil FinalFunc")
+	}
+	tap.spawn = taps.Spawner()
+	tap.inputs.Track(tap.spawn)
+	if err := taps.Attach(&tap.inputs); err != nil {
+		return nil, err
+	}
+	tap.start()
+	return func() {
+		taps.Detach(&tap.inputs)
+		<-tap.Done()
+	}, nil
+}
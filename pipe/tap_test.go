@@ -0,0 +1,135 @@
+package pipe_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+func TestAttach(t *testing.T) {
+	input := make(chan int)
+	stored := make(chan int, 10)
+	nodes := &smfPipe{}
+	b := pipe.NewBuilder(nodes)
+	pipe.AddStart(b, start, func(out chan<- int) {
+		for i := range input {
+			out <- i
+		}
+	})
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i * 10
+		}
+	}, pipe.Tappable())
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		for i := range in {
+			stored <- i
+		}
+		close(stored)
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	input <- 1
+	assert.Equal(t, 10, testers.ReadChannel(t, stored, timeout))
+	tapped := make(chan int)
+	detach, err := pipe.Attach(nodes.mid, func(in <-chan int) {
+		for i := range in {
+			tapped <- i
+		}
+		close(tapped)
+	})
+	require.NoError(t, err)
+	input <- 2
+	assert.Equal(t, 20, testers.ReadChannel(t, tapped, timeout))
+	input <- 3
+	assert.Equal(t, 30, testers.ReadChannel(t, tapped, timeout))
+
+	detach()
+	_, ok := <-tapped
+	assert.False(t, ok)
+
+	input <- 4
+	close(input)
+	testers.ReadChannel(t, r.Done(), timeout)
+	var remaining []int
+	for i := range stored {
+		remaining = append(remaining, i)
+	}
+	assert.Equal(t, []int{20, 30, 40}, remaining)
+}
+
+func TestAttach_SenderFinished(t *testing.T) {
+	nodes := &smfPipe{}
+	b := pipe.NewBuilder(nodes)
+	pipe.AddStart(b, start, Counter(1, 3), pipe.Tappable())
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		for range in {
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.ReadChannel(t, r.Done(), timeout)
+
+	_, err = pipe.Attach(nodes.start, func(in <-chan int) {})
+	require.Error(t, err)
+}
+
+func TestAttach_NotTappable(t *testing.T) {
+	nodes := &smfPipe{}
+	b := pipe.NewBuilder(nodes)
+	pipe.AddStart(b, start, Counter(1, 3))
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {})
+	pipe.AddFinal(b, final, func(in <-chan int) {})
+
+	_, err := pipe.Attach(nodes.start, func(in <-chan int) {})
+	assert.ErrorIs(t, err, pipe.ErrNotTappable)
+}
+
+func TestAttach_Tracked(t *testing.T) {
+	input := make(chan int)
+	nodes := &smfPipe{}
+	b := pipe.NewBuilder(nodes)
+	pipe.AddStart(b, start, func(out chan<- int) {
+		for i := range input {
+			out <- i
+		}
+	})
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	}, pipe.Tappable())
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		for range in {
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	unblock := make(chan struct{})
+	_, err = pipe.Attach(nodes.mid, func(in <-chan int) {
+		<-unblock
+		for range in {
+		}
+	})
+	require.NoError(t, err)
+	// the attached node is tracked under the name of its Sender
+	assert.Contains(t, r.LiveGoroutines(), "mid.Taps")
+
+	close(unblock)
+	close(input)
+	testers.VerifyNoLeaks(t, r)
+}
@@ -0,0 +1,382 @@
+package pipe
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mariomac/pipes/pipe/internal/connect"
+)
+
+// ErrStalled is wrapped by the errors that report that some nodes of the pipeline
+// have been blocked for longer than the Watchdog threshold.
+var ErrStalled = errors.New("pipeline stalled")
+
+type watchdogOptions struct {
+	threshold time.Duration
+	interval  time.Duration
+	logger    *slog.Logger
+	onStall   func(*StallError)
+}
+
+// WatchdogOption allows overriding the default properties of the Watchdog option.
+type WatchdogOption func(options *watchdogOptions)
+
+// WatchdogInterval is a WatchdogOption that specifies how often the nodes are inspected.
+// Default: a quarter of the Watchdog threshold.
+func WatchdogInterval(interval time.Duration) WatchdogOption {
+	return func(options *watchdogOptions) {
+		options.interval = interval
+	}
+}
+
+// WatchdogLogger is a WatchdogOption that logs a warning with the diagnostic of each stall.
+func WatchdogLogger(logger *slog.Logger) WatchdogOption {
+	return func(options *watchdogOptions) {
+		options.logger = logger
+	}
+}
+
+// WatchdogOnStall is a WatchdogOption that registers a function that is invoked with the
+// diagnostic of each stall.
+func WatchdogOnStall(fn func(*StallError)) WatchdogOption {
+	return func(options *watchdogOptions) {
+		options.onStall = fn
+	}
+}
+
+// Watchdog is an Option that periodically inspects the progress and the input channels of the
+// pipeline nodes. When a node has not read any item from its input for longer than the provided
+// threshold, while an item was waiting to be read, it reports a StallError with the chain of
+// blocked nodes, from the blocked sender to the node that is not reading its input (e.g. a Final
+// node that stopped reading or is blocked receiving from another channel, or a node that returned
+// without draining its input). A very slow node that takes longer than the threshold to process a
+// single item is also reported.
+// The progress of each node is counted by an extra goroutine in its input, which holds one item at
+// a time. Map and Filter nodes are not fused when the Watchdog is enabled. Start nodes are considered
+// blocked when their destinations are not reading their input channels and the channels are full.
+// The stalls are reported through the WatchdogLogger and WatchdogOnStall options, and the Runner's
+// Wait method returns the first detected StallError. The nodes are not inspected while the Runner
+// is paused.
+// It only takes effect when it is passed as a default option to the Builder or the Registry, and it
+// uses the Clock provided by the WithClock option. The threshold and the WatchdogInterval must be
+// positive. Otherwise, building the pipeline fails.
+func Watchdog(threshold time.Duration, opts ...WatchdogOption) Option {
+	wo := watchdogOptions{threshold: threshold, interval: threshold / 4}
+	for _, opt := range opts {
+		opt(&wo)
+	}
+	return func(options *creationOptions) {
+		options.watchdog = &wo
+	}
+}
+
+// StalledNode describes a node in a chain of blocked nodes.
+type StalledNode struct {
+	// Name of the node: the NodesMap field name, or the node id if the Runner was
+	// created from a configuration document
+	Name string
+	// Running is false if the node function has returned
+	Running bool
+	// BufferedItems is the number of items that are waiting in the node input channel
+	BufferedItems int
+	// Goroutines describes the operations where the goroutines of the node are blocked
+	// (e.g. "chan send in main.Ingest (/home/user/myproject/ingest.go:31)").
+	Goroutines []string
+}
+
+func (sn *StalledNode) String() string {
+	var details []string
+	if !sn.Running {
+		details = append(details, "not running")
+	}
+	details = append(details, sn.Goroutines...)
+	if sn.BufferedItems > 0 {
+		details = append(details, fmt.Sprintf("%d buffered items", sn.BufferedItems))
+	}
+	return sn.Name + " (" + strings.Join(details, "; ") + ")"
+}
+
+// StallError reports a chain of nodes that have been blocked for longer than the Watchdog
+// threshold.
+type StallError struct {
+	// Chain of blocked nodes, starting from a node that is blocked sending data, and following
+	// the blocked destination nodes
+	Chain []StalledNode
+	// Threshold of the Watchdog
+	Threshold time.Duration
+}
+
+func (e *StallError) Error() string {
+	nodes := make([]string, 0, len(e.Chain))
+	for i := range e.Chain {
+		nodes = append(nodes, e.Chain[i].String())
+	}
+	return fmt.Sprintf("%s for more than %s: %s", ErrStalled, e.Threshold, strings.Join(nodes, " -> "))
+}
+
+func (e *StallError) Unwrap() error {
+	return ErrStalled
+}
+
+type watchdog struct {
+	watchdogOptions
+	clock Clock
+	// probes count the items received by each node, by node name
+	probes map[string]*connect.Probe
+	// samples stores the last observed status of each node, and since when it's unchanged
+	samples map[string]nodeSample
+	// reported stores the blocked senders whose stall has already been reported
+	reported map[string]bool
+
+	detectOnce sync.Once
+	detected   chan struct{}
+	err        *StallError
+}
+
+type nodeSample struct {
+	signature string
+	since     time.Time
+}
+
+// nodeStatus is the status of a node during a watchdog check
+type nodeStatus struct {
+	StalledNode
+	// waiting is true if an item is waiting to be read by the node
+	waiting bool
+	// full is true if the nodes sending data to this node would block
+	full bool
+	// blocked is true if the node is waiting and its progress hasn't changed since the previous check
+	blocked bool
+	// stalled is true if the node is waiting and its progress hasn't changed during the threshold time
+	stalled bool
+	// sending is true if the node is blocked sending data to any of its destinations
+	sending bool
+	dsts    []string
+}
+
+func newWatchdog(options *watchdogOptions, clock Clock) (*watchdog, error) {
+	if options.threshold <= 0 {
+		return nil, fmt.Errorf("the watchdog threshold must be positive. Got %s", options.threshold)
+	}
+	if options.interval <= 0 {
+		return nil, fmt.Errorf("the watchdog interval must be positive. Got %s", options.interval)
+	}
+	return &watchdog{
+		watchdogOptions: *options,
+		clock:           clock,
+		probes:          map[string]*connect.Probe{},
+		samples:         map[string]nodeSample{},
+		reported:        map[string]bool{},
+		detected:        make(chan struct{}),
+	}, nil
+}
+
+// watch makes the nodes receive their inputs through a Probe
+func (w *watchdog) watch(nodes map[string]inspectable) {
+	for name, node := range nodes {
+		if probe := node.watch(); probe != nil {
+			w.probes[name] = probe
+		}
+	}
+}
+
+// run inspects the nodes of the Runner until it is done
+func (w *watchdog) run(r *Runner) {
+	for {
+		tick, stop := w.clock.NewTimer(w.interval)
+		select {
+		case <-r.Done():
+			stop()
+			return
+		case <-tick:
+		}
+		if r.gate.IsClosed() {
+			// paused nodes are blocked on purpose
+			w.samples = map[string]nodeSample{}
+			continue
+		}
+		stalls := w.check(r)
+		if len(stalls) > 0 {
+			addGoroutines(stalls, r.tracker.Live())
+		}
+		for _, stall := range stalls {
+			w.report(stall)
+		}
+	}
+}
+
+// check samples the status of all the nodes and returns the chains of nodes that have been
+// blocked longer than the threshold and haven't been reported yet
+func (w *watchdog) check(r *Runner) []*StallError {
+	status := w.sample(r)
+	var stalls []*StallError
+	inChain := map[string]bool{}
+	for _, head := range w.chainHeads(status) {
+		if inChain[head] {
+			continue
+		}
+		chain := followChain(status, head)
+		for _, node := range chain {
+			inChain[node.Name] = true
+		}
+		if !w.reported[head] {
+			w.reported[head] = true
+			stalls = append(stalls, &StallError{Chain: chain, Threshold: w.threshold})
+		}
+	}
+	return stalls
+}
+
+// chainHeads returns the stalled nodes, sorted so the nodes that aren't blocked by other stalled
+// senders go first. The nodes whose chain would be included in the chain of a blocked sender that
+// isn't stalled yet are omitted, to be reported later.
+func (w *watchdog) chainHeads(status map[string]*nodeStatus) []string {
+	downstream, pending := map[string]bool{}, map[string]bool{}
+	for name, st := range status {
+		if !st.blocked {
+			delete(w.reported, name)
+			continue
+		}
+		if !st.sending {
+			continue
+		}
+		for _, dst := range st.dsts {
+			if dstSt := status[dst]; dstSt.blocked && dstSt.full {
+				if st.stalled {
+					downstream[dst] = true
+				} else {
+					pending[dst] = true
+				}
+			}
+		}
+	}
+	var heads []string
+	for name, st := range status {
+		if st.stalled && !pending[name] {
+			heads = append(heads, name)
+		}
+	}
+	sort.Slice(heads, func(i, j int) bool {
+		if downstream[heads[i]] != downstream[heads[j]] {
+			return !downstream[heads[i]]
+		}
+		return heads[i] < heads[j]
+	})
+	return heads
+}
+
+// sample returns the current status of the nodes, updating the time since they are unchanged
+func (w *watchdog) sample(r *Runner) map[string]*nodeStatus {
+	now := w.clock.Now()
+	inputs := map[any]string{}
+	for name, node := range r.nodes {
+		if in := node.input(); in != nil {
+			inputs[in] = name
+		}
+	}
+	status := make(map[string]*nodeStatus, len(r.nodes))
+	for name, node := range r.nodes {
+		st := &nodeStatus{StalledNode: StalledNode{
+			Name:          name,
+			Running:       node.isRunning(),
+			BufferedItems: node.bufferedItems(),
+		}}
+		if full, ok := node.input().(interface{ Full() bool }); ok {
+			st.full = full.Full()
+		}
+		for _, dst := range node.destinations() {
+			if dstName, ok := inputs[dst]; ok {
+				st.dsts = append(st.dsts, dstName)
+			}
+		}
+		status[name] = st
+		probe, ok := w.probes[name]
+		if !ok {
+			continue
+		}
+		progress, waiting := probe.Progress()
+		st.waiting = waiting
+		signature := fmt.Sprint(progress, st.Running)
+		sample, ok := w.samples[name]
+		if !ok || sample.signature != signature {
+			sample = nodeSample{signature: signature, since: now}
+			w.samples[name] = sample
+		}
+		st.blocked = waiting && now.After(sample.since)
+		st.stalled = waiting && now.Sub(sample.since) >= w.threshold
+	}
+	for _, st := range status {
+		for _, dst := range st.dsts {
+			if status[dst].blocked && status[dst].full {
+				st.sending = true
+			}
+		}
+	}
+	// the nodes without inputs (Start nodes) are blocked as long as they are blocked sending
+	for name, st := range status {
+		if _, ok := w.probes[name]; !ok && st.Running && st.sending {
+			st.blocked = true
+			for _, dst := range st.dsts {
+				st.stalled = st.stalled || status[dst].stalled && status[dst].full
+			}
+		}
+	}
+	return status
+}
+
+// followChain returns the chain of blocked nodes that starts in the provided head. The
+// destination nodes don't need to be stalled for the whole threshold, as they could have
+// been observed later than their senders.
+func followChain(status map[string]*nodeStatus, head string) []StalledNode {
+	chain := []StalledNode{status[head].StalledNode}
+	visited := map[string]bool{head: true}
+	for current := status[head]; current.sending; {
+		var next *nodeStatus
+		for _, dst := range current.dsts {
+			if st := status[dst]; st.blocked && st.full && !visited[dst] {
+				next = st
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		visited[next.Name] = true
+		chain = append(chain, next.StalledNode)
+		current = next
+	}
+	return chain
+}
+
+func (w *watchdog) report(stall *StallError) {
+	w.detectOnce.Do(func() {
+		w.err = stall
+		close(w.detected)
+	})
+	if w.logger != nil {
+		w.logger.Warn(stall.Error())
+	}
+	if w.onStall != nil {
+		w.onStall(stall)
+	}
+}
+
+// addGoroutines adds the description of the live goroutines of each stalled node
+func addGoroutines(stalls []*StallError, live []connect.Goroutine) {
+	goroutines := map[string][]string{}
+	for _, g := range live {
+		owner := strings.TrimSuffix(g.Owner, deadLettersSuffix)
+		goroutines[owner] = append(goroutines[owner], g.State+" in "+g.Location)
+	}
+	for _, stall := range stalls {
+		for i := range stall.Chain {
+			stall.Chain[i].Goroutines = goroutines[stall.Chain[i].Name]
+			sort.Strings(stall.Chain[i].Goroutines)
+		}
+	}
+}
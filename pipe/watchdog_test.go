@@ -0,0 +1,180 @@
+package pipe_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+func TestWatchdog(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+	stalls := make(chan *pipe.StallError, 10)
+	logs := &bytes.Buffer{}
+	b := pipe.NewBuilder(&smfPipe{}, pipe.WithClock(clock), pipe.Watchdog(time.Second,
+		pipe.WatchdogOnStall(func(err *pipe.StallError) { stalls <- err }),
+		pipe.WatchdogLogger(slog.New(slog.NewTextHandler(logs, nil)))))
+	pipe.AddStart(b, start, Counter(1, 5))
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	// the final node stops reading after the first item
+	unblock := make(chan struct{})
+	var received []int
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		received = append(received, <-in)
+		<-unblock
+		for i := range in {
+			received = append(received, i)
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	// advancing the clock until the watchdog detects the stall
+	var stall *pipe.StallError
+	for i := 0; stall == nil && i < 100; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(250 * time.Millisecond)
+		select {
+		case stall = <-stalls:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	require.NotNil(t, stall)
+	assert.ErrorIs(t, stall, pipe.ErrStalled)
+	require.Len(t, stall.Chain, 3)
+	assert.Equal(t, "start", stall.Chain[0].Name)
+	assert.Equal(t, "mid", stall.Chain[1].Name)
+	assert.Equal(t, "final", stall.Chain[2].Name)
+	assert.Contains(t, strings.Join(stall.Chain[2].Goroutines, "\n"),
+		"chan receive in github.com/mariomac/pipes/pipe_test.TestWatchdog")
+	assert.Contains(t, logs.String(), "pipeline stalled for more than 1s: start (")
+
+	// the Runner's Wait method reports the stall
+	err = r.Wait(timeout)
+	var stallErr *pipe.StallError
+	require.True(t, errors.As(err, &stallErr))
+	assert.Same(t, stall, stallErr)
+
+	// the stall is reported only once
+	for i := 0; i < 10; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(250 * time.Millisecond)
+	}
+	assert.Empty(t, stalls)
+
+	close(unblock)
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, received)
+}
+
+func TestWatchdog_NotStalled(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+	stalls := make(chan *pipe.StallError, 10)
+	b := pipe.NewBuilder(&smfPipe{}, pipe.WithClock(clock), pipe.Pausable(), pipe.Watchdog(time.Second,
+		pipe.WatchdogOnStall(func(err *pipe.StallError) { stalls <- err })))
+	quit := make(chan struct{})
+	pipe.AddStart(b, start, func(out chan<- int) {
+		// waiting for external data does not stall the pipeline
+		<-quit
+	})
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		for i := range in {
+			out <- i
+		}
+	})
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		for range in {
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+	for i := 0; i < 20; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(250 * time.Millisecond)
+	}
+	close(quit)
+	testers.ReadChannel(t, r.Done(), timeout)
+	assert.Empty(t, stalls)
+}
+
+func TestWatchdog_BlockedInSelect(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+	stalls := make(chan *pipe.StallError, 10)
+	b := pipe.NewBuilder(&smfPipe{}, pipe.WithClock(clock), pipe.Watchdog(time.Second,
+		pipe.WatchdogOnStall(func(err *pipe.StallError) { stalls <- err })))
+	quit := make(chan struct{})
+	pipe.AddStart(b, start, func(out chan<- int) {
+		out <- 1
+		// waiting for external data
+		<-quit
+	})
+	// the middle node is blocked in a select that doesn't read its input
+	unblock, external := make(chan struct{}), make(chan int)
+	pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {
+		select {
+		case i := <-external:
+			out <- i
+		case <-unblock:
+		}
+		for i := range in {
+			out <- i
+		}
+	}, pipe.ChannelBufferLen(1))
+	pipe.AddFinal(b, final, func(in <-chan int) {
+		for range in {
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+
+	var stall *pipe.StallError
+	for i := 0; stall == nil && i < 100; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(250 * time.Millisecond)
+		select {
+		case stall = <-stalls:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	require.NotNil(t, stall)
+	// the start node isn't blocked, as the middle node input is not full
+	require.Len(t, stall.Chain, 1)
+	assert.Equal(t, "mid", stall.Chain[0].Name)
+	assert.True(t, stall.Chain[0].Running)
+	assert.Equal(t, 1, stall.Chain[0].BufferedItems)
+
+	close(unblock)
+	close(quit)
+	testers.ReadChannel(t, r.Done(), timeout)
+}
+
+func TestWatchdog_InvalidOptions(t *testing.T) {
+	for _, opt := range []pipe.Option{
+		pipe.Watchdog(0),
+		pipe.Watchdog(time.Second, pipe.WatchdogInterval(0)),
+		pipe.Watchdog(-time.Second),
+	} {
+		b := pipe.NewBuilder(&smfPipe{}, opt)
+		pipe.AddStart(b, start, Counter(1, 3))
+		pipe.AddMiddle(b, mid, func(in <-chan int, out chan<- int) {})
+		pipe.AddFinal(b, final, func(in <-chan int) {})
+		_, err := b.Build()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be positive")
+	}
+}
@@ -0,0 +1,151 @@
+package testers
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// WithTimeoutClock wraps the test to make the functions of this package measure the timeouts
+// with the provided FakeClock instead of the time functions from the standard library, when
+// they are invoked with the returned testing.TB. The test can deterministically trigger the
+// timeouts by advancing the clock:
+//
+//	clock := testers.NewFakeClock(time.Now())
+//	item := testers.ReadChannel(testers.WithTimeoutClock(t, clock), ch, time.Second)
+func WithTimeoutClock(t testing.TB, clock *FakeClock) testing.TB {
+	return clockedTest{TB: t, clock: clock}
+}
+
+// clockedTest is a test whose timeouts are measured by a FakeClock
+type clockedTest struct {
+	testing.TB
+	clock *FakeClock
+}
+
+// newTimeout returns a channel that receives the current time after the timeout, measured by
+// the FakeClock of the test if it was wrapped by WithTimeoutClock, and a function to stop it
+func newTimeout(t testing.TB, timeout time.Duration) (<-chan time.Time, func() bool) {
+	if ct, ok := t.(clockedTest); ok {
+		return ct.clock.NewTimer(timeout)
+	}
+	timer := time.NewTimer(timeout)
+	return timer.C, timer.Stop
+}
+
+// FakeClock implements the pipe.Clock interface with a time that only moves forward
+// when the Advance method is invoked, so the time-based features can be deterministically
+// tested:
+//
+//	clock := testers.NewFakeClock(time.Now())
+//	builder := pipe.NewBuilder(&MyPipe{}, pipe.WithClock(clock))
+//	...
+//	clock.Advance(2 * time.Minute)
+type FakeClock struct {
+	mt     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewFakeClock creates a FakeClock whose current time is the provided time.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mt)
+	return c
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the current time once the clock has been advanced
+// by the provided duration. If the duration is not positive, the channel receives it immediately.
+// The timer can't be stopped, so it is waiting for the clock to be advanced until it fires, even
+// if the returned channel is abandoned. Use NewTimer in that case.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	ch, _ := c.NewTimer(d)
+	return ch
+}
+
+// NewTimer is equivalent to After, but it also returns a function that stops the timer, so it
+// is not waiting for the clock to be advanced anymore. The stop function returns false if the
+// timer already fired or was stopped.
+func (c *FakeClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch, func() bool { return false }
+	}
+	timer := &fakeTimer{deadline: c.now.Add(d), ch: ch}
+	c.timers = append(c.timers, timer)
+	c.cond.Broadcast()
+	return ch, func() bool { return c.stop(timer) }
+}
+
+func (c *FakeClock) stop(timer *fakeTimer) bool {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+	for i, t := range c.timers {
+		if t == timer {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Sleep blocks the current goroutine until the clock has been advanced by the provided duration.
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// Advance moves the current time of the clock forward, firing all the timers whose deadline
+// is reached, in deadline order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	fired := 0
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			break
+		}
+		t.ch <- c.now
+		fired++
+	}
+	c.timers = c.timers[fired:]
+}
+
+// BlockUntil blocks until there are at least n timers waiting for the clock to be advanced
+// (e.g. goroutines invoking Sleep or waiting on the channel returned by After or NewTimer).
+// The stopped timers are not counted. It allows
+// synchronizing the test with the code under test before advancing the clock.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// Timers returns the number of timers waiting for the clock to be advanced.
+func (c *FakeClock) Timers() int {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+	return len(c.timers)
+}
@@ -0,0 +1,77 @@
+package testers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+var _ pipe.Clock = (*testers.FakeClock)(nil)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := testers.NewFakeClock(start)
+	assert.Equal(t, start, clock.Now())
+
+	second, minute := clock.After(time.Second), clock.After(time.Minute)
+	immediate := clock.After(0)
+	assert.Equal(t, start, testers.ReadChannel(t, immediate, timeout))
+	assert.Equal(t, 2, clock.Timers())
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, start.Add(30*time.Second), testers.ReadChannel(t, second, timeout))
+	select {
+	case <-minute:
+		t.Fatal("timer shouldn't have fired yet")
+	default:
+	}
+	assert.Equal(t, 1, clock.Timers())
+
+	slept := make(chan struct{})
+	go func() {
+		clock.Sleep(time.Hour)
+		close(slept)
+	}()
+	clock.BlockUntil(2)
+	clock.Advance(time.Hour)
+	assert.Equal(t, start.Add(time.Hour+30*time.Second), testers.ReadChannel(t, minute, timeout))
+	testers.ReadChannel(t, slept, timeout)
+	assert.Zero(t, clock.Timers())
+}
+
+func TestFakeClock_NewTimer(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+	stale, stopStale := clock.NewTimer(time.Second)
+	live, _ := clock.NewTimer(time.Minute)
+	assert.Equal(t, 2, clock.Timers())
+
+	// the stopped timers are not counted as waiting, and they never fire
+	assert.True(t, stopStale())
+	assert.False(t, stopStale())
+	assert.Equal(t, 1, clock.Timers())
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	testers.ReadChannel(t, live, timeout)
+	select {
+	case <-stale:
+		t.Fatal("stopped timer shouldn't have fired")
+	default:
+	}
+	assert.Zero(t, clock.Timers())
+}
+
+func TestWithTimeoutClock(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+
+	ch := make(chan int)
+	go func() {
+		// the timeout is not triggered until the clock is advanced
+		clock.BlockUntil(1)
+		ch <- 1
+	}()
+	assert.Equal(t, 1, testers.ReadChannel(testers.WithTimeoutClock(t, clock), ch, time.Nanosecond))
+}
@@ -0,0 +1,150 @@
+package testers
+
+import (
+	"testing"
+	"time"
+)
+
+// DefaultTimeout is the maximum time that RunMiddle and RunFinal wait for the tested
+// node functions to return.
+var DefaultTimeout = 5 * time.Second
+
+// RunMiddle runs a Middle node function (e.g. a pipe.MiddleFunc), without requiring to build
+// a pipeline. It sends the provided inputs to the function, closes its input channel and returns
+// all the items that the function forwarded before returning:
+//
+//	filter, err := MatchFilterProvider("hello")()
+//	require.NoError(t, err)
+//	out := testers.RunMiddle(t, filter, FileLine{Line: "hello world"}, FileLine{Line: "bye"})
+//	assert.Equal(t, []FileLine{{Line: "hello world"}}, out)
+//
+// The test fails if the function does not return after DefaultTimeout.
+func RunMiddle[IN, OUT any](t testing.TB, fn func(in <-chan IN, out chan<- OUT), inputs ...IN) []OUT {
+	t.Helper()
+	in, out := make(chan IN), make(chan OUT)
+	go func() {
+		defer close(out)
+		fn(in, out)
+	}()
+	collected := make(chan []OUT, 1)
+	go func() {
+		var items []OUT
+		for o := range out {
+			items = append(items, o)
+		}
+		collected <- items
+	}()
+	timeout, stop := newTimeout(t, DefaultTimeout)
+	defer stop()
+	for i, input := range inputs {
+		select {
+		case in <- input:
+		case <-timeout:
+			t.Fatalf("timeout (%s): Middle function is not reading its input. Sent %d of %d items",
+				DefaultTimeout, i, len(inputs))
+		}
+	}
+	close(in)
+	select {
+	case items := <-collected:
+		return items
+	case <-timeout:
+		t.Fatalf("timeout (%s): Middle function didn't return after its input was closed",
+			DefaultTimeout)
+	}
+	return nil
+}
+
+// RunFinal runs a Final node function (e.g. a pipe.FinalFunc), without requiring to build
+// a pipeline. It sends the provided inputs to the function, closes its input channel and waits
+// for the function to return. The test fails if the function does not return after DefaultTimeout.
+func RunFinal[IN any](t testing.TB, fn func(in <-chan IN), inputs ...IN) {
+	t.Helper()
+	in, done := make(chan IN), make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(in)
+	}()
+	timeout, stop := newTimeout(t, DefaultTimeout)
+	defer stop()
+	for i, input := range inputs {
+		select {
+		case in <- input:
+		case <-timeout:
+			t.Fatalf("timeout (%s): Final function is not reading its input. Sent %d of %d items",
+				DefaultTimeout, i, len(inputs))
+		}
+	}
+	close(in)
+	select {
+	case <-done:
+	case <-timeout:
+		t.Fatalf("timeout (%s): Final function didn't return after its input was closed",
+			DefaultTimeout)
+	}
+}
+
+// CollectStart runs a Start node function (e.g. a pipe.StartFunc), without requiring to build
+// a pipeline, and returns the first n items that it forwards. The test fails if the function
+// returns before forwarding n items, or if the n items aren't received after the given timeout.
+// If the function forwards more than n items, it remains blocked when sending the (n+1)th item.
+func CollectStart[OUT any](t testing.TB, fn func(out chan<- OUT), n int, timeout time.Duration) []OUT {
+	t.Helper()
+	out, done := make(chan OUT), make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(out)
+	}()
+	items := make([]OUT, 0, n)
+	deadline, stop := newTimeout(t, timeout)
+	defer stop()
+	for len(items) < n {
+		select {
+		case item := <-out:
+			items = append(items, item)
+		case <-done:
+			t.Fatalf("Start function returned after forwarding %d of %d items: %v", len(items), n, items)
+		case <-deadline:
+			t.Fatalf("timeout (%s) while waiting for Start function items. Got %d of %d items: %v",
+				timeout, len(items), n, items)
+		}
+	}
+	return items
+}
+
+// ReadAll reads all the items from a channel until it's closed, and returns them. It fails the
+// test if the channel is not closed after the given timeout, reporting the name of the node that
+// didn't close it.
+func ReadAll[T any](t testing.TB, node string, inCh <-chan T, timeout time.Duration) []T {
+	t.Helper()
+	var items []T
+	deadline, stop := newTimeout(t, timeout)
+	defer stop()
+	for {
+		select {
+		case item, ok := <-inCh:
+			if !ok {
+				return items
+			}
+			items = append(items, item)
+		case <-deadline:
+			t.Fatalf("timeout (%s): node %s didn't close its output. Got %d items: %v",
+				timeout, node, len(items), items)
+			return items
+		}
+	}
+}
+
+// Waiter is implemented by the pipe.Runner
+type Waiter interface {
+	Wait(timeout time.Duration) error
+}
+
+// WaitPipeline waits for a started pipeline to finish, and fails the test if it doesn't finish
+// after the given timeout, reporting the nodes that are still running.
+func WaitPipeline(t testing.TB, runner Waiter, timeout time.Duration) {
+	t.Helper()
+	if err := runner.Wait(timeout); err != nil {
+		t.Fatalf("waiting for pipeline to finish: %s", err)
+	}
+}
@@ -0,0 +1,175 @@
+package testers_test
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/pipe"
+	"github.com/mariomac/pipes/testers"
+)
+
+const timeout = 5 * time.Second
+
+// failureRecorder is a testing.TB that records the failures of the tested helpers instead of
+// failing the test. Fatal failures stop the current goroutine, so the helpers that might fail
+// fatally must be invoked through the run method.
+type failureRecorder struct {
+	testing.TB
+	mt       sync.Mutex
+	failures []string
+}
+
+func (f *failureRecorder) Helper() {}
+
+func (f *failureRecorder) Errorf(format string, args ...any) {
+	f.mt.Lock()
+	defer f.mt.Unlock()
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func (f *failureRecorder) Fatal(args ...any) {
+	f.Errorf("%s", fmt.Sprint(args...))
+	runtime.Goexit()
+}
+
+func (f *failureRecorder) Fatalf(format string, args ...any) {
+	f.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// run invokes the function in another goroutine, and returns the recorded failures after it returns
+func (f *failureRecorder) run(fn func(t testing.TB)) []string {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(f)
+	}()
+	<-done
+	f.mt.Lock()
+	defer f.mt.Unlock()
+	return f.failures
+}
+
+func TestRunMiddle(t *testing.T) {
+	var upper pipe.MiddleFunc[string, string] = func(in <-chan string, out chan<- string) {
+		for i := range in {
+			out <- strings.ToUpper(i)
+		}
+	}
+	assert.Equal(t, []string{"HELLO", "WORLD"}, testers.RunMiddle(t, upper, "hello", "world"))
+	assert.Empty(t, testers.RunMiddle(t, upper))
+}
+
+func TestRunFinal(t *testing.T) {
+	var stored []int
+	var store pipe.FinalFunc[int] = func(in <-chan int) {
+		for i := range in {
+			stored = append(stored, i)
+		}
+	}
+	testers.RunFinal(t, store, 1, 2, 3)
+	assert.Equal(t, []int{1, 2, 3}, stored)
+}
+
+func TestCollectStart(t *testing.T) {
+	var counter pipe.StartFunc[int] = func(out chan<- int) {
+		for i := 1; i <= 5; i++ {
+			out <- i
+		}
+	}
+	assert.Equal(t, []int{1, 2, 3}, testers.CollectStart(t, counter, 3, timeout))
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, testers.CollectStart(t, counter, 5, timeout))
+}
+
+func TestReadAll(t *testing.T) {
+	ch := make(chan int, 3)
+	ch <- 1
+	ch <- 2
+	close(ch)
+	assert.Equal(t, []int{1, 2}, testers.ReadAll(t, "numbers", ch, timeout))
+}
+
+func TestRunMiddle_Timeout(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+	inputClosed, unblock := make(chan struct{}), make(chan struct{})
+	defer close(unblock)
+	var stuck pipe.MiddleFunc[int, int] = func(in <-chan int, out chan<- int) {
+		for range in {
+		}
+		close(inputClosed)
+		<-unblock
+	}
+	go func() {
+		<-inputClosed
+		clock.Advance(testers.DefaultTimeout)
+	}()
+
+	failures := (&failureRecorder{TB: t}).run(func(ft testing.TB) {
+		testers.RunMiddle(testers.WithTimeoutClock(ft, clock), stuck, 1, 2)
+	})
+	require.Len(t, failures, 1)
+	assert.Contains(t, failures[0], "Middle function didn't return after its input was closed")
+}
+
+func TestReadAll_Timeout(t *testing.T) {
+	clock := testers.NewFakeClock(time.Now())
+	go func() {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+	}()
+
+	failures := (&failureRecorder{TB: t}).run(func(ft testing.TB) {
+		testers.ReadAll(testers.WithTimeoutClock(ft, clock), "exporter", make(chan int), time.Second)
+	})
+	require.Len(t, failures, 1)
+	assert.Contains(t, failures[0], "timeout (1s): node exporter didn't close its output")
+}
+
+type pipeline struct {
+	start pipe.Start[int]
+	store pipe.Final[int]
+}
+
+func (p *pipeline) Connect() { p.start.SendTo(p.store) }
+
+func TestWaitPipeline(t *testing.T) {
+	b := pipe.NewBuilder(&pipeline{})
+	pipe.AddStart(b, func(p *pipeline) *pipe.Start[int] { return &p.start }, func(out chan<- int) {
+		out <- 1
+	})
+	var stored []int
+	pipe.AddFinal(b, func(p *pipeline) *pipe.Final[int] { return &p.store }, func(in <-chan int) {
+		for i := range in {
+			stored = append(stored, i)
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.WaitPipeline(t, r, timeout)
+	assert.Equal(t, []int{1}, stored)
+}
+
+func TestVerifyNoLeaks(t *testing.T) {
+	b := pipe.NewBuilder(&pipeline{})
+	pipe.AddStart(b, func(p *pipeline) *pipe.Start[int] { return &p.start }, func(out chan<- int) {
+		for i := 0; i < 10; i++ {
+			out <- i
+		}
+	})
+	pipe.AddFinal(b, func(p *pipeline) *pipe.Final[int] { return &p.store }, func(in <-chan int) {
+		for range in {
+		}
+	})
+	r, err := b.Build()
+	require.NoError(t, err)
+	r.Start()
+	testers.VerifyNoLeaks(t, r)
+}
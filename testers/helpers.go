@@ -20,30 +20,34 @@ func (a *AsyncWaiter) Done() {
 	(*sync.WaitGroup)(a).Done()
 }
 
-func (a *AsyncWaiter) Wait(t *testing.T, timeout time.Duration) {
+func (a *AsyncWaiter) Wait(t testing.TB, timeout time.Duration) {
 	t.Helper()
 	done := make(chan struct{})
 	go func() {
 		(*sync.WaitGroup)(a).Wait()
 		close(done)
 	}()
+	expired, stop := newTimeout(t, timeout)
+	defer stop()
 	select {
 	case <-done:
 		return
-	case <-time.After(timeout):
+	case <-expired:
 		t.Fatal("timeout waiting for test to be completed")
 	}
 }
 
 // ReadChannel tries to read a message from a channel and returns it. If there isn't any
 // message after the given timeout, it fails the provided test
-func ReadChannel[T any](t *testing.T, inCh <-chan T, timeout time.Duration) T {
+func ReadChannel[T any](t testing.TB, inCh <-chan T, timeout time.Duration) T {
 	t.Helper()
 	var item T
+	expired, stop := newTimeout(t, timeout)
+	defer stop()
 	select {
 	case item = <-inCh:
 		return item
-	case <-time.After(timeout):
+	case <-expired:
 		t.Fatalf("timeout (%s) while waiting for event in input channel", timeout)
 	}
 	return item
@@ -0,0 +1,46 @@
+package testers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// leakGracePeriod is the time that VerifyNoLeaks waits for the goroutines to exit
+// after the pipeline is done
+const leakGracePeriod = time.Second
+
+// LeakDetector is implemented by the pipe.Runner
+type LeakDetector interface {
+	Waiter
+	// LiveGoroutines returns a description of the goroutines started by the pipeline
+	// that haven't exited yet, including where they are blocked
+	LiveGoroutines() []string
+}
+
+// VerifyNoLeaks waits for a started pipeline to finish and verifies that all the goroutines
+// that were started by its nodes and connections have exited. Otherwise, it fails the test
+// reporting the nodes that started the stuck goroutines and the operations where they are blocked.
+// The goroutines are given a grace period of one second to exit, measured in real time:
+//
+//	builder := pipe.NewBuilder(&MyPipe{})
+//	...
+//	runner, err := builder.Build()
+//	require.NoError(t, err)
+//	runner.Start()
+//	testers.VerifyNoLeaks(t, runner)
+func VerifyNoLeaks(t testing.TB, runner LeakDetector) {
+	t.Helper()
+	WaitPipeline(t, runner, DefaultTimeout)
+	// some goroutines might still be returning after the pipeline is done
+	deadline := time.Now().Add(leakGracePeriod)
+	live := runner.LiveGoroutines()
+	for len(live) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		live = runner.LiveGoroutines()
+	}
+	if len(live) > 0 {
+		t.Errorf("%d goroutines didn't exit after the pipeline finished:\n\t%s",
+			len(live), strings.Join(live, "\n\t"))
+	}
+}
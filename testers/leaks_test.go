@@ -0,0 +1,49 @@
+package testers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/testers"
+)
+
+// exitingPipeline is a LeakDetector whose goroutines exit a few checks after the pipeline is done
+type exitingPipeline struct {
+	checks int
+}
+
+func (e *exitingPipeline) Wait(time.Duration) error { return nil }
+
+func (e *exitingPipeline) LiveGoroutines() []string {
+	if e.checks++; e.checks > 3 {
+		return nil
+	}
+	return []string{"final: chan receive in main.Store (/home/user/store.go:12)"}
+}
+
+func TestVerifyNoLeaks_FakeTimeoutClock(t *testing.T) {
+	// the grace period to exit is measured in real time, so it doesn't wait for the clock to advance
+	clock := testers.NewFakeClock(time.Now())
+	testers.VerifyNoLeaks(testers.WithTimeoutClock(t, clock), &exitingPipeline{})
+}
+
+// leakingPipeline is a LeakDetector whose goroutines never exit
+type leakingPipeline struct{}
+
+func (leakingPipeline) Wait(time.Duration) error { return nil }
+
+func (leakingPipeline) LiveGoroutines() []string {
+	return []string{"final: chan receive in main.Store (/home/user/store.go:12)"}
+}
+
+func TestVerifyNoLeaks_Leaked(t *testing.T) {
+	failures := (&failureRecorder{TB: t}).run(func(ft testing.TB) {
+		testers.VerifyNoLeaks(ft, leakingPipeline{})
+	})
+	require.Len(t, failures, 1)
+	assert.Contains(t, failures[0], "1 goroutines didn't exit after the pipeline finished")
+	assert.Contains(t, failures[0], "final: chan receive in main.Store (/home/user/store.go:12)")
+}
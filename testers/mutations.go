@@ -0,0 +1,171 @@
+package testers
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// MutationsWindow is the maximum number of received items that each receiver wrapped by
+// Mutations.Receiving retains to verify them again after they are processed. When the window
+// is full, the oldest item is verified and discarded.
+var MutationsWindow = 1000
+
+// Mutations verifies that the receivers of the items that a node sends to multiple destinations
+// don't modify them. It is created with NoMutations.
+type Mutations[T any] struct {
+	t     testing.TB
+	clone func(T) T
+
+	mt sync.Mutex
+	// snapshots of the sent items that haven't been received yet by all the receivers.
+	// snapshots[0] is the snapshot of the item with index first
+	snapshots []T
+	first     int
+	// cursors store the index of the next item to be received by each receiver
+	cursors []int
+}
+
+// NoMutations verifies that the items that are sent by a node are not modified while they are
+// processed by the pipeline. The sender node function must be wrapped by the Sending method,
+// which takes a snapshot of each item with the provided clone function before it is forwarded
+// to any destination. The Final node functions that receive the items must be wrapped by the
+// Receiving method, which fails the test if any item differs from its snapshot when it is
+// received or after it is processed:
+//
+//	mutations := testers.NoMutations(t, (*Event).DeepCopy)
+//	pipe.AddStart(p, (*MyPipe).Reader, mutations.Sending(reader))
+//	pipe.AddFinal(p, (*MyPipe).Exporter, mutations.Receiving(exporter))
+//
+// The wrapped receivers must receive all the items that are sent by the wrapped sender, and only
+// them, in the same order. The snapshots are deeply compared with reflect.DeepEqual.
+// Since the items are read when they are received and after they are processed, running the test
+// with the -race flag also makes the race detector report the receivers that modify the items
+// concurrently.
+func NoMutations[T any](t testing.TB, clone func(T) T) *Mutations[T] {
+	return &Mutations[T]{t: t, clone: clone}
+}
+
+// Sending wraps a Start node function (e.g. a pipe.StartFunc) to take a snapshot of each item
+// that it sends, before the item is forwarded to its destinations. The wrapped function receives
+// a different channel than the node output.
+func (m *Mutations[T]) Sending(fn func(out chan<- T)) func(out chan<- T) {
+	return func(out chan<- T) {
+		items := make(chan T)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for item := range items {
+				m.snapshot(item)
+				out <- item
+			}
+		}()
+		fn(items)
+		close(items)
+		<-done
+	}
+}
+
+// SendingMiddle is equivalent to Mutations.Sending for a Middle node function (e.g. a pipe.MiddleFunc).
+func SendingMiddle[IN, OUT any](m *Mutations[OUT], fn func(in <-chan IN, out chan<- OUT)) func(in <-chan IN, out chan<- OUT) {
+	return func(in <-chan IN, out chan<- OUT) {
+		m.Sending(func(out chan<- OUT) {
+			fn(in, out)
+		})(out)
+	}
+}
+
+// receivedItem is an item that is retained by a receiver, to verify it after it is processed
+type receivedItem[T any] struct {
+	index          int
+	item, snapshot T
+}
+
+// Receiving wraps a Final node function (e.g. a pipe.FinalFunc) to verify that each item that it
+// receives is equal to the snapshot that was taken when the item was sent, and that it's still
+// equal after it is processed. The last MutationsWindow items are verified after the wrapped
+// function returns.
+func (m *Mutations[T]) Receiving(fn func(in <-chan T)) func(in <-chan T) {
+	receiver := m.addReceiver()
+	return func(in <-chan T) {
+		var window []receivedItem[T]
+		forward := make(chan T)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			fn(forward)
+		}()
+	receive:
+		for item := range in {
+			index, snapshot, ok := m.next(receiver)
+			if !ok {
+				m.t.Errorf("item #%d was not sent by the node wrapped with Sending: %+v", index, item)
+			} else {
+				received := receivedItem[T]{index: index, item: item, snapshot: snapshot}
+				m.verify(received, "before being received")
+				window = append(window, received)
+				if len(window) > MutationsWindow {
+					m.verify(window[0], "after being received")
+					window = window[1:]
+				}
+			}
+			select {
+			case forward <- item:
+			case <-done:
+				// the wrapped function returned before its input was closed
+				break receive
+			}
+		}
+		close(forward)
+		<-done
+		for _, received := range window {
+			m.verify(received, "after being received")
+		}
+	}
+}
+
+func (m *Mutations[T]) verify(received receivedItem[T], when string) {
+	if !reflect.DeepEqual(received.snapshot, received.item) {
+		m.t.Errorf("item #%d was modified %s.\n\tsent: %+v\n\tmodified: %+v",
+			received.index, when, received.snapshot, received.item)
+	}
+}
+
+func (m *Mutations[T]) snapshot(item T) {
+	snapshot := m.clone(item)
+	m.mt.Lock()
+	defer m.mt.Unlock()
+	m.snapshots = append(m.snapshots, snapshot)
+}
+
+func (m *Mutations[T]) addReceiver() int {
+	m.mt.Lock()
+	defer m.mt.Unlock()
+	m.cursors = append(m.cursors, m.first)
+	return len(m.cursors) - 1
+}
+
+// next returns the index and the snapshot of the next item that the receiver must receive,
+// and discards the snapshots that have been already received by all the receivers
+func (m *Mutations[T]) next(receiver int) (int, T, bool) {
+	m.mt.Lock()
+	defer m.mt.Unlock()
+	index := m.cursors[receiver]
+	if index-m.first >= len(m.snapshots) {
+		var none T
+		return index, none, false
+	}
+	snapshot := m.snapshots[index-m.first]
+	m.cursors[receiver]++
+	received := m.cursors[0]
+	for _, c := range m.cursors {
+		if c < received {
+			received = c
+		}
+	}
+	if received > m.first {
+		m.snapshots = m.snapshots[received-m.first:]
+		m.first = received
+	}
+	return index, snapshot, true
+}
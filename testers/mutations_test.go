@@ -0,0 +1,77 @@
+package testers_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/testers"
+)
+
+func cloneMap(m map[string]int) map[string]int {
+	cp := make(map[string]int, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	return cp
+}
+
+func TestNoMutations(t *testing.T) {
+	mutations := testers.NoMutations(t, cloneMap)
+	sender := mutations.Sending(func(out chan<- map[string]int) {
+		for i := 1; i <= 3; i++ {
+			out <- map[string]int{"value": i}
+		}
+	})
+	var sums [2]int
+	readers := [2]func(in <-chan map[string]int){}
+	for i := range readers {
+		sum := &sums[i]
+		readers[i] = mutations.Receiving(func(in <-chan map[string]int) {
+			for m := range in {
+				*sum += m["value"]
+			}
+		})
+	}
+
+	// the sender shares the same items with both readers
+	items := testers.CollectStart(t, sender, 3, testers.DefaultTimeout)
+	testers.RunFinal(t, readers[0], items...)
+	testers.RunFinal(t, readers[1], items...)
+	assert.Equal(t, [2]int{6, 6}, sums)
+}
+
+func TestNoMutations_Modified(t *testing.T) {
+	recorder := &failureRecorder{TB: t}
+	mutations := testers.NoMutations(recorder, cloneMap)
+	sender := mutations.Sending(func(out chan<- map[string]int) {
+		for i := 1; i <= 2; i++ {
+			out <- map[string]int{"value": i}
+		}
+	})
+	var received []map[string]int
+	reader := mutations.Receiving(func(in <-chan map[string]int) {
+		for m := range in {
+			received = append(received, m)
+		}
+	})
+	mutator := mutations.Receiving(func(in <-chan map[string]int) {
+		for m := range in {
+			if m["value"] == 2 {
+				m["value"] = 0
+			}
+		}
+	})
+
+	items := testers.CollectStart(t, sender, 2, testers.DefaultTimeout)
+	testers.RunFinal(t, mutator, items...)
+	testers.RunFinal(t, reader, items...)
+	assert.Equal(t, []map[string]int{{"value": 1}, {"value": 0}}, received)
+
+	// the item is reported as modified by the receiver that mutated it and by the receiver that got it modified
+	require.Len(t, recorder.failures, 3)
+	assert.Contains(t, recorder.failures[0], "item #1 was modified after being received")
+	assert.Contains(t, recorder.failures[1], "item #1 was modified before being received")
+	assert.Contains(t, recorder.failures[2], "item #1 was modified after being received")
+}
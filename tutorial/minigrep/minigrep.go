@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -35,28 +36,42 @@ func (n *MiniGrepNodes) Connect() {
 	n.matchFilter.SendTo(n.printer)
 }
 
-// auxiliary functions that return pointers to the different fields of the MiniGrepNodes
-// struct. They are grouped here for code conciseness in the AddStart, AddMiddle, etc... function invocations.
-func finderPtr(f *MiniGrepNodes) *pipe.Start[*os.File]             { return &f.fileFinder }
-func scannerPtr(f *MiniGrepNodes) *pipe.Middle[*os.File, FileLine] { return &f.fileScanner }
-func matcherPtr(f *MiniGrepNodes) *pipe.Middle[FileLine, FileLine] { return &f.matchFilter }
-func printerPtr(f *MiniGrepNodes) *pipe.Final[FileLine]            { return &f.printer }
+// The functions that return pointers to the different fields of the MiniGrepNodes struct
+// (fileFinderPtr, fileScannerPtr, matchFilterPtr and printerPtr), required by the AddStart,
+// AddMiddle, etc... function invocations, are generated in the minigrepnodes_pipes.go file.
+//go:generate go run github.com/mariomac/pipes/cmd/pipesgen -type MiniGrepNodes
 
 // FileFinder opens the files passed as argument and forwards them to the next pipeline stage.
 // If the file is not found or can't be opened, it just prints a message in the standard error.
-func FileFinder(files []string) pipe.StartFunc[*os.File] {
-	return func(out chan<- *os.File) {
+// When the pipeline is shut down, it stops opening files and closes the file that it couldn't
+// forward.
+func FileFinder(files []string) pipe.CancelableStartFunc[*os.File] {
+	return func(ctx context.Context, out chan<- *os.File) error {
 		// if no file patterns are provided, minigrep filters standard input
 		if len(files) == 0 {
-			out <- os.Stdin
+			select {
+			case out <- os.Stdin:
+			case <-ctx.Done():
+			}
+			return nil
 		}
 		for _, fname := range files {
-			if handler, err := os.Open(fname); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			handler, err := os.Open(fname)
+			if err != nil {
 				fmt.Fprintf(os.Stderr, "%s: %s\n", fname, err.Error())
-			} else {
-				out <- handler
+				continue
+			}
+			select {
+			case out <- handler:
+			case <-ctx.Done():
+				handler.Close()
+				return nil
 			}
 		}
+		return nil
 	}
 }
 
@@ -125,20 +140,22 @@ func main() {
 	// any error.
 	// AddMiddleProvider specifies a node that can return an error and interrupt
 	// the pipeline creation, if the user provides a wrong regular expression pattern.
+	// AddCancelableStart allows stopping the file finder on graceful shutdown.
 	builder := pipe.NewBuilder(&MiniGrepNodes{})
-	pipe.AddStart(builder, finderPtr, FileFinder(os.Args[2:]))
-	pipe.AddMiddle(builder, scannerPtr, FileScanner)
+	pipe.AddCancelableStart(builder, fileFinderPtr, FileFinder(os.Args[2:]))
+	pipe.AddMiddle(builder, fileScannerPtr, FileScanner)
 	pipe.AddFinal(builder, printerPtr, Printer)
-	pipe.AddMiddleProvider(builder, matcherPtr, MatchFilterProvider(os.Args[1]))
+	pipe.AddMiddleProvider(builder, matchFilterPtr, MatchFilterProvider(os.Args[1]))
 
 	runner, err := builder.Build()
 	if err != nil {
 		log.Fatal("minigrep:", err.Error())
 	}
 
-	// start the pipeline runner in background
-	runner.Start()
-
-	// wait until the pipeline has processed all the input
-	<-runner.Done()
+	// start the pipeline and wait until it has processed all the input.
+	// On Ctrl+C, it stops reading files and prints the already read lines.
+	// On a second Ctrl+C, it exits immediately.
+	if err := pipe.RunUntilSignal(runner); err != nil {
+		log.Fatal("minigrep: ", err.Error())
+	}
 }
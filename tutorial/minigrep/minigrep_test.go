@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mariomac/pipes/testers"
+)
+
+func TestMatchFilter(t *testing.T) {
+	filter, err := MatchFilterProvider("^hel+o")()
+	require.NoError(t, err)
+	out := testers.RunMiddle(t, filter,
+		FileLine{FileName: "a.txt", Line: "hello world"},
+		FileLine{FileName: "a.txt", Line: "bye world"},
+		FileLine{FileName: "b.txt", Line: "hellllo"},
+	)
+	assert.Equal(t, []FileLine{
+		{FileName: "a.txt", Line: "hello world"},
+		{FileName: "b.txt", Line: "hellllo"},
+	}, out)
+}
+
+func TestMatchFilter_InvalidPattern(t *testing.T) {
+	_, err := MatchFilterProvider("(")()
+	require.Error(t, err)
+}
+
+func TestFileFinder_Cancel(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.txt")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0o600))
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan *os.File)
+	done := make(chan struct{})
+	go func() {
+		FileFinder([]string{file, file})(ctx, out)
+		close(done)
+	}()
+	f := testers.ReadChannel(t, out, time.Second)
+	require.NoError(t, f.Close())
+	// the finder returns without blocking on the second file
+	cancel()
+	testers.ReadChannel(t, done, time.Second)
+}
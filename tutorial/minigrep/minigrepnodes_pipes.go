@@ -0,0 +1,62 @@
+// Code generated by pipesgen. DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mariomac/pipes/pipe"
+)
+
+// fileFinderPtr returns a pointer to the fileFinder node field of MiniGrepNodes.
+func fileFinderPtr(n *MiniGrepNodes) *pipe.Start[*os.File] { return &n.fileFinder }
+
+// fileScannerPtr returns a pointer to the fileScanner node field of MiniGrepNodes.
+func fileScannerPtr(n *MiniGrepNodes) *pipe.Middle[*os.File, FileLine] { return &n.fileScanner }
+
+// matchFilterPtr returns a pointer to the matchFilter node field of MiniGrepNodes.
+func matchFilterPtr(n *MiniGrepNodes) *pipe.Middle[FileLine, FileLine] { return &n.matchFilter }
+
+// printerPtr returns a pointer to the printer node field of MiniGrepNodes.
+func printerPtr(n *MiniGrepNodes) *pipe.Final[FileLine] { return &n.printer }
+
+// NodeFields returns the names and addresses of the node fields of MiniGrepNodes.
+func (n *MiniGrepNodes) NodeFields() []pipe.NodeField {
+	return []pipe.NodeField{
+		pipe.Field("fileFinder", &n.fileFinder),
+		pipe.Field("fileScanner", &n.fileScanner),
+		pipe.Field("matchFilter", &n.matchFilter),
+		pipe.Field("printer", &n.printer),
+	}
+}
+
+// NewNodes returns a new, empty instance of MiniGrepNodes.
+func (n *MiniGrepNodes) NewNodes() pipe.NodesMap { return &MiniGrepNodes{} }
+
+// ConnectTagged connects the nodes of MiniGrepNodes according to their "pipes" struct tags.
+func (n *MiniGrepNodes) ConnectTagged() {
+}
+
+// CheckNodes verifies that all the nodes of MiniGrepNodes have been added to the pipeline Builder.
+// Optional nodes must be added with a provider that returns a nil function.
+func (n *MiniGrepNodes) CheckNodes() error {
+	var missing []string
+	if n.fileFinder == nil {
+		missing = append(missing, "fileFinder")
+	}
+	if n.fileScanner == nil {
+		missing = append(missing, "fileScanner")
+	}
+	if n.matchFilter == nil {
+		missing = append(missing, "matchFilter")
+	}
+	if n.printer == nil {
+		missing = append(missing, "printer")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("MiniGrepNodes nodes not added to the pipeline: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}